@@ -3,7 +3,9 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"github.com/Shea11012/interpreter_in_go/lexer"
 	"github.com/Shea11012/interpreter_in_go/token"
+	"math/big"
 	"strings"
 )
 
@@ -24,6 +26,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []lexer.Comment // 源码中的注释，按出现顺序排列
 }
 
 func (p *Program) TokenLiteral() string {
@@ -133,6 +136,7 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token // token.Int
 	Value int64
+	Big   *big.Int // 超出 int64 范围的字面量的值，此时 Value 为 0
 }
 
 func (i *IntegerLiteral) expressionNode() {}
@@ -187,7 +191,13 @@ func (al *ArrayLiteral) expressionNode() {}
 
 type HashLiteral struct {
 	Token token.Token // {
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // 按源码中出现的顺序排列
+}
+
+// HashPair 哈希字面量中的一个键值对
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (h *HashLiteral) TokenLiteral() string {
@@ -197,8 +207,8 @@ func (h *HashLiteral) TokenLiteral() string {
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := make([]string, 0, len(h.Pairs))
-	for key, value := range h.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -302,6 +312,7 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // { token
 	Statements []Statement
+	Rbrace     token.Token // } token，用于确定代码块结束的位置
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -402,3 +413,65 @@ func (i *IndexExpression) String() string {
 }
 
 func (i *IndexExpression) expressionNode() {}
+
+// SliceExpression 切片表达式 left[low:high]，省略的边界为 nil
+type SliceExpression struct {
+	Token token.Token // [
+	Left  Expression
+	Low   Expression
+	High  Expression
+}
+
+func (s *SliceExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("]")
+	out.WriteString(")")
+
+	return out.String()
+}
+
+func (s *SliceExpression) expressionNode() {}
+
+// MacroLiteral 宏 macro(x, y) { ... }，在宏展开阶段被处理，不会传给 evaluator 和 compiler
+type MacroLiteral struct {
+	Token      token.Token // macro token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (m *MacroLiteral) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+func (m *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := make([]string, 0, len(m.Parameters))
+
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(m.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(m.Body.String())
+
+	return out.String()
+}
+
+func (m *MacroLiteral) expressionNode() {}
@@ -0,0 +1,168 @@
+package ast
+
+import (
+	"math/big"
+)
+
+// Copy 深拷贝 node，修改返回的节点不会影响原来的节点
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+	case *LetStatement:
+		c := *n
+		c.Name = copyIdentifier(n.Name)
+		c.Value = copyExpression(n.Value)
+		return &c
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = copyExpression(n.ReturnValue)
+		return &c
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = copyExpression(n.Expression)
+		return &c
+	case *BlockStatement:
+		return copyBlock(n)
+	case *Identifier:
+		return copyIdentifier(n)
+	case *IntegerLiteral:
+		c := *n
+		if n.Big != nil {
+			c.Big = new(big.Int).Set(n.Big)
+		}
+		return &c
+	case *StringLiteral:
+		c := *n
+		return &c
+	case *Boolean:
+		c := *n
+		return &c
+	case *PrefixExpression:
+		c := *n
+		c.Right = copyExpression(n.Right)
+		return &c
+	case *InfixExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Right = copyExpression(n.Right)
+		return &c
+	case *IfExpression:
+		c := *n
+		c.Condition = copyExpression(n.Condition)
+		c.Consequence = copyBlock(n.Consequence)
+		c.Alternative = copyBlock(n.Alternative)
+		return &c
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = copyBlock(n.Body)
+		return &c
+	case *CallExpression:
+		c := *n
+		c.Function = copyExpression(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+	case *HashLiteral:
+		c := *n
+		if n.Pairs != nil {
+			c.Pairs = make([]HashPair, len(n.Pairs))
+			for i, pair := range n.Pairs {
+				c.Pairs[i] = HashPair{Key: copyExpression(pair.Key), Value: copyExpression(pair.Value)}
+			}
+		}
+		return &c
+	case *IndexExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Index = copyExpression(n.Index)
+		return &c
+	case *SliceExpression:
+		c := *n
+		c.Left = copyExpression(n.Left)
+		c.Low = copyExpression(n.Low)
+		c.High = copyExpression(n.High)
+		return &c
+	}
+
+	return node
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	result := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		if !isNil(stmt) {
+			result[i] = Copy(stmt).(Statement)
+		}
+	}
+
+	return result
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+
+	result := make([]Expression, len(exps))
+	for i, exp := range exps {
+		result[i] = copyExpression(exp)
+	}
+
+	return result
+}
+
+func copyExpression(exp Expression) Expression {
+	if isNil(exp) {
+		return exp
+	}
+
+	return Copy(exp).(Expression)
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+
+	c := *ident
+	return &c
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+
+	result := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		result[i] = copyIdentifier(ident)
+	}
+
+	return result
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	c := *block
+	c.Statements = copyStatements(block.Statements)
+	return &c
+}
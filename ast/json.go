@@ -0,0 +1,454 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"math/big"
+)
+
+// EncodeJSON 把节点编码为 JSON，每个节点是一个带 type 字段的对象，其余字段与节点的字段一一对应，
+// token 包含位置信息。nil 节点编码为 null，nil 和空的列表分别编码为 null 和 []，
+// 因此 DecodeJSON 可以还原出与原来完全相同的 AST
+func EncodeJSON(node Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// DecodeJSON 从 EncodeJSON 的结果还原节点，缺少的字段使用零值
+func DecodeJSON(data []byte) (Node, error) {
+	return decode(data)
+}
+
+// DecodeProgramJSON 从 EncodeJSON 的结果还原 *Program
+func DecodeProgramJSON(data []byte) (*Program, error) {
+	node, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("expected a Program, got %T", node)
+	}
+	return program, nil
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// jsonObject 按字段顺序输出的 JSON 对象，type 总是第一个字段
+type jsonObject []field
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func encode(node Node) interface{} {
+	if isNil(node) {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return jsonObject{{"type", "Program"}, {"statements", encodeStatements(n.Statements)}, {"comments", n.Comments}}
+	case *LetStatement:
+		return jsonObject{{"type", "LetStatement"}, {"token", n.Token}, {"name", encode(n.Name)}, {"value", encode(n.Value)}}
+	case *ReturnStatement:
+		return jsonObject{{"type", "ReturnStatement"}, {"token", n.Token}, {"returnValue", encode(n.ReturnValue)}}
+	case *ExpressionStatement:
+		return jsonObject{{"type", "ExpressionStatement"}, {"token", n.Token}, {"expression", encode(n.Expression)}}
+	case *BlockStatement:
+		return jsonObject{
+			{"type", "BlockStatement"}, {"token", n.Token},
+			{"statements", encodeStatements(n.Statements)}, {"rbrace", n.Rbrace},
+		}
+	case *Identifier:
+		return jsonObject{{"type", "Identifier"}, {"token", n.Token}, {"value", n.Value}}
+	case *IntegerLiteral:
+		if n.Big != nil {
+			return jsonObject{{"type", "IntegerLiteral"}, {"token", n.Token}, {"value", n.Big}}
+		}
+		return jsonObject{{"type", "IntegerLiteral"}, {"token", n.Token}, {"value", n.Value}}
+	case *StringLiteral:
+		return jsonObject{{"type", "StringLiteral"}, {"token", n.Token}, {"value", n.Value}}
+	case *Boolean:
+		return jsonObject{{"type", "Boolean"}, {"token", n.Token}, {"value", n.Value}}
+	case *PrefixExpression:
+		return jsonObject{{"type", "PrefixExpression"}, {"token", n.Token}, {"operator", n.Operator}, {"right", encode(n.Right)}}
+	case *InfixExpression:
+		return jsonObject{
+			{"type", "InfixExpression"}, {"token", n.Token},
+			{"left", encode(n.Left)}, {"operator", n.Operator}, {"right", encode(n.Right)},
+		}
+	case *IfExpression:
+		return jsonObject{
+			{"type", "IfExpression"}, {"token", n.Token}, {"condition", encode(n.Condition)},
+			{"consequence", encode(n.Consequence)}, {"alternative", encode(n.Alternative)},
+		}
+	case *FunctionLiteral:
+		return jsonObject{
+			{"type", "FunctionLiteral"}, {"token", n.Token}, {"parameters", encodeIdentifiers(n.Parameters)},
+			{"body", encode(n.Body)}, {"name", n.Name},
+		}
+	case *MacroLiteral:
+		return jsonObject{
+			{"type", "MacroLiteral"}, {"token", n.Token}, {"parameters", encodeIdentifiers(n.Parameters)},
+			{"body", encode(n.Body)},
+		}
+	case *CallExpression:
+		return jsonObject{
+			{"type", "CallExpression"}, {"token", n.Token},
+			{"function", encode(n.Function)}, {"arguments", encodeExpressions(n.Arguments)},
+		}
+	case *ArrayLiteral:
+		return jsonObject{{"type", "ArrayLiteral"}, {"token", n.Token}, {"elements", encodeExpressions(n.Elements)}}
+	case *HashLiteral:
+		var pairs []interface{}
+		if n.Pairs != nil {
+			pairs = make([]interface{}, 0, len(n.Pairs))
+			for _, pair := range n.Pairs {
+				pairs = append(pairs, jsonObject{{"key", encode(pair.Key)}, {"value", encode(pair.Value)}})
+			}
+		}
+		return jsonObject{{"type", "HashLiteral"}, {"token", n.Token}, {"pairs", pairs}}
+	case *IndexExpression:
+		return jsonObject{{"type", "IndexExpression"}, {"token", n.Token}, {"left", encode(n.Left)}, {"index", encode(n.Index)}}
+	case *SliceExpression:
+		return jsonObject{
+			{"type", "SliceExpression"}, {"token", n.Token},
+			{"left", encode(n.Left)}, {"low", encode(n.Low)}, {"high", encode(n.High)},
+		}
+	}
+
+	return jsonObject{{"type", fmt.Sprintf("%T", node)}}
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	if stmts == nil {
+		return nil
+	}
+
+	result := make([]interface{}, 0, len(stmts))
+	for _, stmt := range stmts {
+		result = append(result, encode(stmt))
+	}
+	return result
+}
+
+func encodeExpressions(exps []Expression) []interface{} {
+	if exps == nil {
+		return nil
+	}
+
+	result := make([]interface{}, 0, len(exps))
+	for _, exp := range exps {
+		result = append(result, encode(exp))
+	}
+	return result
+}
+
+func encodeIdentifiers(idents []*Identifier) []interface{} {
+	if idents == nil {
+		return nil
+	}
+
+	result := make([]interface{}, 0, len(idents))
+	for _, ident := range idents {
+		result = append(result, encode(ident))
+	}
+	return result
+}
+
+// isNull 判断 JSON 值是否为 null 或不存在
+func isNull(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || string(data) == "null"
+}
+
+func decode(data []byte) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	d := &fieldDecoder{fields: fields}
+	d.value("type", &d.typ)
+
+	var node Node
+	switch d.typ {
+	case "Program":
+		n := &Program{Statements: d.statements("statements")}
+		d.value("comments", &n.Comments)
+		node = n
+	case "LetStatement":
+		node = &LetStatement{Token: d.token(), Name: d.identifier("name"), Value: d.expression("value")}
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: d.token(), ReturnValue: d.expression("returnValue")}
+	case "ExpressionStatement":
+		node = &ExpressionStatement{Token: d.token(), Expression: d.expression("expression")}
+	case "BlockStatement":
+		n := &BlockStatement{Token: d.token(), Statements: d.statements("statements")}
+		d.value("rbrace", &n.Rbrace)
+		node = n
+	case "Identifier":
+		n := &Identifier{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "IntegerLiteral":
+		n := &IntegerLiteral{Token: d.token()}
+		// 超出 int64 范围的数字解码为 Big，其余情况按 int64 解码并报告原有错误
+		var value big.Int
+		if raw := d.fields["value"]; len(raw) > 0 && raw[0] != '"' && value.UnmarshalJSON(raw) == nil && !value.IsInt64() {
+			n.Big = &value
+		} else {
+			d.value("value", &n.Value)
+		}
+		node = n
+	case "StringLiteral":
+		n := &StringLiteral{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "Boolean":
+		n := &Boolean{Token: d.token()}
+		d.value("value", &n.Value)
+		node = n
+	case "PrefixExpression":
+		n := &PrefixExpression{Token: d.token(), Right: d.expression("right")}
+		d.value("operator", &n.Operator)
+		node = n
+	case "InfixExpression":
+		n := &InfixExpression{Token: d.token(), Left: d.expression("left"), Right: d.expression("right")}
+		d.value("operator", &n.Operator)
+		node = n
+	case "IfExpression":
+		node = &IfExpression{
+			Token:       d.token(),
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence"),
+			Alternative: d.block("alternative"),
+		}
+	case "FunctionLiteral":
+		n := &FunctionLiteral{Token: d.token(), Parameters: d.identifiers("parameters"), Body: d.block("body")}
+		d.value("name", &n.Name)
+		node = n
+	case "MacroLiteral":
+		node = &MacroLiteral{Token: d.token(), Parameters: d.identifiers("parameters"), Body: d.block("body")}
+	case "CallExpression":
+		node = &CallExpression{Token: d.token(), Function: d.expression("function"), Arguments: d.expressions("arguments")}
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: d.token(), Elements: d.expressions("elements")}
+	case "HashLiteral":
+		node = &HashLiteral{Token: d.token(), Pairs: d.pairs("pairs")}
+	case "IndexExpression":
+		node = &IndexExpression{Token: d.token(), Left: d.expression("left"), Index: d.expression("index")}
+	case "SliceExpression":
+		node = &SliceExpression{
+			Token: d.token(),
+			Left:  d.expression("left"),
+			Low:   d.expression("low"),
+			High:  d.expression("high"),
+		}
+	default:
+		return nil, fmt.Errorf("unknown node type %q", d.typ)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+// fieldDecoder 解码一个节点的字段，记录遇到的第一个错误
+type fieldDecoder struct {
+	typ    string
+	fields map[string]json.RawMessage
+	err    error
+}
+
+func (d *fieldDecoder) fail(key string, err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%s.%s: %s", d.typ, key, err)
+	}
+}
+
+func (d *fieldDecoder) value(key string, v interface{}) {
+	if raw, ok := d.fields[key]; ok && d.err == nil {
+		if err := json.Unmarshal(raw, v); err != nil {
+			d.fail(key, err)
+		}
+	}
+}
+
+func (d *fieldDecoder) token() token.Token {
+	var tok token.Token
+	d.value("token", &tok)
+	return tok
+}
+
+// list 解码节点列表，null 返回 nil，[] 返回空的切片
+func (d *fieldDecoder) list(key string) []json.RawMessage {
+	raw := d.fields[key]
+	if d.err != nil || isNull(raw) {
+		return nil
+	}
+
+	list := []json.RawMessage{}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		d.fail(key, err)
+		return nil
+	}
+	return list
+}
+
+func (d *fieldDecoder) node(key string, raw []byte) Node {
+	if d.err != nil {
+		return nil
+	}
+
+	node, err := decode(raw)
+	if err != nil {
+		d.fail(key, err)
+		return nil
+	}
+	return node
+}
+
+func (d *fieldDecoder) expression(key string) Expression {
+	return d.toExpression(key, d.node(key, d.fields[key]))
+}
+
+func (d *fieldDecoder) toExpression(key string, node Node) Expression {
+	if node == nil {
+		return nil
+	}
+
+	exp, ok := node.(Expression)
+	if !ok {
+		d.fail(key, fmt.Errorf("expected an expression, got %T", node))
+	}
+	return exp
+}
+
+func (d *fieldDecoder) identifier(key string) *Identifier {
+	return d.toIdentifier(key, d.node(key, d.fields[key]))
+}
+
+func (d *fieldDecoder) toIdentifier(key string, node Node) *Identifier {
+	if node == nil {
+		return nil
+	}
+
+	ident, ok := node.(*Identifier)
+	if !ok {
+		d.fail(key, fmt.Errorf("expected an Identifier, got %T", node))
+	}
+	return ident
+}
+
+func (d *fieldDecoder) block(key string) *BlockStatement {
+	node := d.node(key, d.fields[key])
+	if node == nil {
+		return nil
+	}
+
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail(key, fmt.Errorf("expected a BlockStatement, got %T", node))
+	}
+	return block
+}
+
+func (d *fieldDecoder) statements(key string) []Statement {
+	list := d.list(key)
+	if list == nil {
+		return nil
+	}
+
+	stmts := make([]Statement, 0, len(list))
+	for _, raw := range list {
+		node := d.node(key, raw)
+		stmt, ok := node.(Statement)
+		if node != nil && !ok {
+			d.fail(key, fmt.Errorf("expected a statement, got %T", node))
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *fieldDecoder) expressions(key string) []Expression {
+	list := d.list(key)
+	if list == nil {
+		return nil
+	}
+
+	exps := make([]Expression, 0, len(list))
+	for _, raw := range list {
+		exps = append(exps, d.toExpression(key, d.node(key, raw)))
+	}
+	return exps
+}
+
+func (d *fieldDecoder) identifiers(key string) []*Identifier {
+	list := d.list(key)
+	if list == nil {
+		return nil
+	}
+
+	idents := make([]*Identifier, 0, len(list))
+	for _, raw := range list {
+		idents = append(idents, d.toIdentifier(key, d.node(key, raw)))
+	}
+	return idents
+}
+
+func (d *fieldDecoder) pairs(key string) []HashPair {
+	list := d.list(key)
+	if list == nil {
+		return nil
+	}
+
+	pairs := make([]HashPair, 0, len(list))
+	for _, raw := range list {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			d.fail(key, err)
+			return nil
+		}
+
+		pairs = append(pairs, HashPair{
+			Key:   d.toExpression(key, d.node(key, fields["key"])),
+			Value: d.toExpression(key, d.node(key, fields["value"])),
+		})
+	}
+	return pairs
+}
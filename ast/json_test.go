@@ -0,0 +1,86 @@
+package ast
+
+import (
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := allNodes()
+	program.Comments = []lexer.Comment{
+		{Token: token.Token{Type: token.COMMENT, Literal: "// c", Line: 1, Column: 3}},
+		{Token: token.Token{Type: token.COMMENT, Literal: "// d", Line: 2, Column: 8}, Trailing: true},
+	}
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10)
+	program.Statements = append(program.Statements,
+		exprStmt(&FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "fn", Line: 2, Column: 1}, Name: "f", Body: block()}),
+		exprStmt(&MacroLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Rbrace: token.Token{Type: token.RBRACE, Literal: "}"}}}),
+		exprStmt(&ArrayLiteral{Elements: []Expression{}}),
+		exprStmt(&IntegerLiteral{Token: token.Token{Type: token.INT, Literal: huge.String()}, Big: huge}),
+		exprStmt(&HashLiteral{Pairs: []HashPair{}}),
+	)
+
+	data, err := EncodeJSON(program)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+
+	decoded, err := DecodeProgramJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeProgramJSON returned error: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, program) {
+		again, _ := EncodeJSON(decoded)
+		t.Fatalf("decoded program is not equal.\nencoded=%s\nagain=%s", data, again)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	node := &LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+		Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Line: 1, Column: 5}, Value: "x"},
+	}
+
+	data, err := EncodeJSON(node)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+
+	expected := `{"type":"LetStatement",` +
+		`"token":{"type":"LET","literal":"let","line":1,"column":1},` +
+		`"name":{"type":"Identifier","token":{"type":"IDENT","literal":"x","line":1,"column":5},"value":"x"},` +
+		`"value":null}`
+	if string(data) != expected {
+		t.Errorf("wrong JSON.\ngot=%s\nwant=%s", data, expected)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type":"Unknown"}`, `unknown node type "Unknown"`},
+		{`{"type":"LetStatement","name":{"type":"IntegerLiteral"}}`, "LetStatement.name: expected an Identifier"},
+		{`{"type":"Program","statements":[{"type":"Identifier"}]}`, "Program.statements: expected a statement"},
+		{`{"type":"InfixExpression","left":{"type":"BlockStatement"}}`, "InfixExpression.left: expected an expression"},
+		{`{"type":"IntegerLiteral","value":"1"}`, "IntegerLiteral.value: json: cannot unmarshal string"},
+		{`[]`, "cannot unmarshal array"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	if _, err := DecodeProgramJSON([]byte(`{"type":"Identifier"}`)); err == nil {
+		t.Errorf("DecodeProgramJSON should reject nodes other than Program")
+	}
+}
@@ -0,0 +1,137 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// ModifierFunc 接收一个子节点已经修改过的节点，返回替换它的节点
+type ModifierFunc func(Node) Node
+
+// Modify 按后序遍历修改 node 及其所有子节点，返回修改后的节点，会直接修改传入的节点
+//
+// 语句列表中的语句被替换为 nil 时会被删除。
+// let 的变量名和函数参数只能替换为 *Identifier，代码块只能替换为 *BlockStatement，否则会 panic
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+	case *BlockStatement:
+		n.Statements = modifyStatements(n.Statements, modifier)
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *MacroLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+	case *HashLiteral:
+		for i, pair := range n.Pairs {
+			n.Pairs[i] = HashPair{
+				Key:   modifyExpression(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+	case *IndexExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Index = modifyExpression(n.Index, modifier)
+	case *SliceExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Low = modifyExpression(n.Low, modifier)
+		n.High = modifyExpression(n.High, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	result := stmts[:0]
+	for _, stmt := range stmts {
+		if isNil(stmt) {
+			continue
+		}
+
+		modified := Modify(stmt, modifier)
+		if isNil(modified) {
+			continue
+		}
+
+		s, ok := modified.(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Modify: cannot replace statement %T with %T", stmt, modified))
+		}
+		result = append(result, s)
+	}
+
+	return result
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) {
+	for i, exp := range exps {
+		exps[i] = modifyExpression(exp, modifier)
+	}
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if isNil(exp) {
+		return exp
+	}
+
+	modified := Modify(exp, modifier)
+	if isNil(modified) {
+		return nil
+	}
+
+	e, ok := modified.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace expression %T with %T", exp, modified))
+	}
+	return e
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+
+	modified, ok := Modify(ident, modifier).(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace %T with a node other than *Identifier", ident))
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+
+	modified, ok := Modify(block, modifier).(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace %T with a node other than *BlockStatement", block))
+	}
+	return modified
+}
@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"github.com/Shea11012/interpreter_in_go/token"
+)
+
+// TokenOf 返回节点的 token，其中包含节点在源码中的位置
+// Program 返回第一条语句的 token，没有 token 的节点返回零值
+func TokenOf(node Node) token.Token {
+	if isNil(node) {
+		return token.Token{}
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return TokenOf(n.Statements[0])
+		}
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *ExpressionStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *MacroLiteral:
+		return n.Token
+	case *CallExpression:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *HashLiteral:
+		return n.Token
+	case *IndexExpression:
+		return n.Token
+	case *SliceExpression:
+		return n.Token
+	}
+
+	return token.Token{}
+}
@@ -0,0 +1,115 @@
+package ast
+
+import (
+	"reflect"
+)
+
+// Visitor Walk 遇到每个节点时调用 Visit，返回的 Visitor 不为 nil 时用它继续遍历子节点，
+// 子节点遍历完之后再调用一次 Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk 按深度优先的顺序遍历 node 及其所有子节点，子节点按源码中出现的顺序访问，nil 子节点会被跳过
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walk(v, n.Name)
+		walk(v, n.Value)
+	case *ReturnStatement:
+		walk(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walk(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *PrefixExpression:
+		walk(v, n.Right)
+	case *InfixExpression:
+		walk(v, n.Left)
+		walk(v, n.Right)
+	case *IfExpression:
+		walk(v, n.Condition)
+		walk(v, n.Consequence)
+		walk(v, n.Alternative)
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walk(v, p)
+		}
+		walk(v, n.Body)
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			walk(v, p)
+		}
+		walk(v, n.Body)
+	case *CallExpression:
+		walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walk(v, pair.Key)
+			walk(v, pair.Value)
+		}
+	case *IndexExpression:
+		walk(v, n.Left)
+		walk(v, n.Index)
+	case *SliceExpression:
+		walk(v, n.Left)
+		walk(v, n.Low)
+		walk(v, n.High)
+	}
+
+	v.Visit(nil)
+}
+
+// walk 跳过 nil 节点，包括值为 nil 的具体类型指针
+func walk(v Visitor, node Node) {
+	if !isNil(node) {
+		Walk(v, node)
+	}
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		walk(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walk(v, exp)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect 按深度优先的顺序对每个节点调用 f，f 返回 false 时不再遍历该节点的子节点
+// 遍历完一个节点的子节点后会调用 f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// isNil 判断节点是否为 nil，包括值为 nil 的具体类型指针
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
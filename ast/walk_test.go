@@ -0,0 +1,183 @@
+package ast
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func one() Expression { return &IntegerLiteral{Value: 1} }
+func two() Expression { return &IntegerLiteral{Value: 2} }
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Statements: stmts}
+}
+
+func exprStmt(exp Expression) Statement {
+	return &ExpressionStatement{Expression: exp}
+}
+
+// allNodes 包含每一种节点的程序
+func allNodes() *Program {
+	return &Program{Statements: []Statement{
+		&LetStatement{Name: ident("a"), Value: &PrefixExpression{Operator: "-", Right: one()}},
+		&ReturnStatement{ReturnValue: &InfixExpression{Left: one(), Operator: "+", Right: two()}},
+		exprStmt(&IfExpression{
+			Condition:   &Boolean{Value: true},
+			Consequence: block(exprStmt(one())),
+			Alternative: block(exprStmt(two())),
+		}),
+		exprStmt(&IfExpression{Condition: one(), Consequence: block()}),
+		exprStmt(&CallExpression{
+			Function:  &FunctionLiteral{Parameters: []*Identifier{ident("x")}, Body: block(exprStmt(ident("x")))},
+			Arguments: []Expression{one(), &StringLiteral{Value: "s"}},
+		}),
+		exprStmt(&HashLiteral{Pairs: []HashPair{{Key: one(), Value: &ArrayLiteral{Elements: []Expression{two()}}}}}),
+		exprStmt(&IndexExpression{Left: ident("a"), Index: one()}),
+		exprStmt(&SliceExpression{Left: ident("a"), High: two()}),
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var types []string
+	depth := 0
+	Inspect(allNodes(), func(node Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		types = append(types, strings.Repeat(" ", depth)+strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+		depth++
+		return true
+	})
+
+	expected := []string{
+		"Program",
+		" LetStatement", "  Identifier", "  PrefixExpression", "   IntegerLiteral",
+		" ReturnStatement", "  InfixExpression", "   IntegerLiteral", "   IntegerLiteral",
+		" ExpressionStatement", "  IfExpression", "   Boolean",
+		"   BlockStatement", "    ExpressionStatement", "     IntegerLiteral",
+		"   BlockStatement", "    ExpressionStatement", "     IntegerLiteral",
+		" ExpressionStatement", "  IfExpression", "   IntegerLiteral", "   BlockStatement",
+		" ExpressionStatement", "  CallExpression",
+		"   FunctionLiteral", "    Identifier", "    BlockStatement", "     ExpressionStatement", "      Identifier",
+		"   IntegerLiteral", "   StringLiteral",
+		" ExpressionStatement", "  HashLiteral", "   IntegerLiteral", "   ArrayLiteral", "    IntegerLiteral",
+		" ExpressionStatement", "  IndexExpression", "   Identifier", "   IntegerLiteral",
+		" ExpressionStatement", "  SliceExpression", "   Identifier", "   IntegerLiteral",
+	}
+
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("wrong traversal.\ngot=%q\nwant=%q", types, expected)
+	}
+	if depth != 0 {
+		t.Errorf("Visit(nil) not called once per node. depth=%d", depth)
+	}
+}
+
+func TestInspectSkipChildren(t *testing.T) {
+	count := 0
+	Inspect(allNodes(), func(node Node) bool {
+		if node != nil {
+			count++
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		_, isStatement := node.(Statement)
+		return !isFunction && !isStatement
+	})
+
+	if count != 9 {
+		t.Errorf("wrong number of visited nodes. got=%d, want=9", count)
+	}
+}
+
+func TestModify(t *testing.T) {
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if ok && integer.Value == 1 {
+			integer.Value = 2
+		}
+		return node
+	}
+
+	program := allNodes()
+	Modify(program, turnOneIntoTwo)
+
+	Inspect(program, func(node Node) bool {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value != 2 {
+			t.Errorf("integer literal was not modified: %d", integer.Value)
+		}
+		return true
+	})
+}
+
+func TestModifyReplaceAndDelete(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		exprStmt(one()),
+		&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident("a"), Value: ident("b")},
+		exprStmt(&FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "fn"}, Parameters: []*Identifier{ident("b")}, Body: block(exprStmt(one()), exprStmt(ident("b")))}),
+	}}
+
+	modified := Modify(program, func(node Node) Node {
+		switch node := node.(type) {
+		case *Identifier:
+			if node.Value == "b" {
+				return ident("c")
+			}
+		case *ExpressionStatement:
+			// 删除只有字面量的语句
+			if _, ok := node.Expression.(*IntegerLiteral); ok {
+				return nil
+			}
+		}
+		return node
+	})
+
+	expected := "let a = c;fn(c) c"
+	if modified.String() != expected {
+		t.Errorf("wrong program. got=%q, want=%q", modified.String(), expected)
+	}
+}
+
+func TestModifyWrongType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic")
+		}
+	}()
+
+	Modify(&LetStatement{Name: ident("a"), Value: one()}, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return one()
+		}
+		return node
+	})
+}
+
+func TestCopy(t *testing.T) {
+	program := allNodes()
+	copied := Copy(program)
+	if copied.String() != program.String() {
+		t.Fatalf("copy is not equal. got=%q, want=%q", copied.String(), program.String())
+	}
+
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 42
+		}
+		return node
+	})
+
+	Inspect(program, func(node Node) bool {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 42 {
+			t.Errorf("modifying the copy changed the original")
+		}
+		return true
+	})
+}
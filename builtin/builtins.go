@@ -3,59 +3,118 @@ package builtin
 import (
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/object"
+	"unicode/utf8"
 )
 
 type BuiltinFn struct {
 	Name    string
 	Builtin *object.Builtin
+	IO      bool   // 是否读写标准输入输出，沙箱预设会据此过滤
+	Arity   *Arity // 接受的参数个数，为 nil 时表示未知，静态检查不会检查参数个数
+
+	// Signature 用于文档和编辑器提示的签名，如 push(ARRAY, OBJECT) ARRAY，为空时表示未知
+	Signature string
 }
 
-var BuiltinFns []BuiltinFn
+// Arity 内置函数接受的参数个数范围，Max 小于 0 表示不限
+type Arity struct {
+	Min int
+	Max int
+}
 
-type method func() BuiltinFn
+// ExactArgs 只接受 n 个参数
+func ExactArgs(n int) *Arity {
+	return &Arity{Min: n, Max: n}
+}
 
-func init() {
-	m := []method{
-		lenMethod,
-		putsMethod,
-		firstMethod,
-		lastMethod,
-		restMethod,
-		pushMethod,
-		mapMethod,
-	}
-	registerMethod(m...)
+// MinArgs 至少接受 n 个参数
+func MinArgs(n int) *Arity {
+	return &Arity{Min: n, Max: -1}
 }
 
-func registerMethod(methods ...method) {
-	for _, m := range methods {
-		BuiltinFns = append(BuiltinFns, m())
-	}
+// RangeArgs 接受 min 到 max 个参数
+func RangeArgs(min int, max int) *Arity {
+	return &Arity{Min: min, Max: max}
+}
+
+// Accepts 判断 n 个参数是否合法
+func (a *Arity) Accepts(n int) bool {
+	return n >= a.Min && (a.Max < 0 || n <= a.Max)
 }
 
-func GetBuiltinByName(name string) *object.Builtin {
-	for _, def := range BuiltinFns {
-		if def.Name == name {
-			return def.Builtin
-		}
+// String 与运行时参数个数错误中 want 的格式相同，如 1、>=1、2 or 3
+func (a *Arity) String() string {
+	switch {
+	case a.Max < 0:
+		return fmt.Sprintf(">=%d", a.Min)
+	case a.Min == a.Max:
+		return fmt.Sprintf("%d", a.Min)
+	case a.Max == a.Min+1:
+		return fmt.Sprintf("%d or %d", a.Min, a.Max)
 	}
 
-	return nil
+	return fmt.Sprintf("%d..%d", a.Min, a.Max)
+}
+
+type method func() BuiltinFn
+
+// standardMethods 标准内置函数，注册顺序即 Registry.Names 的顺序
+var standardMethods = []method{
+	lenMethod,
+	putsMethod,
+	firstMethod,
+	lastMethod,
+	restMethod,
+	pushMethod,
+	mapMethod,
+	reduceMethod,
+	printMethod,
+	eprintMethod,
+	readLineMethod,
+	readAllMethod,
+	splitMethod,
+	joinMethod,
+	trimMethod,
+	trimLeftMethod,
+	trimRightMethod,
+	upperMethod,
+	lowerMethod,
+	containsMethod,
+	startsWithMethod,
+	endsWithMethod,
+	indexOfMethod,
+	replaceMethod,
+	repeatMethod,
+	substrMethod,
+	charsMethod,
+	formatMethod,
+	sprintfMethod,
+	keysMethod,
+	valuesMethod,
+	entriesMethod,
+	hasKeyMethod,
+	deleteMethod,
+	mergeMethod,
 }
 
+// lenMethod len(x) 字符串返回字符数而不是字节数，资源限制中字符串的大小按字节数计算
 func lenMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "len",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "len",
+		Signature: "len(STRING | ARRAY | HASH) INTEGER",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s", arg.Type())
 			}
@@ -65,10 +124,13 @@ func lenMethod() BuiltinFn {
 
 func putsMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "puts",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "puts",
+		Signature: "puts(OBJECT...)",
+		Arity:     MinArgs(0),
+		IO:        true,
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				_, _ = fmt.Fprintln(rt.IO.Stdout, arg.Inspect())
 			}
 
 			return nil
@@ -78,8 +140,10 @@ func putsMethod() BuiltinFn {
 
 func firstMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "first",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "first",
+		Signature: "first(ARRAY) OBJECT",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -100,8 +164,10 @@ func firstMethod() BuiltinFn {
 
 func lastMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "last",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "last",
+		Signature: "last(ARRAY) OBJECT",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -123,8 +189,10 @@ func lastMethod() BuiltinFn {
 
 func restMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "rest",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "rest",
+		Signature: "rest(ARRAY) ARRAY",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -149,8 +217,10 @@ func restMethod() BuiltinFn {
 
 func pushMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "push",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		Name:      "push",
+		Signature: "push(ARRAY, OBJECT) ARRAY",
+		Arity:     ExactArgs(2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -171,14 +241,13 @@ func pushMethod() BuiltinFn {
 	}
 }
 
-// todo
+// mapMethod map(arr, fn) 对 arr 的每个元素调用 fn，返回由结果组成的新数组
 func mapMethod() BuiltinFn {
 	return BuiltinFn{
-		Name: "map",
-		Builtin: &object.Builtin{Fn: func(args ...object.Object) object.Object {
-			fmt.Println(len(args))
-			fmt.Printf("%+v\n",args[0])
-			fmt.Printf("%+v\n",args[1])
+		Name:      "map",
+		Signature: "map(ARRAY, FUNCTION) ARRAY",
+		Arity:     ExactArgs(2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -187,70 +256,86 @@ func mapMethod() BuiltinFn {
 				return newError("argument to `map` must be array, got %s", args[0].Type())
 			}
 
-			if args[1].Type() != object.COMPILE_FUNCTION_OBJ {
-				return newError("second argument to `map` must be compiled function")
+			if !isCallable(args[1]) {
+				return newError("second argument to `map` must be function, got %s", args[1].Type())
 			}
 
-			/*arr := args[0].(*object.Array)
-			fmt.Printf("%T %+v",arr,arr)
-			fn := args[1].(*object.CompiledFunction)
-			fmt.Printf("%T %+v",fn,fn)*/
-			/*fnLiteral := &ast.FunctionLiteral{
-				Token: token.Token{
-					Type: token.FUNCTION,
-				},
-				Parameters: fn.Parameters,
-				Body:       fn.Body,
+			if rt == nil || rt.Call == nil {
+				return newError("`map` cannot call functions here")
 			}
 
-			callExpression := &ast.CallExpression{
-				Token:     token.Token{},
-				Function:  fnLiteral,
-				Arguments: nil,
+			arr := args[0].(*object.Array)
+			if err := rt.CheckSize(len(arr.Elements)); err != nil {
+				return newError("%s", err)
 			}
 
-			comp := compiler.New()
-
 			newElements := make([]object.Object, 0, len(arr.Elements))
 			for _, el := range arr.Elements {
-				integer := el.(*object.Integer)
-				arguments := []ast.Expression{
-					&ast.IntegerLiteral{
-						Token: token.Token{
-							Type: token.INT,
-						},
-						Value: integer.Value,
-					},
+				result, err := rt.Call(args[1], el)
+				if err != nil {
+					return newError("%s", err)
 				}
-				callExpression.Arguments = arguments
-
-				expressionStmt := &ast.ExpressionStatement{
-					Token:      token.Token{},
-					Expression: callExpression,
+				if result.Type() == object.ERROR_OBJ {
+					return result
 				}
+				newElements = append(newElements, result)
+			}
+
+			return &object.Array{Elements: newElements}
+		}},
+	}
+}
+
+// reduceMethod reduce(arr, initial, fn) 从 initial 开始依次用 fn(acc, el) 的结果作为新的 acc，返回最后的 acc
+func reduceMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "reduce",
+		Signature: "reduce(ARRAY, OBJECT, FUNCTION) OBJECT",
+		Arity:     ExactArgs(3),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("first argument to `reduce` must be array, got %s", args[0].Type())
+			}
+
+			if !isCallable(args[2]) {
+				return newError("third argument to `reduce` must be function, got %s", args[2].Type())
+			}
 
-				program := &ast.Program{Statements: []ast.Statement{expressionStmt}}
-				err := comp.Compile(program)
+			if rt == nil || rt.Call == nil {
+				return newError("`reduce` cannot call functions here")
+			}
+
+			result := args[1]
+			for _, el := range args[0].(*object.Array).Elements {
+				next, err := rt.Call(args[2], result, el)
 				if err != nil {
-					return newError("map compile err: %s", err)
+					return newError("%s", err)
 				}
-				v := vm.New(comp.Bytecode())
-				err = v.Run()
-				if err != nil {
-					return newError("map run err: %s", err)
+				if next.Type() == object.ERROR_OBJ {
+					return next
 				}
+				result = next
+			}
 
-				obj := v.LastPoppedStackElem()
-				comp.Reset()
-				newElements = append(newElements, obj)
-			}*/
-
-			// return &object.Array{Elements: newElements}
-			return nil
+			return result
 		}},
 	}
 }
 
+// isCallable 判断 obj 能否通过 Runtime.Call 调用
+func isCallable(obj object.Object) bool {
+	switch obj.Type() {
+	case object.CLOSURE_OBJ, object.FUNCTION_OBJ, object.BUILTIN_OBJ:
+		return true
+	}
+
+	return false
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
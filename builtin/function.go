@@ -0,0 +1,172 @@
+package builtin
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"reflect"
+	"strings"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*object.Runtime)(nil))
+)
+
+// FromFunc 将普通的 Go 函数包装为内置函数，如 func(s string, n int64) (string, error)
+// 参数和返回值通过 object.ToGo/object.FromGo 转换，参数数量和类型不匹配时返回 *object.Error，
+// 最后一个返回值为 error 且不为 nil 时，返回带有函数名的 *object.Error，支持可变参数函数
+// 第一个参数为 *object.Runtime 时会传入当前的运行时环境，不计入参数数量
+func FromFunc(name string, fn interface{}) (BuiltinFn, error) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func {
+		return BuiltinFn{}, fmt.Errorf("builtin %s: expected a function, got %s", name, ft)
+	}
+
+	returnsError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	numResults := ft.NumOut()
+	if returnsError {
+		numResults--
+	}
+	if numResults > 1 {
+		return BuiltinFn{}, fmt.Errorf("builtin %s: too many return values in %s", name, ft)
+	}
+
+	// withRuntime 表示第一个参数接收 *object.Runtime
+	withRuntime := ft.NumIn() > 0 && ft.In(0) == runtimeType
+	offset := 0
+	if withRuntime {
+		offset = 1
+	}
+
+	numIn := ft.NumIn() - offset
+	variadic := ft.IsVariadic()
+
+	call := func(rt *object.Runtime, args ...object.Object) object.Object {
+		if variadic && len(args) < numIn-1 {
+			return newError("wrong number of arguments. got=%d, want>=%d", len(args), numIn-1)
+		}
+		if !variadic && len(args) != numIn {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), numIn)
+		}
+
+		in := make([]reflect.Value, len(args)+offset)
+		if withRuntime {
+			in[0] = reflect.ValueOf(rt)
+		}
+
+		for i, arg := range args {
+			paramType := paramType(ft, i+offset)
+			v := reflect.New(paramType).Elem()
+			if want, ok := expectedType(paramType); ok && arg.Type() != want {
+				return newError("argument %d to `%s` must be %s, got %s", i+1, name, want, arg.Type())
+			}
+
+			if err := object.ToGo(arg, v.Addr().Interface()); err != nil {
+				return newError("argument %d to `%s`: %s", i+1, name, err)
+			}
+			in[i+offset] = v
+		}
+
+		out := fv.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s: %s", name, err)
+			}
+		}
+
+		if numResults == 0 {
+			return nil
+		}
+
+		result, err := object.FromGo(out[0].Interface())
+		if err != nil {
+			return newError("%s: invalid result: %s", name, err)
+		}
+
+		return result
+	}
+
+	arity := ExactArgs(numIn)
+	if variadic {
+		arity = MinArgs(numIn - 1)
+	}
+
+	return BuiltinFn{
+		Name:      name,
+		Builtin:   &object.Builtin{Fn: call},
+		Arity:     arity,
+		Signature: signature(name, ft, offset, numResults),
+	}, nil
+}
+
+// signature 根据参数和返回值的 Go 类型生成签名，如 split(STRING, STRING) ARRAY
+func signature(name string, ft reflect.Type, offset int, numResults int) string {
+	params := make([]string, 0, ft.NumIn()-offset)
+	for i := offset; i < ft.NumIn(); i++ {
+		param := typeName(paramType(ft, i))
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			param += "..."
+		}
+		params = append(params, param)
+	}
+
+	sig := name + "(" + strings.Join(params, ", ") + ")"
+	if numResults > 0 {
+		sig += " " + typeName(ft.Out(0))
+	}
+
+	return sig
+}
+
+// typeName Go 类型对应的 object 类型名，无法确定时为 OBJECT
+func typeName(t reflect.Type) string {
+	if want, ok := expectedType(t); ok {
+		return string(want)
+	}
+
+	return "OBJECT"
+}
+
+// MustFromFunc 与 FromFunc 相同，fn 不是合法的函数时 panic，用于注册固定的内置函数
+func MustFromFunc(name string, fn interface{}) BuiltinFn {
+	def, err := FromFunc(name, fn)
+	if err != nil {
+		panic(err)
+	}
+
+	return def
+}
+
+// paramType 第 i 个实参对应的形参类型，可变参数取切片的元素类型
+func paramType(ft reflect.Type, i int) reflect.Type {
+	if ft.IsVariadic() && i >= ft.NumIn()-1 {
+		return ft.In(ft.NumIn() - 1).Elem()
+	}
+
+	return ft.In(i)
+}
+
+// expectedType Go 类型对应的 object 类型，指针、interface 和 Object 类型不做检查
+func expectedType(t reflect.Type) (object.Type, bool) {
+	if t.Implements(objectType) {
+		return "", false
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object.INTEGER_OBJ, true
+	case reflect.String:
+		return object.STRING_OBJ, true
+	case reflect.Bool:
+		return object.BOOLEAN_OBJ, true
+	case reflect.Slice, reflect.Array:
+		return object.ARRAY_OBJ, true
+	case reflect.Map, reflect.Struct:
+		return object.HASH_OBJ, true
+	}
+
+	return "", false
+}
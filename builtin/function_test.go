@@ -0,0 +1,103 @@
+package builtin
+
+import (
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"strings"
+	"testing"
+)
+
+func TestFromFunc(t *testing.T) {
+	repeat := MustFromFunc("repeat", func(s string, n int64) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, int(n)), nil
+	})
+	sum := MustFromFunc("sum", func(base int, nums ...int) int {
+		for _, n := range nums {
+			base += n
+		}
+		return base
+	})
+	first := MustFromFunc("first_key", func(h map[string]int, keys []string) *int {
+		for _, k := range keys {
+			if v, ok := h[k]; ok {
+				return &v
+			}
+		}
+		return nil
+	})
+	noop := MustFromFunc("noop", func(o object.Object) {})
+
+	hash, _ := object.FromGo(map[string]int{"b": 2})
+	keys, _ := object.FromGo([]string{"a", "b"})
+	missing, _ := object.FromGo([]string{"a"})
+
+	tests := []struct {
+		fn       BuiltinFn
+		args     []object.Object
+		expected string
+	}{
+		{repeat, []object.Object{&object.String{Value: "ab"}, &object.Integer{Value: 3}}, "ababab"},
+		{repeat, []object.Object{&object.String{Value: "ab"}}, "ERROR: wrong number of arguments. got=1, want=2"},
+		{repeat, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 3}},
+			"ERROR: argument 1 to `repeat` must be STRING, got INTEGER"},
+		{repeat, []object.Object{&object.String{Value: "ab"}, &object.Integer{Value: -1}},
+			"ERROR: repeat: negative count"},
+		{sum, []object.Object{&object.Integer{Value: 1}}, "1"},
+		{sum, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Integer{Value: 3}}, "6"},
+		{sum, []object.Object{}, "ERROR: wrong number of arguments. got=0, want>=1"},
+		{sum, []object.Object{&object.Integer{Value: 1}, object.TRUE},
+			"ERROR: argument 2 to `sum` must be INTEGER, got BOOLEAN"},
+		{first, []object.Object{hash, keys}, "2"},
+		{first, []object.Object{hash, missing}, "null"},
+		{noop, []object.Object{object.NULL}, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		result := tt.fn.Builtin.Fn(&object.Runtime{}, tt.args...)
+		got := "<nil>"
+		if result != nil {
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.fn.Name, tt.expected, got)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		fn       BuiltinFn
+		expected string
+	}{
+		{MustFromFunc("repeat", func(s string, n int64) (string, error) { return s, nil }), "repeat(STRING, INTEGER) STRING"},
+		{MustFromFunc("sum", func(base int, nums ...int) int { return base }), "sum(INTEGER, INTEGER...) INTEGER"},
+		{MustFromFunc("noop", func(o object.Object) {}), "noop(OBJECT)"},
+	}
+
+	for _, tt := range tests {
+		if tt.fn.Signature != tt.expected {
+			t.Errorf("wrong signature. got=%q, want=%q", tt.fn.Signature, tt.expected)
+		}
+	}
+
+	for _, name := range Default().Names() {
+		def, _ := Default().Get(name)
+		if !strings.HasPrefix(def.Signature, name+"(") {
+			t.Errorf("builtin %s has wrong signature %q", name, def.Signature)
+		}
+	}
+}
+
+func TestFromFuncInvalid(t *testing.T) {
+	if _, err := FromFunc("x", 1); err == nil {
+		t.Errorf("expected error for non-function")
+	}
+
+	if _, err := FromFunc("x", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error for too many return values")
+	}
+}
@@ -0,0 +1,175 @@
+package builtin
+
+import (
+	"github.com/Shea11012/interpreter_in_go/object"
+)
+
+// 哈希相关的内置函数，与 push 一样不修改参数，总是返回新的对象
+
+func keysMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "keys",
+		Signature: "keys(HASH) ARRAY",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			hash, err := hashArgument("keys", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+
+			return &object.Array{Elements: elements}
+		}},
+	}
+}
+
+func valuesMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "values",
+		Signature: "values(HASH) ARRAY",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			hash, err := hashArgument("values", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+
+			return &object.Array{Elements: elements}
+		}},
+	}
+}
+
+// entriesMethod 返回 [key, value] 数组组成的数组
+func entriesMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "entries",
+		Signature: "entries(HASH) ARRAY",
+		Arity:     ExactArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			hash, err := hashArgument("entries", args)
+			if err != nil {
+				return err
+			}
+
+			pairs := hash.OrderedPairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+
+			return &object.Array{Elements: elements}
+		}},
+	}
+}
+
+func hasKeyMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "has_key",
+		Signature: "has_key(HASH, OBJECT) BOOLEAN",
+		Arity:     ExactArgs(2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `has_key` must be HASH, got %s", args[0].Type())
+			}
+
+			key, ok := object.HashKeyOf(args[1])
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			if _, ok := hash.Get(key); ok {
+				return object.TRUE
+			}
+
+			return object.FALSE
+		}},
+	}
+}
+
+// deleteMethod 返回删除了 key 的新哈希，其余键的顺序不变，key 不存在时返回内容相同的新哈希
+func deleteMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "delete",
+		Signature: "delete(HASH, OBJECT) HASH",
+		Arity:     ExactArgs(2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+
+			key, ok := object.HashKeyOf(args[1])
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+
+			result := hash.Copy()
+			result.Delete(key)
+
+			return result
+		}},
+	}
+}
+
+// mergeMethod 合并多个哈希，键相同时后面的值覆盖前面的值，键的位置以第一次出现时为准
+func mergeMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "merge",
+		Signature: "merge(HASH, HASH...) HASH",
+		Arity:     MinArgs(1),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+
+			result := object.NewHash(0)
+			for i, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument %d to `merge` must be HASH, got %s", i+1, arg.Type())
+				}
+
+				for _, k := range hash.Keys() {
+					pair, _ := hash.Get(k)
+					result.Set(k, pair)
+				}
+			}
+
+			return result
+		}},
+	}
+}
+
+// hashArgument 检查只接收一个哈希参数的内置函数的参数
+func hashArgument(name string, args []object.Object) (*object.Hash, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+
+	return hash, nil
+}
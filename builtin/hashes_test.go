@@ -0,0 +1,50 @@
+package builtin
+
+import (
+	"github.com/Shea11012/interpreter_in_go/object"
+	"testing"
+)
+
+func TestHashBuiltins(t *testing.T) {
+	hash, _ := object.FromGo(map[string]int{"a": 1, "b": 2})
+	other, _ := object.FromGo(map[string]int{"b": 3, "c": 4})
+	a := &object.String{Value: "a"}
+	x := &object.String{Value: "x"}
+
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"len", []object.Object{hash}, "2"},
+		{"keys", []object.Object{hash}, "[a, b]"},
+		{"values", []object.Object{hash}, "[1, 2]"},
+		{"entries", []object.Object{hash}, "[[a, 1], [b, 2]]"},
+		{"keys", []object.Object{a}, "ERROR: argument to `keys` must be HASH, got STRING"},
+		{"values", []object.Object{}, "ERROR: wrong number of arguments. got=0, want=1"},
+		{"has_key", []object.Object{hash, a}, "true"},
+		{"has_key", []object.Object{hash, x}, "false"},
+		{"has_key", []object.Object{hash, hash}, "ERROR: unusable as hash key: HASH"},
+		{"delete", []object.Object{hash, a}, "{b: 2}"},
+		{"delete", []object.Object{a, a}, "ERROR: argument to `delete` must be HASH, got STRING"},
+		{"merge", []object.Object{hash, other}, "{a: 1, b: 3, c: 4}"},
+		{"merge", []object.Object{hash, a}, "ERROR: argument 2 to `merge` must be HASH, got STRING"},
+	}
+
+	registry := Default()
+	for _, tt := range tests {
+		fn, ok := registry.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("builtin %s not registered", tt.name)
+		}
+
+		result := fn.Fn(&object.Runtime{}, tt.args...)
+		if got := result.Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+
+	if hash.(*object.Hash).Len() != 2 {
+		t.Errorf("delete modified its argument")
+	}
+}
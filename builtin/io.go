@@ -0,0 +1,92 @@
+package builtin
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func printMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "print",
+		Signature: "print(OBJECT...)",
+		Arity:     MinArgs(0),
+		IO:        true,
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			writeObjects(rt.IO.Stdout, args)
+			return nil
+		}},
+	}
+}
+
+func eprintMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "eprint",
+		Signature: "eprint(OBJECT...)",
+		Arity:     MinArgs(0),
+		IO:        true,
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			writeObjects(rt.IO.Stderr, args)
+			return nil
+		}},
+	}
+}
+
+// readLineMethod 读取一行，不包含换行符，输入结束时返回 null
+func readLineMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "read_line",
+		Signature: "read_line() STRING",
+		Arity:     ExactArgs(0),
+		IO:        true,
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			line, err := rt.IO.Stdin.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return newError("read_line: %s", err)
+			}
+
+			if err == io.EOF && line == "" {
+				return nil
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			return &object.String{Value: line}
+		}},
+	}
+}
+
+// readAllMethod 读取剩余的全部输入
+func readAllMethod() BuiltinFn {
+	return BuiltinFn{
+		Name:      "read_all",
+		Signature: "read_all() STRING",
+		Arity:     ExactArgs(0),
+		IO:        true,
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			data, err := ioutil.ReadAll(rt.IO.Stdin)
+			if err != nil {
+				return newError("read_all: %s", err)
+			}
+
+			return &object.String{Value: string(data)}
+		}},
+	}
+}
+
+// writeObjects 依次写入参数，参数之间不加分隔符
+func writeObjects(w io.Writer, args []object.Object) {
+	for _, arg := range args {
+		_, _ = fmt.Fprint(w, arg.Inspect())
+	}
+}
@@ -0,0 +1,118 @@
+package builtin
+
+import (
+	"github.com/Shea11012/interpreter_in_go/object"
+)
+
+// Preset 预设的内置函数集合
+type Preset int
+
+const (
+	PresetAll  Preset = iota // 全部标准内置函数
+	PresetNoIO               // 不包含读写标准输入输出的函数，用于运行不受信任的脚本
+	PresetNone               // 空集合，由宿主自行注册
+)
+
+// Registry 内置函数注册表，由编译器、虚拟机和求值器各自持有
+// 字节码中通过函数名引用内置函数，注册表增删函数不会影响其他函数的编译结果
+type Registry struct {
+	names []string
+	fns   map[string]BuiltinFn
+}
+
+func NewRegistry() *Registry {
+	return &Registry{fns: make(map[string]BuiltinFn)}
+}
+
+// Default 包含全部标准内置函数的注册表，每次调用返回新的实例
+func Default() *Registry {
+	return NewPreset(PresetAll)
+}
+
+// NewPreset 根据预设创建注册表
+func NewPreset(p Preset) *Registry {
+	r := NewRegistry()
+	if p == PresetNone {
+		return r
+	}
+
+	for _, m := range standardMethods {
+		def := m()
+		if p == PresetNoIO && def.IO {
+			continue
+		}
+		r.Register(def)
+	}
+
+	return r
+}
+
+// Register 注册内置函数，同名函数会被替换且保持原有顺序
+func (r *Registry) Register(def BuiltinFn) {
+	if _, ok := r.fns[def.Name]; !ok {
+		r.names = append(r.names, def.Name)
+	}
+
+	r.fns[def.Name] = def
+}
+
+// RegisterFunc 将 Go 函数包装后注册为内置函数，见 FromFunc
+func (r *Registry) RegisterFunc(name string, fn interface{}) error {
+	def, err := FromFunc(name, fn)
+	if err != nil {
+		return err
+	}
+
+	r.Register(def)
+	return nil
+}
+
+// Remove 移除内置函数，不存在时忽略
+func (r *Registry) Remove(names ...string) {
+	for _, name := range names {
+		if _, ok := r.fns[name]; !ok {
+			continue
+		}
+
+		delete(r.fns, name)
+		for i, n := range r.names {
+			if n == name {
+				r.names = append(r.names[:i], r.names[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Lookup 根据名称查找内置函数
+func (r *Registry) Lookup(name string) (*object.Builtin, bool) {
+	def, ok := r.fns[name]
+	if !ok {
+		return nil, false
+	}
+
+	return def.Builtin, true
+}
+
+// Get 根据名称获取内置函数的完整定义
+func (r *Registry) Get(name string) (BuiltinFn, bool) {
+	def, ok := r.fns[name]
+	return def, ok
+}
+
+// Names 按注册顺序返回所有内置函数名
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
+
+// Clone 复制注册表，对副本的修改不会影响原注册表
+func (r *Registry) Clone() *Registry {
+	c := NewRegistry()
+	for _, name := range r.names {
+		c.Register(r.fns[name])
+	}
+
+	return c
+}
@@ -0,0 +1,98 @@
+package builtin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register(MustFromFunc("a", func() int { return 1 }))
+	r.Register(MustFromFunc("b", func() int { return 2 }))
+	r.Register(MustFromFunc("c", func() int { return 3 }))
+	r.Register(MustFromFunc("a", func() int { return 4 }))
+
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("wrong names. got=%v", names)
+	}
+
+	fn, ok := r.Lookup("a")
+	if !ok || fn.Fn(nil).Inspect() != "4" {
+		t.Fatalf("replaced builtin not found")
+	}
+
+	clone := r.Clone()
+	r.Remove("b", "missing")
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"a", "c"}) {
+		t.Fatalf("wrong names after remove. got=%v", names)
+	}
+
+	if _, ok := r.Lookup("b"); ok {
+		t.Fatalf("removed builtin still found")
+	}
+
+	if _, ok := clone.Lookup("b"); !ok {
+		t.Fatalf("clone was modified")
+	}
+}
+
+func TestPresets(t *testing.T) {
+	all := NewPreset(PresetAll)
+	if _, ok := all.Lookup("puts"); !ok {
+		t.Errorf("PresetAll should contain puts")
+	}
+
+	noIO := NewPreset(PresetNoIO)
+	if _, ok := noIO.Lookup("puts"); ok {
+		t.Errorf("PresetNoIO should not contain puts")
+	}
+	if _, ok := noIO.Lookup("len"); !ok {
+		t.Errorf("PresetNoIO should contain len")
+	}
+
+	if names := NewPreset(PresetNone).Names(); len(names) != 0 {
+		t.Errorf("PresetNone should be empty, got=%v", names)
+	}
+
+	if Default() == Default() {
+		t.Errorf("Default should return a new registry")
+	}
+}
+
+func TestArity(t *testing.T) {
+	r := Default()
+	for _, name := range r.Names() {
+		def, _ := r.Get(name)
+		if def.Arity == nil {
+			t.Errorf("builtin %s has no arity", name)
+		}
+	}
+
+	tests := []struct {
+		arity    *Arity
+		accepts  []int
+		rejects  []int
+		expected string
+	}{
+		{ExactArgs(1), []int{1}, []int{0, 2}, "1"},
+		{MinArgs(1), []int{1, 5}, []int{0}, ">=1"},
+		{RangeArgs(2, 3), []int{2, 3}, []int{1, 4}, "2 or 3"},
+		{RangeArgs(1, 3), []int{1, 2, 3}, []int{0, 4}, "1..3"},
+	}
+
+	for _, tt := range tests {
+		for _, n := range tt.accepts {
+			if !tt.arity.Accepts(n) {
+				t.Errorf("arity %s should accept %d", tt.arity, n)
+			}
+		}
+		for _, n := range tt.rejects {
+			if tt.arity.Accepts(n) {
+				t.Errorf("arity %s should reject %d", tt.arity, n)
+			}
+		}
+		if tt.arity.String() != tt.expected {
+			t.Errorf("wrong string. got=%q, want=%q", tt.arity.String(), tt.expected)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package builtin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// 字符串相关的内置函数，下标和长度均以 unicode 字符(rune)为单位
+
+func splitMethod() BuiltinFn {
+	return MustFromFunc("split", func(s string, sep string) []string {
+		return strings.Split(s, sep)
+	})
+}
+
+func joinMethod() BuiltinFn {
+	return MustFromFunc("join", func(elements []string, sep string) string {
+		return strings.Join(elements, sep)
+	})
+}
+
+func trimMethod() BuiltinFn {
+	return withArity(MustFromFunc("trim", func(s string, cutset ...string) (string, error) {
+		if len(cutset) == 0 {
+			return strings.TrimSpace(s), nil
+		}
+
+		set, err := singleCutset(cutset)
+		return strings.Trim(s, set), err
+	}), RangeArgs(1, 2))
+}
+
+func trimLeftMethod() BuiltinFn {
+	return withArity(MustFromFunc("trim_left", func(s string, cutset ...string) (string, error) {
+		if len(cutset) == 0 {
+			return strings.TrimLeftFunc(s, isSpace), nil
+		}
+
+		set, err := singleCutset(cutset)
+		return strings.TrimLeft(s, set), err
+	}), RangeArgs(1, 2))
+}
+
+func trimRightMethod() BuiltinFn {
+	return withArity(MustFromFunc("trim_right", func(s string, cutset ...string) (string, error) {
+		if len(cutset) == 0 {
+			return strings.TrimRightFunc(s, isSpace), nil
+		}
+
+		set, err := singleCutset(cutset)
+		return strings.TrimRight(s, set), err
+	}), RangeArgs(1, 2))
+}
+
+func upperMethod() BuiltinFn {
+	return MustFromFunc("upper", strings.ToUpper)
+}
+
+func lowerMethod() BuiltinFn {
+	return MustFromFunc("lower", strings.ToLower)
+}
+
+func containsMethod() BuiltinFn {
+	return MustFromFunc("contains", strings.Contains)
+}
+
+func startsWithMethod() BuiltinFn {
+	return MustFromFunc("starts_with", strings.HasPrefix)
+}
+
+func endsWithMethod() BuiltinFn {
+	return MustFromFunc("ends_with", strings.HasSuffix)
+}
+
+// indexOfMethod 返回 sub 第一次出现的字符下标，不存在时返回 -1
+func indexOfMethod() BuiltinFn {
+	return MustFromFunc("index_of", func(s string, sub string) int {
+		idx := strings.Index(s, sub)
+		if idx < 0 {
+			return -1
+		}
+
+		return utf8.RuneCountInString(s[:idx])
+	})
+}
+
+func replaceMethod() BuiltinFn {
+	return MustFromFunc("replace", func(s string, old string, new string) string {
+		return strings.ReplaceAll(s, old, new)
+	})
+}
+
+// repeatMethod 结果的长度会在分配内存之前检查，避免超过 MaxCollectionSize
+func repeatMethod() BuiltinFn {
+	return MustFromFunc("repeat", func(rt *object.Runtime, s string, count int64) (string, error) {
+		if count < 0 {
+			return "", errors.New("negative repeat count")
+		}
+
+		if len(s) > 0 && count > int64(maxStringSize/len(s)) {
+			return "", errors.New("result too large")
+		}
+
+		if err := rt.CheckSize(len(s) * int(count)); err != nil {
+			return "", err
+		}
+
+		return strings.Repeat(s, int(count)), nil
+	})
+}
+
+// substrMethod substr(s, start) 或 substr(s, start, length)，超出范围的部分会被截断
+func substrMethod() BuiltinFn {
+	return withArity(MustFromFunc("substr", func(s string, start int64, length ...int64) (string, error) {
+		if len(length) > 1 {
+			return "", fmt.Errorf("wrong number of arguments. got=%d, want=2 or 3", len(length)+2)
+		}
+
+		runes := []rune(s)
+		n := int64(len(runes))
+		if start < 0 {
+			return "", errors.New("negative start")
+		}
+		if start > n {
+			start = n
+		}
+
+		end := n
+		if len(length) == 1 {
+			if length[0] < 0 {
+				return "", errors.New("negative length")
+			}
+			if length[0] < n-start {
+				end = start + length[0]
+			}
+		}
+
+		return string(runes[start:end]), nil
+	}), RangeArgs(2, 3))
+}
+
+// withArity 可变参数函数实际接受的参数有上限时，用 arity 替换 FromFunc 推断出的参数个数
+func withArity(def BuiltinFn, arity *Arity) BuiltinFn {
+	def.Arity = arity
+	return def
+}
+
+func charsMethod() BuiltinFn {
+	return MustFromFunc("chars", func(s string) []string {
+		chars := make([]string, 0, utf8.RuneCountInString(s))
+		for _, r := range s {
+			chars = append(chars, string(r))
+		}
+
+		return chars
+	})
+}
+
+func formatMethod() BuiltinFn {
+	return formatBuiltin("format")
+}
+
+func sprintfMethod() BuiltinFn {
+	return formatBuiltin("sprintf")
+}
+
+// formatBuiltin 支持 %d(INTEGER)、%s(STRING)、%v(任意值) 和 %%
+func formatBuiltin(name string) BuiltinFn {
+	return BuiltinFn{
+		Name:      name,
+		Arity:     MinArgs(1),
+		Signature: name + "(STRING, OBJECT...) STRING",
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+
+			format, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument 1 to `%s` must be STRING, got %s", name, args[0].Type())
+			}
+
+			result, err := formatString(format.Value, args[1:])
+			if err != nil {
+				return newError("%s: %s", name, err)
+			}
+
+			return &object.String{Value: result}
+		}},
+	}
+}
+
+func formatString(format string, args []object.Object) (string, error) {
+	var out strings.Builder
+	argIndex := 0
+
+	for i := 0; i < len(format); i++ {
+		ch := format[i]
+		if ch != '%' {
+			out.WriteByte(ch)
+			continue
+		}
+
+		i++
+		if i >= len(format) {
+			return "", errors.New("format ends with %")
+		}
+
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+
+		if argIndex >= len(args) {
+			return "", fmt.Errorf("missing argument for %%%c", verb)
+		}
+		arg := args[argIndex]
+		argIndex++
+
+		switch verb {
+		case 'd':
+			if arg.Type() != object.INTEGER_OBJ {
+				return "", fmt.Errorf("%%d expects INTEGER, got %s", arg.Type())
+			}
+			out.WriteString(arg.Inspect())
+		case 's':
+			if arg.Type() != object.STRING_OBJ {
+				return "", fmt.Errorf("%%s expects STRING, got %s", arg.Type())
+			}
+			out.WriteString(arg.Inspect())
+		case 'v':
+			out.WriteString(arg.Inspect())
+		default:
+			return "", fmt.Errorf("unknown verb %%%c", verb)
+		}
+	}
+
+	if argIndex != len(args) {
+		return "", fmt.Errorf("too many arguments. got=%d, want=%d", len(args), argIndex)
+	}
+
+	return out.String(), nil
+}
+
+// maxStringSize 字符串长度的硬上限，防止计算长度时整数溢出
+const maxStringSize = 1 << 31
+
+func singleCutset(cutset []string) (string, error) {
+	if len(cutset) > 1 {
+		return "", fmt.Errorf("wrong number of arguments. got=%d, want=1 or 2", len(cutset)+1)
+	}
+
+	return cutset[0], nil
+}
+
+func isSpace(r rune) bool {
+	return strings.TrimSpace(string(r)) == ""
+}
@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"testing"
+)
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []interface{}
+		expected string
+	}{
+		{"len", []interface{}{"héllo, 世界"}, "9"},
+		{"split", []interface{}{"a,b,,c", ","}, "[a, b, , c]"},
+		{"split", []interface{}{"世界", ""}, "[世, 界]"},
+		{"split", []interface{}{1, ","}, "ERROR: argument 1 to `split` must be STRING, got INTEGER"},
+		{"join", []interface{}{[]string{"a", "b", "c"}, "-"}, "a-b-c"},
+		{"join", []interface{}{[]interface{}{"a", 1}, "-"}, "ERROR: argument 1 to `join`: [1]: cannot convert INTEGER to string"},
+		{"trim", []interface{}{"\t hi \n"}, "hi"},
+		{"trim", []interface{}{"xxhixx", "x"}, "hi"},
+		{"trim", []interface{}{"hi", "x", "y"}, "ERROR: trim: wrong number of arguments. got=3, want=1 or 2"},
+		{"trim_left", []interface{}{"  hi  "}, "hi  "},
+		{"trim_right", []interface{}{"  hi  "}, "  hi"},
+		{"trim_right", []interface{}{"hi!!", "!"}, "hi"},
+		{"upper", []interface{}{"héllo"}, "HÉLLO"},
+		{"lower", []interface{}{"ÀB"}, "àb"},
+		{"contains", []interface{}{"monkey", "key"}, "true"},
+		{"starts_with", []interface{}{"monkey", "mon"}, "true"},
+		{"ends_with", []interface{}{"monkey", "mon"}, "false"},
+		{"index_of", []interface{}{"世界 hello", "hello"}, "3"},
+		{"index_of", []interface{}{"monkey", "x"}, "-1"},
+		{"replace", []interface{}{"a-b-c", "-", "+"}, "a+b+c"},
+		{"repeat", []interface{}{"ab", 3}, "ababab"},
+		{"repeat", []interface{}{"ab", -1}, "ERROR: repeat: negative repeat count"},
+		{"repeat", []interface{}{"ab", 1 << 62}, "ERROR: repeat: result too large"},
+		{"substr", []interface{}{"世界 hello", 1, 3}, "界 h"},
+		{"substr", []interface{}{"hello", 2}, "llo"},
+		{"substr", []interface{}{"hello", 3, 100}, "lo"},
+		{"substr", []interface{}{"hello", 10}, ""},
+		{"substr", []interface{}{"hello", -1}, "ERROR: substr: negative start"},
+		{"chars", []interface{}{"a世"}, "[a, 世]"},
+		{"format", []interface{}{"%s is %d, %v%%", "x", 1, []int{1}}, "x is 1, [1]%"},
+		{"sprintf", []interface{}{"%v", true}, "true"},
+		{"format", []interface{}{"%d", "x"}, "ERROR: format: %d expects INTEGER, got STRING"},
+		{"format", []interface{}{"%s %s", "x"}, "ERROR: format: missing argument for %s"},
+		{"format", []interface{}{"%s", "x", "y"}, "ERROR: format: too many arguments. got=2, want=1"},
+		{"format", []interface{}{"%q", "x"}, "ERROR: format: unknown verb %q"},
+		{"format", []interface{}{"100%"}, "ERROR: format: format ends with %"},
+	}
+
+	registry := Default()
+	for _, tt := range tests {
+		fn, ok := registry.Lookup(tt.name)
+		if !ok {
+			t.Fatalf("builtin %s not registered", tt.name)
+		}
+
+		args := make([]object.Object, len(tt.args))
+		for i, arg := range tt.args {
+			obj, err := object.FromGo(arg)
+			if err != nil {
+				t.Fatalf("FromGo(%#v) returned error: %s", arg, err)
+			}
+			args[i] = obj
+		}
+
+		result := fn.Fn(&object.Runtime{}, args...)
+		if got := result.Inspect(); got != tt.expected {
+			t.Errorf("%s%v wrong result. want=%q, got=%q", tt.name, tt.args, tt.expected, got)
+		}
+	}
+}
+
+func TestRepeatSizeLimit(t *testing.T) {
+	fn, _ := Default().Lookup("repeat")
+	rt := &object.Runtime{SizeLimit: func(size int) error {
+		if size > 10 {
+			return errors.New("too large")
+		}
+		return nil
+	}}
+
+	result := fn.Fn(rt, &object.String{Value: "ab"}, &object.Integer{Value: 6})
+	if got := result.Inspect(); got != "ERROR: repeat: too large" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+}
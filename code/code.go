@@ -19,6 +19,20 @@ func (ins Instructions) String() string {
 			continue
 		}
 
+		if Opcode(ins[i]) == OpWide && i+1 < len(ins) {
+			wideDef, err := Lookup(ins[i+1])
+			if err != nil {
+				_, _ = fmt.Fprintf(&out, "ERROR: %s\n", err)
+				break
+			}
+
+			operands, read := ReadWideOperands(wideDef, ins[i+2:])
+			_, _ = fmt.Fprintf(&out, "%04d %s %s\n", i, def.Name, ins.fmtInstruction(wideDef, operands))
+
+			i += 2 + read
+			continue
+		}
+
 		operands, read := ReadOperands(def, ins[i+1:])
 
 		_, _ = fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
@@ -80,6 +94,9 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpSlice
+	OpWide // 前缀指令，紧跟着的指令的操作数宽度加倍
+	OpMod
 )
 
 type Definition struct {
@@ -114,10 +131,13 @@ var definitions = map[Opcode]*Definition{
 	OpReturn:         {"OpReturn", []int{}},
 	OpGetLocal:       {"OpGetLocal", []int{1}},
 	OpSetLocal:       {"OpSetLocal", []int{1}},
-	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}}, // 操作数为常量池中内置函数名的索引
 	OpClosure:        {"OpClosure", []int{2, 1}}, // 2表示一个常量，这样可以使得函数可以转换为闭包，1表示有多少个变量在栈中
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpSlice:          {"OpSlice", []int{}}, // 栈顶依次为 high、low、被切片的对象，省略的边界为 null
+	OpWide:           {"OpWide", []int{}},  // 操作数超出原来的宽度时使用，1 字节变为 2 字节，2 字节变为 4 字节
+	OpMod:            {"OpMod", []int{}},
 }
 
 // Lookup 查询opcode对应的definition
@@ -136,9 +156,80 @@ func Make(op Opcode, operands ...int) []byte {
 		return []byte{}
 	}
 
+	return makeInstruction(op, def.OperandWidths, operands)
+}
+
+// MakeWide 生成带有 OpWide 前缀的指令
+func MakeWide(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	return append([]byte{byte(OpWide)}, makeInstruction(op, wideWidths(def), operands)...)
+}
+
+// Encode 操作数超出原来的宽度时生成带有 OpWide 前缀的指令，否则和 Make 相同
+// 调用前需要用 FitsWide 确认操作数没有超出加宽后的宽度
+func Encode(op Opcode, operands ...int) []byte {
+	if Fits(op, operands...) {
+		return Make(op, operands...)
+	}
+
+	return MakeWide(op, operands...)
+}
+
+// Fits 判断操作数能否使用原来的宽度编码
+func Fits(op Opcode, operands ...int) bool {
+	for i, o := range operands {
+		if o < 0 || o > MaxOperand(op, i, false) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FitsWide 判断操作数能否使用 OpWide 加宽后的宽度编码
+func FitsWide(op Opcode, operands ...int) bool {
+	for i, o := range operands {
+		if o < 0 || o > MaxOperand(op, i, true) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MaxOperand 返回 op 的第 i 个操作数可以表示的最大值，wide 表示是否带有 OpWide 前缀
+func MaxOperand(op Opcode, i int, wide bool) int {
+	def, ok := definitions[op]
+	if !ok || i >= len(def.OperandWidths) {
+		return 0
+	}
+
+	width := def.OperandWidths[i]
+	if wide {
+		width *= 2
+	}
+
+	return 1<<(8*uint(width)) - 1
+}
+
+// wideWidths 返回 OpWide 前缀下每个操作数的宽度
+func wideWidths(def *Definition) []int {
+	widths := make([]int, len(def.OperandWidths))
+	for i, w := range def.OperandWidths {
+		widths[i] = w * 2
+	}
+
+	return widths
+}
+
+func makeInstruction(op Opcode, widths []int, operands []int) []byte {
 	// 计算对应的opcode结果指令长度，长度从1开始是需要在第一位存对应的opcode
 	instructionLen := 1
-	for _, w := range def.OperandWidths {
+	for _, w := range widths {
 		instructionLen += w
 	}
 
@@ -147,12 +238,14 @@ func Make(op Opcode, operands ...int) []byte {
 
 	offset := 1
 	for i, o := range operands {
-		width := def.OperandWidths[i]
+		width := widths[i]
 		switch width {
 		case 1:
 			instruction[offset] = byte(o)
 		case 2:
 			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
 		}
 		offset += width
 	}
@@ -161,23 +254,40 @@ func Make(op Opcode, operands ...int) []byte {
 }
 
 func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
-	operands := make([]int, len(def.OperandWidths))
-	offset := 0
+	return readOperands(def.OperandWidths, ins)
+}
 
-	for i, width := range def.OperandWidths {
-		switch width {
-		case 1:
-			operands[i] = int(ReadUint8(ins[offset:]))
-		case 2:
-			operands[i] = int(ReadUint16(ins[offset:]))
-		}
+// ReadWideOperands 按 OpWide 前缀下的宽度读取操作数
+func ReadWideOperands(def *Definition, ins Instructions) ([]int, int) {
+	return readOperands(wideWidths(def), ins)
+}
 
+func readOperands(widths []int, ins Instructions) ([]int, int) {
+	operands := make([]int, len(widths))
+	offset := 0
+
+	for i, width := range widths {
+		operands[i] = ReadOperand(ins[offset:], width)
 		offset += width
 	}
 
 	return operands, offset
 }
 
+// ReadOperand 读取一个宽度为 width 字节的操作数
+func ReadOperand(ins Instructions, width int) int {
+	switch width {
+	case 1:
+		return int(ReadUint8(ins))
+	case 2:
+		return int(ReadUint16(ins))
+	case 4:
+		return int(ReadUint32(ins))
+	}
+
+	return 0
+}
+
 func ReadUint8(ins Instructions) uint8 {
 	return ins[0]
 }
@@ -185,3 +295,8 @@ func ReadUint8(ins Instructions) uint8 {
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
+
+// ReadUint32 读取 OpWide 前缀下 4 字节的操作数
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
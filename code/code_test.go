@@ -1,6 +1,7 @@
 package code
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -87,3 +88,183 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeAssemble(t *testing.T) {
+	ins := concatInstructions(
+		Make(OpConstant, 1),       // 0000
+		Make(OpJumpNotTruthy, 10), // 0003
+		Make(OpNull),              // 0006
+		Make(OpGetLocal, 2),       // 0007
+		Make(OpPop),               // 0009
+		Make(OpClosure, 3, 1),     // 0010
+		Make(OpJump, 3),           // 0014
+	)
+
+	decoded, err := Decode(ins)
+	if err != nil {
+		t.Fatalf("Decode returned error: %s", err)
+	}
+
+	if len(decoded) != 7 {
+		t.Fatalf("wrong number of instructions. want=7, got=%d", len(decoded))
+	}
+
+	if decoded[5].Op != OpClosure || decoded[5].Offset != 10 || decoded[5].Operands[0] != 3 || decoded[5].Operands[1] != 1 {
+		t.Errorf("wrong decoded instruction: %+v", decoded[5])
+	}
+
+	if assembled := Assemble(decoded); assembled.String() != ins.String() {
+		t.Errorf("round trip mismatch.\nwant=\n%s\ngot=\n%s", ins, assembled)
+	}
+
+	// 删除 0006 和 0007 处的指令后，跳转目标需要重新计算
+	reduced := append(append([]Instruction{}, decoded[:2]...), decoded[4:]...)
+	reduced[1].Operands = []int{7}
+	expected := concatInstructions(
+		Make(OpConstant, 1),      // 0000
+		Make(OpJumpNotTruthy, 6), // 0003
+		Make(OpPop),              // 0006
+		Make(OpClosure, 3, 1),    // 0007
+		Make(OpJump, 3),          // 0011
+	)
+	if assembled := Assemble(reduced); assembled.String() != expected.String() {
+		t.Errorf("wrong relocation.\nwant=\n%s\ngot=\n%s", expected, assembled)
+	}
+
+	if _, err := Decode(Instructions{byte(OpConstant), 0}); err == nil {
+		t.Errorf("expected error for truncated instruction")
+	}
+}
+
+func TestWideInstructions(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpGetLocal, []int{256}, []byte{byte(OpWide), byte(OpGetLocal), 1, 0}},
+		{OpConstant, []int{65536}, []byte{byte(OpWide), byte(OpConstant), 0, 1, 0, 0}},
+		{OpClosure, []int{1, 300}, []byte{byte(OpWide), byte(OpClosure), 0, 0, 0, 1, 1, 44}},
+	}
+
+	for _, tt := range tests {
+		instruction := Encode(tt.op, tt.operands...)
+		if string(instruction) != string(tt.expected) {
+			t.Errorf("wrong encoding for %v. want=%v, got=%v", tt.operands, tt.expected, instruction)
+		}
+
+		decoded, err := Decode(instruction)
+		if err != nil {
+			t.Fatalf("Decode returned error: %s", err)
+		}
+
+		if len(decoded) != 1 || decoded[0].Op != tt.op || decoded[0].Offset != 0 {
+			t.Fatalf("wrong decoded instruction: %+v", decoded)
+		}
+
+		for i, want := range tt.operands {
+			if decoded[0].Operands[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, decoded[0].Operands[i])
+			}
+		}
+	}
+
+	ins := concatInstructions(MakeWide(OpGetLocal, 256), Make(OpPop))
+	expected := "0000 OpWide OpGetLocal 256\n0004 OpPop\n"
+	if ins.String() != expected {
+		t.Errorf("wide instruction wrongly formatted.\nwant=%q\ngot =%q", expected, ins.String())
+	}
+
+	if FitsWide(OpGetLocal, 65536) {
+		t.Errorf("OpGetLocal 65536 should not fit in wide operands")
+	}
+
+	if _, err := Decode(Instructions{byte(OpWide)}); err == nil {
+		t.Errorf("expected error for truncated wide instruction")
+	}
+}
+
+func TestAssembleLongJumps(t *testing.T) {
+	// 第二条跳转的目标超出 2 字节需要加宽，加宽后第一条跳转的目标也超出了 2 字节
+	instructions := []Instruction{
+		{Op: OpJump, Operands: []int{65533}, Offset: 0},
+		{Op: OpJumpNotTruthy, Operands: []int{65536}, Offset: 3},
+	}
+	for offset := 6; offset < 65536; offset++ {
+		instructions = append(instructions, Instruction{Op: OpNull, Offset: offset})
+	}
+	instructions = append(instructions, Instruction{Op: OpPop, Offset: 65536})
+
+	assembled := Assemble(instructions)
+	decoded, err := Decode(assembled)
+	if err != nil {
+		t.Fatalf("Decode returned error: %s", err)
+	}
+
+	if assembled[0] != byte(OpWide) || assembled[6] != byte(OpWide) {
+		t.Fatalf("expected both jumps to be wide, got %v", []byte(assembled[:12]))
+	}
+
+	expected := []struct {
+		op     Opcode
+		target int
+	}{
+		{OpJump, 65539},
+		{OpJumpNotTruthy, 65542},
+	}
+	for i, want := range expected {
+		if decoded[i].Op != want.op || decoded[i].Operands[0] != want.target {
+			t.Errorf("wrong jump. want=%+v, got=%+v", want, decoded[i])
+		}
+	}
+
+	last := decoded[len(decoded)-1]
+	if last.Op != OpPop || last.Offset != 65542 {
+		t.Errorf("wrong last instruction: %+v", last)
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	var lines LineTable
+	lines = lines.Add(0, 1)
+	lines = lines.Add(3, 1)
+	lines = lines.Add(4, 0)
+	lines = lines.Add(6, 2)
+	lines = lines.Add(9, 4)
+	lines = lines.Add(9, 3)
+
+	expected := LineTable{{0, 1}, {6, 2}, {9, 3}}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("wrong line table. got=%v, want=%v", lines, expected)
+	}
+
+	for offset, want := range map[int]int{0: 1, 5: 1, 6: 2, 8: 2, 9: 3, 100: 3} {
+		if got := lines.Line(offset); got != want {
+			t.Errorf("wrong line for offset %d. got=%d, want=%d", offset, got, want)
+		}
+	}
+
+	if got := lines.Truncate(6); !reflect.DeepEqual(got, LineTable{{0, 1}}) {
+		t.Errorf("wrong truncated table. got=%v", got)
+	}
+
+	// 第一条指令加宽之后，后面的指令位置都变大了
+	instructions := []Instruction{
+		{Op: OpConstant, Operands: []int{70000}, Offset: 0},
+		{Op: OpPop, Offset: 3},
+		{Op: OpNull, Offset: 4},
+	}
+	_, relocated := AssembleWithLines(instructions, LineTable{{0, 1}, {4, 2}})
+	if want := (LineTable{{0, 1}, {7, 2}}); !reflect.DeepEqual(relocated, want) {
+		t.Errorf("wrong relocated table. got=%v, want=%v", relocated, want)
+	}
+}
+
+func concatInstructions(instructions ...[]byte) Instructions {
+	var out Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
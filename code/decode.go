@@ -0,0 +1,161 @@
+package code
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Instruction 解码后的一条指令，用于在编译之后改写指令序列
+type Instruction struct {
+	Op       Opcode
+	Operands []int
+	Offset   int // 在原指令序列中的位置，跳转指令的操作数也是原指令序列中的位置
+}
+
+// String 返回指令的文本形式，如 OpClosure 3 1，带有 OpWide 前缀的指令也按原操作码显示
+func (ins Instruction) String() string {
+	def, err := Lookup(byte(ins.Op))
+	if err != nil {
+		return fmt.Sprintf("ERROR: %s", err)
+	}
+
+	return Instructions(nil).fmtInstruction(def, ins.Operands)
+}
+
+// IsJump 判断 op 的操作数是否为跳转的目标位置
+func IsJump(op Opcode) bool {
+	return op == OpJump || op == OpJumpNotTruthy
+}
+
+// Decode 将指令序列解码为 Instruction 列表，OpWide 前缀和它后面的指令解码为一条指令
+func Decode(ins Instructions) ([]Instruction, error) {
+	var result []Instruction
+
+	i := 0
+	for i < len(ins) {
+		instruction, size, err := DecodeAt(ins, i)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, instruction)
+		i += size
+	}
+
+	return result, nil
+}
+
+// DecodeAt 解码 offset 处的一条指令，返回指令和它占用的字节数，包括 OpWide 前缀
+func DecodeAt(ins Instructions, offset int) (Instruction, int, error) {
+	i := offset
+	wide := Opcode(ins[i]) == OpWide
+	if wide {
+		i++
+		if i == len(ins) {
+			return Instruction{}, 0, fmt.Errorf("truncated instruction OpWide at %d", offset)
+		}
+	}
+
+	def, err := Lookup(ins[i])
+	if err != nil {
+		return Instruction{}, 0, err
+	}
+
+	widths := def.OperandWidths
+	if wide {
+		widths = wideWidths(def)
+	}
+
+	width := 0
+	for _, w := range widths {
+		width += w
+	}
+	if i+1+width > len(ins) {
+		return Instruction{}, 0, fmt.Errorf("truncated instruction %s at %d", def.Name, offset)
+	}
+
+	operands, read := readOperands(widths, ins[i+1:])
+	return Instruction{Op: Opcode(ins[i]), Operands: operands, Offset: offset}, i + 1 + read - offset, nil
+}
+
+// Assemble 将 Instruction 列表重新编码，跳转目标按原位置换算到新的位置
+// 目标指令已经被删除时，跳转到原位置之后第一条保留下来的指令
+// 操作数超出原来的宽度时自动加上 OpWide 前缀，调用前需要保证操作数没有超出加宽后的宽度
+func Assemble(instructions []Instruction) Instructions {
+	result, _ := assemble(instructions)
+	return result
+}
+
+// assemble 重新编码指令，同时返回每条指令在新指令序列中的位置
+func assemble(instructions []Instruction) (Instructions, []int) {
+	wide := make([]bool, len(instructions))
+	for i, ins := range instructions {
+		wide[i] = !IsJump(ins.Op) && !Fits(ins.Op, ins.Operands...)
+	}
+
+	positions := make([]int, len(instructions))
+	end := 0
+
+	// relocate 返回原位置 target 在新指令序列中的位置
+	relocate := func(target int) int {
+		i := sort.Search(len(instructions), func(i int) bool {
+			return instructions[i].Offset >= target
+		})
+		if i == len(instructions) {
+			return end
+		}
+		return positions[i]
+	}
+
+	// 跳转指令加宽后后面的指令位置会变大，可能导致其它跳转也需要加宽，直到不再变化为止
+	for {
+		end = 0
+		for i, ins := range instructions {
+			positions[i] = end
+			end += instructionSize(ins.Op, wide[i])
+		}
+
+		changed := false
+		for i, ins := range instructions {
+			if IsJump(ins.Op) && !wide[i] && !Fits(ins.Op, relocate(ins.Operands[0])) {
+				wide[i] = true
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	result := make(Instructions, 0, end)
+	for i, ins := range instructions {
+		operands := ins.Operands
+		if IsJump(ins.Op) {
+			operands = []int{relocate(ins.Operands[0])}
+		}
+
+		if wide[i] {
+			result = append(result, MakeWide(ins.Op, operands...)...)
+		} else {
+			result = append(result, Make(ins.Op, operands...)...)
+		}
+	}
+
+	return result, positions
+}
+
+// instructionSize 返回指令编码后的字节数，wide 表示是否带有 OpWide 前缀
+func instructionSize(op Opcode, wide bool) int {
+	width := 0
+	for _, w := range definitions[op].OperandWidths {
+		width += w
+	}
+
+	if wide {
+		// OpWide 前缀占一个字节，操作数宽度加倍
+		return 2 + width*2
+	}
+
+	return 1 + width
+}
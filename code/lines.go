@@ -0,0 +1,66 @@
+package code
+
+import (
+	"sort"
+)
+
+// LineEntry 从 Offset 开始的指令由源码的第 Line 行编译而来
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// LineTable 指令位置到源码行号的映射，按 Offset 递增排列，每一项覆盖到下一项的 Offset 之前
+type LineTable []LineEntry
+
+// Line 返回 offset 处的指令对应的行号，offset 可以位于指令的操作数中，没有行号信息时返回 0
+func (t LineTable) Line(offset int) int {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Offset > offset
+	})
+	if i == 0 {
+		return 0
+	}
+
+	return t[i-1].Line
+}
+
+// Add 记录从 offset 开始的指令对应第 line 行，offset 不能小于已有的最后一项
+// 与最后一项的行号相同时不增加新的项，line 为 0 时沿用前面的行号
+func (t LineTable) Add(offset int, line int) LineTable {
+	if line == 0 {
+		return t
+	}
+
+	n := len(t)
+	if n > 0 && t[n-1].Offset == offset {
+		t = t[:n-1]
+		n--
+	}
+	if n > 0 && t[n-1].Line == line {
+		return t
+	}
+
+	return append(t, LineEntry{Offset: offset, Line: line})
+}
+
+// Truncate 删除 offset 及之后的指令对应的项，用于删除最后几条指令之后
+func (t LineTable) Truncate(offset int) LineTable {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Offset >= offset
+	})
+
+	return t[:i]
+}
+
+// AssembleWithLines 与 Assemble 相同，同时把原指令序列的行号表 lines 换算到新的指令序列
+func AssembleWithLines(instructions []Instruction, lines LineTable) (Instructions, LineTable) {
+	result, positions := assemble(instructions)
+
+	var relocated LineTable
+	for i, ins := range instructions {
+		relocated = relocated.Add(positions[i], lines.Line(ins.Offset))
+	}
+
+	return result, relocated
+}
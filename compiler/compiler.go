@@ -6,12 +6,34 @@ import (
 	"github.com/Shea11012/interpreter_in_go/builtin"
 	"github.com/Shea11012/interpreter_in_go/code"
 	"github.com/Shea11012/interpreter_in_go/object"
-	"sort"
 )
 
+// GlobalsSize 全局变量数量的上限，虚拟机按这个大小分配全局变量
+const GlobalsSize = 65536
+
+// operandNames 指令操作数的含义，操作数超出上限时用于生成错误信息
+var operandNames = map[code.Opcode][]string{
+	code.OpConstant:      {"constants"},
+	code.OpJump:          {"instructions"},
+	code.OpJumpNotTruthy: {"instructions"},
+	code.OpGetGlobal:     {"global bindings"},
+	code.OpSetGlobal:     {"global bindings"},
+	code.OpArray:         {"array elements"},
+	code.OpHash:          {"hash elements"},
+	code.OpCall:          {"call arguments"},
+	code.OpGetLocal:      {"local bindings"},
+	code.OpSetLocal:      {"local bindings"},
+	code.OpGetBuiltin:    {"constants"},
+	code.OpClosure:       {"constants", "free variables"},
+	code.OpGetFree:       {"free variables"},
+}
+
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+
+	Lines       code.LineTable // 主程序指令对应的源码行
+	GlobalNames []string       // 全局变量名，下标为全局变量的索引
 }
 
 type EmittedInstruction struct {
@@ -23,17 +45,32 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	// longJumps 目标超出 2 字节的跳转指令位置及其真实目标，离开作用域时改写为带 OpWide 前缀的跳转
+	longJumps map[int]int
+
+	lines code.LineTable // 指令对应的源码行
 }
 
 type Compiler struct {
 	constants   []object.Object
 	symbolTable *SymbolTable
 
+	constantIndex map[constantKey]int // 整数和字符串常量在常量池中的索引，相同的常量只加入一次
+
 	scopes     []CompilationScope
 	scopeIndex int
+
+	err  error // 第一个超出指令操作数上限的错误
+	line int   // 正在编译的节点所在的行，记录到生成的指令上
 }
 
 func New() *Compiler {
+	return NewWithRegistry(builtin.Default())
+}
+
+// NewWithRegistry 使用指定的内置函数注册表创建编译器
+func NewWithRegistry(registry *builtin.Registry) *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
 		lastInstruction:     EmittedInstruction{},
@@ -42,26 +79,42 @@ func New() *Compiler {
 
 	symbolTable := NewSymbolTable()
 
-	for i, fn := range builtin.BuiltinFns {
-		symbolTable.DefineBuiltin(i, fn.Name)
+	for i, name := range registry.Names() {
+		symbolTable.DefineBuiltin(i, name)
 	}
 
 	return &Compiler{
-		scopes:      []CompilationScope{mainScope},
-		constants:   []object.Object{},
-		symbolTable: symbolTable,
-		scopeIndex:  0,
+		scopes:           []CompilationScope{mainScope},
+		constants:        []object.Object{},
+		symbolTable:      symbolTable,
+		scopeIndex:       0,
+		constantIndex:    make(map[constantKey]int),
 	}
 }
 
+// NewWithState 使用已有的符号表和常量池创建编译器，符号表中需要已定义好内置函数
 func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
-	compiler := New()
+	compiler := NewWithRegistry(builtin.NewRegistry())
 	compiler.symbolTable = s
 	compiler.constants = constants
+	// 之前编译加入的常量(包括内置函数名)继续参与去重
+	for i, constant := range constants {
+		if key, ok := constantKeyOf(constant); ok {
+			if _, seen := compiler.constantIndex[key]; !seen {
+				compiler.constantIndex[key] = i
+			}
+		}
+	}
 	return compiler
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	// 没有位置的节点(如宏展开或优化生成的节点)沿用外层节点的行
+	if line := ast.TokenOf(node).Line; line > 0 && line != c.line {
+		defer func(outer int) { c.line = outer }(c.line)
+		c.line = line
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -88,6 +141,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 	case *ast.LetStatement:
 		symbol := c.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope && symbol.Index >= GlobalsSize {
+			return fmt.Errorf("too many global bindings: limit is %d", GlobalsSize)
+		}
+
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
@@ -179,6 +236,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
 		case ">":
 			c.emit(code.OpGreaterThan)
 		case "==":
@@ -216,6 +275,28 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpIndex)
 
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+
+			err = c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro literal outside of a top-level let statement")
+
 	case *ast.CallExpression:
 		err := c.Compile(node.Function)
 		if err != nil {
@@ -257,16 +338,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
-		instructions := c.leaveScope()
+		localNames := c.symbolTable.definedNames()
+		instructions, lines := c.leaveScope()
 
 		// 将闭包需要的变量加载到栈中
 		for _,s := range freeSymbols {
 			c.loadSymbol(s)
 		}
+		freeNames := make([]string, len(freeSymbols))
+		for i, s := range freeSymbols {
+			freeNames[i] = s.Name
+		}
+
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Name:          node.Name,
+			Line:          node.Token.Line,
+			Lines:         lines,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
@@ -280,7 +372,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.loadSymbol(symbol)
 
 	case *ast.IntegerLiteral:
-		integer := &object.Integer{Value: node.Value}
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
 	case *ast.StringLiteral:
@@ -297,23 +392,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteral:
-		keys := make([]ast.Expression, 0, len(node.Pairs))
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-
-		// 不排序则指令会变成乱序(map 无序)，不方便测试
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-
-		for _, k := range keys {
-			err := c.Compile(k)
+		// 按源码顺序压栈，运行时的哈希会保留这个顺序
+		for _, pair := range node.Pairs {
+			err := c.Compile(pair.Key)
 			if err != nil {
 				return err
 			}
 
-			err = c.Compile(node.Pairs[k])
+			err = c.Compile(pair.Value)
 			if err != nil {
 				return err
 			}
@@ -329,13 +415,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 	}
 
-	return nil
+	return c.err
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
+	instructions, lines := c.resolvedInstructions()
 	return &Bytecode{
-		Instructions: c.currentInstructions(),
+		Instructions: instructions,
 		Constants:    c.constants,
+		Lines:        lines,
+		GlobalNames:  c.symbolTable.definedNames(),
 	}
 }
 
@@ -350,19 +439,64 @@ func (c *Compiler) Reset() {
 	c.constants = []object.Object{}
 	c.symbolTable = NewSymbolTable()
 	c.scopeIndex = 0
+	c.constantIndex = make(map[constantKey]int)
+	c.err = nil
+	c.line = 0
+}
+
+// constantKey 用于比较整数和字符串常量是否相同
+type constantKey struct {
+	typ   object.Type
+	value interface{}
 }
 
-// addConstant 将 obj 加入常量池，返回常量位于池中的索引
+// constantKeyOf 返回整数和字符串常量的去重键，其他常量(如函数)没有去重键
+func constantKeyOf(obj object.Object) (constantKey, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return constantKey{typ: obj.Type(), value: obj.Value}, true
+	case *object.BigInt:
+		return constantKey{typ: obj.Type(), value: obj.Value.String()}, true
+	case *object.String:
+		return constantKey{typ: obj.Type(), value: obj.Value}, true
+	}
+
+	return constantKey{}, false
+}
+
+// addConstant 将 obj 加入常量池，返回常量位于池中的索引，相同的整数和字符串常量返回已有的索引
 func (c *Compiler) addConstant(obj object.Object) int {
+	key, ok := constantKeyOf(obj)
+	if ok {
+		if idx, seen := c.constantIndex[key]; seen {
+			return idx
+		}
+	}
+
 	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+	idx := len(c.constants) - 1
+	if ok {
+		c.constantIndex[key] = idx
+	}
+	return idx
+}
+
+// builtinConstant 内置函数通过函数名引用，保证注册表变化时已编译的字节码仍然指向同一个函数
+func (c *Compiler) builtinConstant(name string) int {
+	return c.addConstant(&object.String{Value: name})
 }
 
 // emit 根据op和操作数生成新的指令，返回指令的起始位置
+// 操作数超出原来的宽度时自动加上 OpWide 前缀，超出加宽后的宽度时记录错误，由 Compile 返回
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
-	ins := code.Make(op, operands...)
+	if !code.FitsWide(op, operands...) && c.err == nil {
+		c.err = operandError(op, operands)
+	}
+
+	ins := code.Encode(op, operands...)
 	pos := c.addInstruction(ins)
 	c.setLastInstruction(op, pos)
+	c.scopes[c.scopeIndex].lines = c.scopes[c.scopeIndex].lines.Add(pos, c.line)
 	return pos
 }
 
@@ -404,6 +538,7 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = newInstructions
 	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.scopes[c.scopeIndex].lines = c.scopes[c.scopeIndex].lines.Truncate(last.Position)
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
@@ -413,12 +548,61 @@ func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
 	}
 }
 
+// changeOperand 修改跳转指令的目标，目标超出 2 字节时先记录下来，离开作用域时再统一改写
 func (c *Compiler) changeOperand(opPos int, operand int) {
 	op := code.Opcode(c.currentInstructions()[opPos])
+	if !code.Fits(op, operand) {
+		if c.scopes[c.scopeIndex].longJumps == nil {
+			c.scopes[c.scopeIndex].longJumps = make(map[int]int)
+		}
+		c.scopes[c.scopeIndex].longJumps[opPos] = operand
+		return
+	}
+
 	newInstruction := code.Make(op, operand)
 	c.replaceInstruction(opPos, newInstruction)
 }
 
+// resolvedInstructions 返回当前作用域的指令及其行号表，目标超出 2 字节的跳转改写为带 OpWide 前缀的跳转
+func (c *Compiler) resolvedInstructions() (code.Instructions, code.LineTable) {
+	scope := c.scopes[c.scopeIndex]
+	if len(scope.longJumps) == 0 {
+		return scope.instructions, scope.lines
+	}
+
+	instructions, err := code.Decode(scope.instructions)
+	if err != nil {
+		// 编译器生成的指令总是可以解码
+		panic(err)
+	}
+
+	for i, ins := range instructions {
+		if target, ok := scope.longJumps[ins.Offset]; ok {
+			instructions[i].Operands = []int{target}
+		}
+	}
+
+	return code.AssembleWithLines(instructions, scope.lines)
+}
+
+// operandError 返回操作数超出上限的错误
+func operandError(op code.Opcode, operands []int) error {
+	for i, o := range operands {
+		max := code.MaxOperand(op, i, true)
+		if o <= max {
+			continue
+		}
+
+		name := "operands"
+		if names, ok := operandNames[op]; ok && i < len(names) {
+			name = names[i]
+		}
+		return fmt.Errorf("too many %s: limit is %d", name, max+1)
+	}
+
+	return nil
+}
+
 func (c *Compiler) enterScope() {
 	scope := CompilationScope{
 		instructions:        code.Instructions{},
@@ -430,13 +614,13 @@ func (c *Compiler) enterScope() {
 	c.scopeIndex++
 }
 
-func (c *Compiler) leaveScope() code.Instructions {
-	instructions := c.currentInstructions()
+func (c *Compiler) leaveScope() (code.Instructions, code.LineTable) {
+	instructions, lines := c.resolvedInstructions()
 	c.scopes = c.scopes[:len(c.scopes)-1]
 	c.scopeIndex--
 	c.symbolTable = c.symbolTable.Outer
 
-	return instructions
+	return instructions, lines
 }
 
 func (c *Compiler) replaceLastPopWithReturn() {
@@ -453,7 +637,7 @@ func (c *Compiler) loadSymbol(s Symbol) {
 	case LocalScope:
 		c.emit(code.OpGetLocal, s.Index)
 	case BuiltinScope:
-		c.emit(code.OpGetBuiltin, s.Index)
+		c.emit(code.OpGetBuiltin, c.builtinConstant(s.Name))
 	case FreeScope:
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
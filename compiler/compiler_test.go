@@ -3,11 +3,14 @@ package compiler
 import (
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
 	"github.com/Shea11012/interpreter_in_go/code"
 	"github.com/Shea11012/interpreter_in_go/lexer"
 	"github.com/Shea11012/interpreter_in_go/object"
 	"github.com/Shea11012/interpreter_in_go/parser"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -39,6 +42,16 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "7%2",
+			expectedConstants: []interface{}{7, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1-2",
 			expectedConstants: []interface{}{1, 2},
@@ -406,6 +419,18 @@ func TestHashLiterals(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "{3:4,1:2}",
+			expectedConstants: []interface{}{3, 4, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "{1:2+3,4:5*6}",
 			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
@@ -427,18 +452,59 @@ func TestHashLiterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1][0:1]",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"ab"[:1]`,
+			expectedConstants: []interface{}{"ab", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"ab"[1:]`,
+			expectedConstants: []interface{}{"ab", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "[1,2,3][1+1]",
-			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			expectedConstants: []interface{}{1, 2, 3},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 2),
 				code.Make(code.OpArray, 3),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 0),
 				code.Make(code.OpAdd),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
@@ -446,13 +512,13 @@ func TestIndexExpressions(t *testing.T) {
 		},
 		{
 			input:             "{1:2}[2-1]",
-			expectedConstants: []interface{}{1, 2, 2, 1},
+			expectedConstants: []interface{}{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 0),
 				code.Make(code.OpSub),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
@@ -815,23 +881,29 @@ func TestBuiltins(t *testing.T) {
 			input: `
 			len([]);
 			push([],1);
+			len([]);
 `,
-			expectedConstants: []interface{}{1},
+			expectedConstants: []interface{}{"len", "push", 1},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpGetBuiltin, 0),
 				code.Make(code.OpArray, 0),
 				code.Make(code.OpCall, 1),
 				code.Make(code.OpPop),
-				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpGetBuiltin, 1),
 				code.Make(code.OpArray, 0),
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 2),
 				code.Make(code.OpCall, 2),
 				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: `fn() { len([]) }`,
 			expectedConstants: []interface{}{
+				"len",
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
@@ -840,29 +912,29 @@ func TestBuiltins(t *testing.T) {
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpClosure, 1, 0),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input: `map([1,2,3],fn(a){ a * 2 });`,
 			expectedConstants: []interface{}{
+				"map",
 				1,
 				2,
 				3,
-				2,
 				[]code.Instructions{
 					code.Make(code.OpGetLocal, 0),
-					code.Make(code.OpConstant, 3),
+					code.Make(code.OpConstant, 2),
 					code.Make(code.OpMul),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpGetBuiltin, 6),
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpGetBuiltin, 0),
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
 				code.Make(code.OpArray, 3),
 				code.Make(code.OpClosure, 4, 0),
 				code.Make(code.OpCall, 2),
@@ -874,6 +946,27 @@ func TestBuiltins(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestBuiltinsWithRegistry(t *testing.T) {
+	registry := builtin.NewRegistry()
+	registry.Register(builtin.MustFromFunc("double", func(n int64) int64 { return n * 2 }))
+
+	compiler := NewWithRegistry(registry)
+	err := compiler.Compile(parse(`double(2)`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err = testConstants(t, []interface{}{"double", 2}, compiler.Bytecode().Constants)
+	if err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+
+	err = NewWithRegistry(registry).Compile(parse(`len([])`))
+	if err == nil || err.Error() != "undefined variable len" {
+		t.Fatalf("expected undefined variable error, got=%v", err)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -988,13 +1081,12 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
-				1,
 			},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpClosure,1,0),
 				code.Make(code.OpSetGlobal,0),
 				code.Make(code.OpGetGlobal,0),
-				code.Make(code.OpConstant,2),
+				code.Make(code.OpConstant,0),
 				code.Make(code.OpCall,1),
 				code.Make(code.OpPop),
 			},
@@ -1018,18 +1110,17 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
-				1,
 				[]code.Instructions{
 					code.Make(code.OpClosure,1,0),
 					code.Make(code.OpSetLocal,0),
 					code.Make(code.OpGetLocal,0),
-					code.Make(code.OpConstant,2),
+					code.Make(code.OpConstant,0),
 					code.Make(code.OpCall,1),
 					code.Make(code.OpReturnValue),
 				},
 			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpClosure,3,0),
+				code.Make(code.OpClosure,2,0),
 				code.Make(code.OpSetGlobal,0),
 				code.Make(code.OpGetGlobal,0),
 				code.Make(code.OpCall,0),
@@ -1162,3 +1253,178 @@ func parse(input string) *ast.Program {
 	p := parser.New(l)
 	return p.ParseProgram()
 }
+
+func TestWideOperands(t *testing.T) {
+	// 标识符只能包含字母，用 vaa、vab、... 生成 300 个参数名
+	params := make([]string, 300)
+	for i := range params {
+		params[i] = "v" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+	}
+
+	tests := []compilerTestCase{
+		{
+			input: fmt.Sprintf("fn(%s) { %s }", strings.Join(params, ", "), params[299]),
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.MakeWide(code.OpGetLocal, 299),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLongJumps(t *testing.T) {
+	// 分支中的指令超过 64KB，跳转目标需要 4 字节
+	elements := strings.Repeat("1, ", 30000)
+	input := fmt.Sprintf("if (true) { [%s1] } else { 2 }; 3", elements)
+
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	instructions := compiler.Bytecode().Instructions
+	decoded, err := code.Decode(instructions)
+	if err != nil {
+		t.Fatalf("Decode returned error: %s", err)
+	}
+
+	var jumps []code.Instruction
+	for _, ins := range decoded {
+		if code.IsJump(ins.Op) {
+			jumps = append(jumps, ins)
+		}
+	}
+
+	if len(jumps) != 2 {
+		t.Fatalf("wrong number of jumps. want=2, got=%d", len(jumps))
+	}
+
+	for _, jump := range jumps {
+		if code.Opcode(instructions[jump.Offset]) != code.OpWide {
+			t.Errorf("jump at %d is not wide", jump.Offset)
+		}
+	}
+
+	// OpJumpNotTruthy 跳到 else 分支，OpJump 跳过 else 分支
+	alternative, end := jumps[0].Operands[0], jumps[1].Operands[0]
+	if code.Opcode(instructions[alternative]) != code.OpConstant || code.Opcode(instructions[end]) != code.OpPop {
+		t.Errorf("wrong jump targets %d and %d", alternative, end)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			fmt.Sprintf("let f = fn() { 1 }; f(%s1)", strings.Repeat("1, ", 65536)),
+			"too many call arguments: limit is 65536",
+		},
+		{
+			strings.Repeat("let a = 1; ", 65536) + "let b = 2;",
+			"too many global bindings: limit is 65536",
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestDebugInfo(t *testing.T) {
+	input := `let x = 1;
+let f = fn(a) {
+    let b = a + x;
+    b
+};
+if (x > 0) {
+    f(2);
+}`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	instructionLines := func(ins code.Instructions, lines code.LineTable) []int {
+		decoded, err := code.Decode(ins)
+		if err != nil {
+			t.Fatalf("Decode returned error: %s", err)
+		}
+
+		result := make([]int, 0, len(decoded))
+		for _, d := range decoded {
+			result = append(result, lines.Line(d.Offset))
+		}
+		return result
+	}
+
+	// OpConstant OpSetGlobal OpClosure OpSetGlobal
+	// OpGetGlobal OpConstant OpGreaterThan OpJumpNotTruthy OpGetGlobal OpConstant OpCall OpJump OpNull OpPop
+	mainLines := []int{1, 1, 2, 2, 6, 6, 6, 6, 7, 7, 7, 6, 6, 6}
+	if got := instructionLines(bytecode.Instructions, bytecode.Lines); !reflect.DeepEqual(got, mainLines) {
+		t.Errorf("wrong main lines. got=%v, want=%v", got, mainLines)
+	}
+
+	if !reflect.DeepEqual(bytecode.GlobalNames, []string{"x", "f"}) {
+		t.Errorf("wrong global names. got=%v", bytecode.GlobalNames)
+	}
+
+	var fn *object.CompiledFunction
+	for _, constant := range bytecode.Constants {
+		if f, ok := constant.(*object.CompiledFunction); ok {
+			fn = f
+		}
+	}
+	if fn == nil {
+		t.Fatalf("no compiled function in constants")
+	}
+
+	if fn.Name != "f" || fn.Line != 2 || !reflect.DeepEqual(fn.LocalNames, []string{"a", "b"}) || len(fn.FreeNames) != 0 {
+		t.Errorf("wrong function debug info. got name=%q line=%d locals=%v free=%v",
+			fn.Name, fn.Line, fn.LocalNames, fn.FreeNames)
+	}
+
+	// OpGetLocal OpGetGlobal OpAdd OpSetLocal OpGetLocal OpReturnValue
+	fnLines := []int{3, 3, 3, 3, 4, 4}
+	if got := instructionLines(fn.Instructions, fn.Lines); !reflect.DeepEqual(got, fnLines) {
+		t.Errorf("wrong function lines. got=%v, want=%v", got, fnLines)
+	}
+}
+
+func TestConstantsAcrossState(t *testing.T) {
+	symbolTable := NewSymbolTable()
+	for i, name := range builtin.Default().Names() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	// 与 REPL 相同，每一行使用新的编译器，相同的常量和内置函数名只加入一次
+	var constants []object.Object
+	for _, input := range []string{`len("a") + 1`, `len("a") + 1`, `let x = 1; "len"`} {
+		compiler := NewWithState(symbolTable, constants)
+		if err := compiler.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = compiler.Bytecode().Constants
+	}
+
+	err := testConstants(t, []interface{}{"len", "a", 1}, constants)
+	if err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+}
@@ -104,3 +104,15 @@ func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.store[original.Name] = symbol
 	return symbol
 }
+
+// definedNames 返回这个作用域中定义的变量名，下标为变量的索引，被同名变量覆盖的索引为空
+func (s *SymbolTable) definedNames() []string {
+	names := make([]string, s.numDefinitions)
+	for name, sym := range s.store {
+		if sym.Scope == GlobalScope || sym.Scope == LocalScope {
+			names[sym.Index] = name
+		}
+	}
+
+	return names
+}
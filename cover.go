@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/cover"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// coverCommand monkey cover [-html file] [-o file] <profile>...，合并覆盖率文件并输出摘要，返回进程的退出码
+func coverCommand(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	html := flags.String("html", "", "write an annotated HTML report to `file`")
+	output := flags.String("o", "", "write the merged coverage profile to `file`")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	profile := cover.NewProfile()
+	for _, filename := range flags.Args() {
+		p, ok := readCoverProfile(filename)
+		if !ok {
+			return 1
+		}
+		profile.Merge(p)
+	}
+
+	if err := profile.WriteSummary(os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *output != "" && !writeFile(*output, profile.Write) {
+		return 1
+	}
+
+	if *html != "" && !writeFile(*html, func(w io.Writer) error { return profile.WriteHTML(w, ioutil.ReadFile) }) {
+		return 1
+	}
+
+	return 0
+}
+
+// writeCoverage 把覆盖率摘要输出到标准错误，filename 不为空时把结果合并到这个覆盖率文件
+func writeCoverage(profile *cover.Profile, filename string) bool {
+	if err := profile.WriteSummary(os.Stderr); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	if filename == "" {
+		return true
+	}
+
+	if _, err := os.Stat(filename); err == nil {
+		previous, ok := readCoverProfile(filename)
+		if !ok {
+			return false
+		}
+		previous.Merge(profile)
+		profile = previous
+	}
+
+	return writeFile(filename, profile.Write)
+}
+
+// readCoverProfile 读取覆盖率文件，出错时把错误输出到标准错误
+func readCoverProfile(filename string) (*cover.Profile, bool) {
+	f, err := os.Open(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+	defer f.Close()
+
+	profile, err := cover.Parse(f)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return nil, false
+	}
+
+	return profile, true
+}
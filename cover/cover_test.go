@@ -0,0 +1,170 @@
+package cover
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `let classify = fn(n) {
+    if (n < 0) {
+        "negative"
+    } else {
+        "positive"
+    }
+};
+let unused = fn() {
+    1
+};
+let add = fn(a, b) { a + b };
+let total = add(1, len(classify(5)));
+puts(classify(7));`
+
+// run 运行 source，把覆盖率记录到 name 中
+func run(t *testing.T, profile *Profile, name string, source string) {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	machine := vm.New(bytecode)
+	machine.SetIO(object.NewIO(strings.NewReader(""), ioutil.Discard, ioutil.Discard))
+	machine.AddHooks(NewRecorder(profile.File(name), bytecode).Hooks())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	profile := NewProfile()
+	run(t, profile, "test.mk", testSource)
+
+	// 第 2 行在执行完分支之后还有跳转指令，每次调用进入两次；
+	// 从 add 和 classify 返回到第 12 行不算进入
+	expected := map[int]int64{1: 1, 2: 4, 3: 0, 5: 2, 8: 1, 9: 0, 11: 2, 12: 1, 13: 1}
+	if got := profile.File("test.mk").Counts; !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong counts.\ngot=%v\nwant=%v", got, expected)
+	}
+
+	covered, total := profile.File("test.mk").Covered()
+	if covered != 7 || total != 9 {
+		t.Errorf("wrong coverage. got=%d/%d, want=7/9", covered, total)
+	}
+}
+
+func TestParseWriteMerge(t *testing.T) {
+	first := NewProfile()
+	run(t, first, "a b:1.mk", testSource)
+	run(t, first, "b.mk", `puts(1);`)
+
+	var out bytes.Buffer
+	if err := first.Write(&out); err != nil {
+		t.Fatalf("Write error: %s", err)
+	}
+
+	parsed, err := Parse(&out)
+	if err != nil {
+		t.Fatalf("Parse error: %s", err)
+	}
+	if !reflect.DeepEqual(parsed, first) {
+		t.Errorf("wrong parsed profile.\ngot=%+v\nwant=%+v", parsed.Files, first.Files)
+	}
+
+	second := NewProfile()
+	run(t, second, "a b:1.mk", strings.Replace(testSource, "classify(7)", "classify(-7)", 1))
+	parsed.Merge(second)
+
+	a := parsed.File("a b:1.mk")
+	if a.Counts[3] != 1 || a.Counts[5] != 3 || a.Counts[13] != 2 || a.Counts[9] != 0 {
+		t.Errorf("wrong merged counts. got=%v", a.Counts)
+	}
+	if covered, total := a.Covered(); covered != 8 || total != 9 {
+		t.Errorf("wrong merged coverage. got=%d/%d, want=8/9", covered, total)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", `line 1: expected "mode: count", got EOF`},
+		{"mode: set\n", `line 1: expected "mode: count", got "mode: set"`},
+		{"mode: count\na.mk 1\n", `line 2: expected file:line count, got "a.mk 1"`},
+		{"mode: count\na.mk:x 1\n", `line 2: invalid line number "x"`},
+		{"mode: count\na.mk:1 -1\n", `line 2: invalid count "-1"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. got=%v, want=%s", tt.input, err, tt.expected)
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	profile := NewProfile()
+	profile.File("a.mk").Counts = map[int]int64{1: 1, 2: 0, 4: 0, 5: 3, 7: 0}
+	profile.File("long.mk").Counts = map[int]int64{1: 1}
+
+	var out bytes.Buffer
+	if err := profile.WriteSummary(&out); err != nil {
+		t.Fatalf("WriteSummary error: %s", err)
+	}
+
+	expected := `a.mk      40.0% (2/5 lines)  uncovered: 2-4, 7
+long.mk  100.0% (1/1 lines)
+total     50.0% (3/6 lines)
+`
+	if out.String() != expected {
+		t.Errorf("wrong summary.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	profile := NewProfile()
+	profile.File("a.mk").Counts = map[int]int64{1: 2, 3: 0}
+	profile.File("missing.mk").Counts = map[int]int64{1: 0}
+
+	source := func(name string) ([]byte, error) {
+		if name == "a.mk" {
+			return []byte("let a = \"<b>\";\n\nputs(a);\n"), nil
+		}
+		return nil, errors.New("file not found")
+	}
+
+	var out bytes.Buffer
+	if err := profile.WriteHTML(&out, source); err != nil {
+		t.Fatalf("WriteHTML error: %s", err)
+	}
+
+	for _, expected := range []string{
+		`<tr class="covered"><td class="number">1</td><td class="count">2</td><td class="source">let a = &#34;&lt;b&gt;&#34;;</td></tr>`,
+		`<tr class=""><td class="number">2</td><td class="count"></td><td class="source"></td></tr>`,
+		`<tr class="uncovered"><td class="number">3</td><td class="count">0</td><td class="source">puts(a);</td></tr>`,
+		`<h2 id="missing.mk">missing.mk   0.0% (0/1 lines)</h2>`,
+		`<p class="error">file not found</p>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("HTML report does not contain %s\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package cover 通过编译时记录的行号信息统计 Monkey 程序执行过哪些源码行，
+// 统计结果可以保存到文件、合并多次运行的结果，并生成文本摘要和 HTML 报告
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// header 覆盖率文件的第一行
+const header = "mode: count"
+
+// File 一个源文件的覆盖率，Counts 包含所有有指令的行，值为执行进入这一行的次数
+type File struct {
+	Name   string
+	Counts map[int]int64
+}
+
+// Covered 返回执行过的行数和有指令的行数
+func (f *File) Covered() (covered int, total int) {
+	for _, count := range f.Counts {
+		if count > 0 {
+			covered++
+		}
+	}
+
+	return covered, len(f.Counts)
+}
+
+// Lines 返回有指令的行，从小到大排列
+func (f *File) Lines() []int {
+	lines := make([]int, 0, len(f.Counts))
+	for line := range f.Counts {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+// Profile 多个源文件的覆盖率
+type Profile struct {
+	Files map[string]*File
+}
+
+// NewProfile 创建一个空的 Profile
+func NewProfile() *Profile {
+	return &Profile{Files: make(map[string]*File)}
+}
+
+// File 返回文件 name 的覆盖率，不存在时创建
+func (p *Profile) File(name string) *File {
+	f, ok := p.Files[name]
+	if !ok {
+		f = &File{Name: name, Counts: make(map[int]int64)}
+		p.Files[name] = f
+	}
+
+	return f
+}
+
+// Names 返回所有文件名，按字典序排列
+func (p *Profile) Names() []string {
+	names := make([]string, 0, len(p.Files))
+	for name := range p.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Merge 把 other 中的结果加到 p 上，同一行的次数相加
+func (p *Profile) Merge(other *Profile) {
+	for name, f := range other.Files {
+		merged := p.File(name)
+		for line, count := range f.Counts {
+			merged.Counts[line] += count
+		}
+	}
+}
+
+// Write 输出覆盖率文件，第一行为 mode: count，之后每行为 文件名:行号 次数，按文件名和行号排列
+func (p *Profile) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintln(bw, header)
+	for _, name := range p.Names() {
+		f := p.Files[name]
+		for _, line := range f.Lines() {
+			_, _ = fmt.Fprintf(bw, "%s:%d %d\n", name, line, f.Counts[line])
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Parse 读取 Write 输出的覆盖率文件
+func Parse(r io.Reader) (*Profile, error) {
+	p := NewProfile()
+	scanner := bufio.NewScanner(r)
+
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		text := scanner.Text()
+		if lineno == 1 {
+			if text != header {
+				return nil, fmt.Errorf("line 1: expected %q, got %q", header, text)
+			}
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		// 文件名中可能有空格和冒号，从右边开始拆分
+		space := strings.LastIndexByte(text, ' ')
+		colon := -1
+		if space >= 0 {
+			colon = strings.LastIndexByte(text[:space], ':')
+		}
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: expected file:line count, got %q", lineno, text)
+		}
+
+		line, err := strconv.Atoi(text[colon+1 : space])
+		if err != nil || line <= 0 {
+			return nil, fmt.Errorf("line %d: invalid line number %q", lineno, text[colon+1:space])
+		}
+		count, err := strconv.ParseInt(text[space+1:], 10, 64)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("line %d: invalid count %q", lineno, text[space+1:])
+		}
+
+		p.File(text[:colon]).Counts[line] += count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lineno == 0 {
+		return nil, fmt.Errorf("line 1: expected %q, got EOF", header)
+	}
+
+	return p, nil
+}
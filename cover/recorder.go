@@ -0,0 +1,58 @@
+package cover
+
+import (
+	"github.com/Shea11012/interpreter_in_go/code"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+)
+
+// Recorder 通过 vm.Hooks 统计一次运行中执行进入每一行的次数，函数返回到调用所在的行不算进入
+type Recorder struct {
+	file *File
+
+	prevFrame *vm.Frame
+	prevLine  int
+	prevDepth int
+}
+
+// NewRecorder 创建把 bytecode 的执行结果记录到 file 的 Recorder，
+// bytecode 中所有有指令的行都会加到 file 中，没有执行过的行次数为 0
+func NewRecorder(file *File, bytecode *compiler.Bytecode) *Recorder {
+	addLines(file, bytecode.Lines)
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			addLines(file, fn.Lines)
+		}
+	}
+
+	return &Recorder{file: file}
+}
+
+func addLines(file *File, lines code.LineTable) {
+	for _, entry := range lines {
+		if _, ok := file.Counts[entry.Line]; !ok {
+			file.Counts[entry.Line] = 0
+		}
+	}
+}
+
+// Hooks 返回需要添加到虚拟机上的回调
+func (r *Recorder) Hooks() *vm.Hooks {
+	return &vm.Hooks{Instruction: r.instruction}
+}
+
+func (r *Recorder) instruction(v *vm.VM) error {
+	frame := v.Frames()[0]
+	line, depth := frame.Line(), v.Depth()
+
+	entered := frame != r.prevFrame || line != r.prevLine
+	returned := depth < r.prevDepth
+	r.prevFrame, r.prevLine, r.prevDepth = frame, line, depth
+
+	if line != 0 && entered && !returned {
+		r.file.Counts[line]++
+	}
+
+	return nil
+}
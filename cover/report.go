@@ -0,0 +1,167 @@
+package cover
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteSummary 每个文件输出一行覆盖率和没有执行过的行，最后一行为合计，如
+//
+//	fib.mk  83.3% (10/12 lines)  uncovered: 4-5
+//	total   83.3% (10/12 lines)
+func (p *Profile) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	var covered, total int
+	for _, name := range p.Names() {
+		f := p.Files[name]
+		c, t := f.Covered()
+		covered, total = covered+c, total+t
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s", name, percentage(c, t))
+		if uncovered := f.uncovered(); uncovered != "" {
+			_, _ = fmt.Fprintf(tw, "\tuncovered: %s", uncovered)
+		}
+		_, _ = fmt.Fprintln(tw)
+	}
+	_, _ = fmt.Fprintf(tw, "total\t%s\n", percentage(covered, total))
+
+	return tw.Flush()
+}
+
+func percentage(covered, total int) string {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(covered) * 100 / float64(total)
+	}
+
+	return fmt.Sprintf("%5.1f%% (%d/%d lines)", percent, covered, total)
+}
+
+// uncovered 返回没有执行过的行，连续的行合并为一个区间，如 4-5, 9
+func (f *File) uncovered() string {
+	var ranges []string
+	start, end := 0, 0
+	flush := func() {
+		if start == 0 {
+			return
+		}
+		if start == end {
+			ranges = append(ranges, fmt.Sprint(start))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, end))
+		}
+		start = 0
+	}
+
+	// 中间没有指令的行不打断区间
+	for _, line := range f.Lines() {
+		if f.Counts[line] > 0 {
+			flush()
+			continue
+		}
+		if start == 0 {
+			start = line
+		}
+		end = line
+	}
+	flush()
+
+	return strings.Join(ranges, ", ")
+}
+
+// htmlLine HTML 报告中的一行源码，Class 为 covered、uncovered 或空，空表示这一行没有指令
+type htmlLine struct {
+	Number int
+	Count  int64
+	Class  string
+	Text   string
+}
+
+type htmlFile struct {
+	Name    string
+	Summary string
+	Error   string
+	Lines   []htmlLine
+}
+
+var htmlTemplate = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Monkey coverage</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; font-family: monospace; white-space: pre; }
+td { padding: 0 0.6em; }
+td.number, td.count { text-align: right; color: #888; }
+tr.covered td.source { background: #dff5df; }
+tr.uncovered td.source { background: #f8d7d7; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Monkey coverage</h1>
+<ul>
+{{- range .}}
+<li><a href="#{{.Name}}">{{.Name}}</a> {{.Summary}}</li>
+{{- end}}
+</ul>
+{{- range .}}
+<h2 id="{{.Name}}">{{.Name}} {{.Summary}}</h2>
+{{- if .Error}}
+<p class="error">{{.Error}}</p>
+{{- else}}
+<table>
+{{- range .Lines}}
+<tr class="{{.Class}}"><td class="number">{{.Number}}</td><td class="count">{{if .Class}}{{.Count}}{{end}}</td><td class="source">{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML 输出每个文件带有执行次数的源码，执行过的行为绿色，没有执行过的为红色，
+// source 返回文件的内容，出错时在报告中显示错误
+func (p *Profile) WriteHTML(w io.Writer, source func(name string) ([]byte, error)) error {
+	var files []htmlFile
+	for _, name := range p.Names() {
+		f := p.Files[name]
+		file := htmlFile{Name: name, Summary: percentage(f.Covered())}
+
+		content, err := source(name)
+		if err != nil {
+			file.Error = err.Error()
+			files = append(files, file)
+			continue
+		}
+
+		for i, text := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			line := htmlLine{Number: i + 1, Text: text}
+			if count, ok := f.Counts[line.Number]; ok {
+				line.Count = count
+				line.Class = "uncovered"
+				if count > 0 {
+					line.Class = "covered"
+				}
+			}
+			file.Lines = append(file.Lines, line)
+		}
+		files = append(files, file)
+	}
+
+	var out bytes.Buffer
+	if err := htmlTemplate.Execute(&out, files); err != nil {
+		return err
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/debugger"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io/ioutil"
+	"os"
+)
+
+// debugCommand monkey debug <file>，在调试器中运行程序，调试命令从标准输入读取
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	filename := flags.Arg(0)
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	program, ok := parseSource(filename, source)
+	if !ok {
+		return 1
+	}
+
+	// 不做优化，保证每条指令的行号与源码一致
+	bytecode, ok := compileProgram(program, false)
+	if !ok {
+		return 1
+	}
+
+	// 程序和调试器共用同一个标准输入的缓冲区
+	stdin := bufio.NewReader(os.Stdin)
+	machine := vm.New(bytecode)
+	machine.SetIO(object.NewIO(stdin, os.Stdout, os.Stderr))
+	machine.AddHooks(debugger.New(string(source), bytecode, stdin, os.Stdout).Hooks())
+
+	switch err := machine.Run(); err {
+	case nil:
+		fmt.Println("program exited")
+	case debugger.ErrQuit:
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "runtime error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,440 @@
+// Package debugger 实现字节码虚拟机的交互式调试器，通过 vm.Hooks 在指令执行之前暂停
+package debugger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/code"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQuit 用户退出了调试器，程序没有运行完，由 vm.Run 返回
+var ErrQuit = errors.New("debugger: quit")
+
+// printTimeout print 命令求值的最长时间
+const printTimeout = time.Second
+
+// disasmContext disasm 命令在当前指令前后各显示的指令数
+const disasmContext = 5
+
+const help = `commands:
+  break [line]   set a breakpoint at line, or list breakpoints (b)
+  clear <line>   remove the breakpoint at line
+  step           run to the next line, entering function calls (s)
+  next           run to the next line in the current function (n)
+  finish         run until the current function returns
+  continue       run until the next breakpoint (c)
+  bt             print the call stack
+  locals         print the variables of the current function
+  print <expr>   evaluate an expression with the current variables (p)
+  disasm         print the instructions around the current one
+  quit           stop the program (q)
+an empty line repeats the last command
+`
+
+type mode int
+
+const (
+	modeStep mode = iota
+	modeNext
+	modeFinish
+	modeContinue
+)
+
+// Debugger 在程序开始运行时以及遇到断点、单步执行结束时暂停，从 in 读取命令，把结果写到 out
+//
+// 断点和单步执行都以源码行为单位：进入新的一行时才会暂停，从函数返回到调用者的那一行时只有单步执行会暂停
+type Debugger struct {
+	in       *bufio.Reader
+	out      io.Writer
+	source   []string
+	bytecode *compiler.Bytecode
+	registry *builtin.Registry
+
+	breakpoints map[int]bool
+	codeLines   map[int]bool // 有指令的行，只能在这些行上设置断点
+
+	mode  mode
+	depth int // 开始单步执行时的调用深度
+	line  int // 开始单步执行时所在的行
+
+	// 上一条指令所在的 frame、行和调用深度，用于判断是否进入了新的一行
+	prevFrame *vm.Frame
+	prevLine  int
+	prevDepth int
+
+	lastCommand string
+}
+
+// New 创建调试器，source 为 bytecode 的源码，in 为 *bufio.Reader 时直接使用，
+// 这样程序和调试器可以共用同一个标准输入
+func New(source string, bytecode *compiler.Bytecode, in io.Reader, out io.Writer) *Debugger {
+	reader, ok := in.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(in)
+	}
+
+	d := &Debugger{
+		in:          reader,
+		out:         out,
+		source:      strings.Split(source, "\n"),
+		bytecode:    bytecode,
+		registry:    builtin.Default(),
+		breakpoints: make(map[int]bool),
+		codeLines:   make(map[int]bool),
+		mode:        modeStep,
+	}
+
+	lines := []code.LineTable{bytecode.Lines}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			lines = append(lines, fn.Lines)
+		}
+	}
+	for _, table := range lines {
+		for _, entry := range table {
+			d.codeLines[entry.Line] = true
+		}
+	}
+
+	return d
+}
+
+// SetBuiltins 设置 print 命令求值时使用的内置函数注册表，应当与虚拟机使用的相同
+func (d *Debugger) SetBuiltins(registry *builtin.Registry) {
+	d.registry = registry
+}
+
+// Hooks 返回需要添加到虚拟机上的回调
+func (d *Debugger) Hooks() *vm.Hooks {
+	return &vm.Hooks{Instruction: d.instruction}
+}
+
+func (d *Debugger) instruction(v *vm.VM) error {
+	frames := v.Frames()
+	frame, depth := frames[0], len(frames)
+	line := frame.Line()
+
+	entered := frame != d.prevFrame || line != d.prevLine
+	returned := depth < d.prevDepth
+	d.prevFrame, d.prevLine, d.prevDepth = frame, line, depth
+
+	if line == 0 || !entered || !d.shouldPause(line, depth, returned) {
+		return nil
+	}
+
+	return d.prompt(v)
+}
+
+// shouldPause 判断进入 line 时是否暂停，returned 表示是从函数返回到这一行
+func (d *Debugger) shouldPause(line, depth int, returned bool) bool {
+	if d.breakpoints[line] && !returned {
+		return true
+	}
+
+	switch d.mode {
+	case modeStep:
+		return depth != d.depth || line != d.line
+	case modeNext:
+		return depth < d.depth || depth == d.depth && line != d.line
+	case modeFinish:
+		return depth < d.depth
+	}
+
+	return false
+}
+
+// prompt 暂停执行，读取并执行命令，直到遇到继续执行的命令
+func (d *Debugger) prompt(v *vm.VM) error {
+	frame := v.Frames()[0]
+	d.printf("=> %d: %s\n", frame.Line(), d.sourceLine(frame.Line()))
+
+	for {
+		d.printf("(mdb) ")
+		input, err := d.in.ReadString('\n')
+		if err != nil && input == "" {
+			d.printf("\n")
+			return ErrQuit
+		}
+
+		command := strings.TrimSpace(input)
+		if command == "" {
+			command = d.lastCommand
+		}
+		d.lastCommand = command
+
+		name, arg := command, ""
+		if i := strings.IndexAny(command, " \t"); i >= 0 {
+			name, arg = command[:i], strings.TrimSpace(command[i+1:])
+		}
+
+		switch name {
+		case "":
+		case "break", "b":
+			d.setBreakpoint(arg)
+		case "clear":
+			d.clearBreakpoint(arg)
+		case "step", "s":
+			d.resume(v, modeStep)
+			return nil
+		case "next", "n":
+			d.resume(v, modeNext)
+			return nil
+		case "finish":
+			if v.Depth() == 1 {
+				d.printf("finish is not meaningful in the outermost frame\n")
+				continue
+			}
+			d.resume(v, modeFinish)
+			return nil
+		case "continue", "c":
+			d.resume(v, modeContinue)
+			return nil
+		case "bt", "backtrace":
+			d.backtrace(v)
+		case "locals":
+			d.locals(v)
+		case "print", "p":
+			d.print(v, arg)
+		case "disasm":
+			d.disasm(v)
+		case "help", "h":
+			d.printf("%s", help)
+		case "quit", "q":
+			return ErrQuit
+		default:
+			d.printf("unknown command %q, type help for a list of commands\n", name)
+		}
+	}
+}
+
+func (d *Debugger) resume(v *vm.VM, mode mode) {
+	d.mode = mode
+	d.depth = v.Depth()
+	d.line = v.Frames()[0].Line()
+}
+
+func (d *Debugger) setBreakpoint(arg string) {
+	if arg == "" {
+		if len(d.breakpoints) == 0 {
+			d.printf("no breakpoints\n")
+			return
+		}
+
+		lines := make([]int, 0, len(d.breakpoints))
+		for line := range d.breakpoints {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		for _, line := range lines {
+			d.printf("breakpoint at line %d: %s\n", line, d.sourceLine(line))
+		}
+		return
+	}
+
+	line, ok := d.parseLine(arg)
+	if !ok {
+		return
+	}
+	if !d.codeLines[line] {
+		d.printf("no code at line %d\n", line)
+		return
+	}
+
+	d.breakpoints[line] = true
+	d.printf("breakpoint set at line %d\n", line)
+}
+
+func (d *Debugger) clearBreakpoint(arg string) {
+	line, ok := d.parseLine(arg)
+	if !ok {
+		return
+	}
+	if !d.breakpoints[line] {
+		d.printf("no breakpoint at line %d\n", line)
+		return
+	}
+
+	delete(d.breakpoints, line)
+	d.printf("breakpoint at line %d cleared\n", line)
+}
+
+func (d *Debugger) parseLine(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		d.printf("invalid line number %q\n", arg)
+		return 0, false
+	}
+
+	return line, true
+}
+
+// backtrace 输出调用栈，#0 为当前函数
+func (d *Debugger) backtrace(v *vm.VM) {
+	for i, frame := range v.Frames() {
+		d.printf("#%d %s at line %d\n", i, vm.FunctionName(frame.Function()), frame.Line())
+	}
+}
+
+// locals 输出当前函数的参数、局部变量和自由变量，在主程序中输出全局变量
+func (d *Debugger) locals(v *vm.VM) {
+	frame := v.Frames()[0]
+	fn := frame.Function()
+
+	printed := false
+	show := func(name string, value object.Object, suffix string) {
+		if name == "" || value == nil {
+			return
+		}
+		d.printf("%s = %s%s\n", name, inspect(value), suffix)
+		printed = true
+	}
+
+	if v.Depth() == 1 {
+		globals := v.Globals()
+		for i, name := range d.bytecode.GlobalNames {
+			if i < len(globals) {
+				show(name, globals[i], "")
+			}
+		}
+	} else {
+		for i, value := range v.Locals(frame) {
+			if i < len(fn.LocalNames) {
+				show(fn.LocalNames[i], value, "")
+			}
+		}
+		for i, value := range frame.Closure().Free {
+			if i < len(fn.FreeNames) {
+				show(fn.FreeNames[i], value, " (free)")
+			}
+		}
+	}
+
+	if !printed {
+		d.printf("no variables\n")
+	}
+}
+
+// print 用求值器计算表达式，其中的变量取当前函数中的值，没有找到的取全局变量
+func (d *Debugger) print(v *vm.VM, expr string) {
+	if expr == "" {
+		d.printf("usage: print <expr>\n")
+		return
+	}
+
+	p := parser.New(lexer.New(expr))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		// 后面的错误通常是第一个错误引起的
+		d.printf("syntax error: %s\n", p.Errors()[0])
+		return
+	}
+
+	env := object.NewEnvironment()
+	globals := v.Globals()
+	for i, name := range d.bytecode.GlobalNames {
+		if name != "" && i < len(globals) && globals[i] != nil {
+			env.Set(name, globals[i])
+		}
+	}
+
+	frame := v.Frames()[0]
+	if v.Depth() > 1 {
+		fn := frame.Function()
+		for i, value := range frame.Closure().Free {
+			if i < len(fn.FreeNames) {
+				env.Set(fn.FreeNames[i], value)
+			}
+		}
+		for i, value := range v.Locals(frame) {
+			if i < len(fn.LocalNames) && fn.LocalNames[i] != "" && value != nil {
+				env.Set(fn.LocalNames[i], value)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), printTimeout)
+	defer cancel()
+
+	result, err := evaluator.NewWithRegistry(d.registry).Run(ctx, program, env)
+	if err != nil {
+		d.printf("error: %s\n", err)
+		return
+	}
+	if result == nil {
+		result = object.NULL
+	}
+
+	d.printf("%s\n", inspect(result))
+}
+
+// inspect 返回值的文本形式，闭包显示函数名和定义所在的行而不是地址
+func inspect(value object.Object) string {
+	if cl, ok := value.(*object.Closure); ok {
+		return fmt.Sprintf("<fn %s at line %d>", vm.FunctionName(cl.Fn), cl.Fn.Line)
+	}
+
+	return value.Inspect()
+}
+
+// disasm 输出当前指令前后的指令，每行为位置、源码行和指令
+func (d *Debugger) disasm(v *vm.VM) {
+	frame := v.Frames()[0]
+	fn := frame.Function()
+
+	instructions, err := code.Decode(fn.Instructions)
+	if err != nil {
+		d.printf("error: %s\n", err)
+		return
+	}
+
+	current := 0
+	for i, ins := range instructions {
+		if ins.Offset <= frame.IP() {
+			current = i
+		}
+	}
+
+	from, to := current-disasmContext, current+disasmContext+1
+	if from < 0 {
+		from = 0
+	}
+	if to > len(instructions) {
+		to = len(instructions)
+	}
+
+	d.printf("%s:\n", vm.FunctionName(fn))
+	for i := from; i < to; i++ {
+		marker := "  "
+		if i == current {
+			marker = "=>"
+		}
+		ins := instructions[i]
+		d.printf("%s %04d %4d  %s\n", marker, ins.Offset, fn.Lines.Line(ins.Offset), ins)
+	}
+}
+
+func (d *Debugger) sourceLine(line int) string {
+	if line < 1 || line > len(d.source) {
+		return ""
+	}
+
+	return strings.TrimSpace(d.source[line-1])
+}
+
+func (d *Debugger) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(d.out, format, a...)
+}
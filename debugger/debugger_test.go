@@ -0,0 +1,160 @@
+package debugger
+
+import (
+	"bytes"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"strings"
+	"testing"
+)
+
+const testSource = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let total = add(1, 2);
+puts(total);
+let offset = 10;
+let shift = fn(x) { fn(y) { x + y + offset } };
+puts(shift(1)(2));`
+
+// run 在调试器中运行 testSource，commands 为输入的命令，返回调试器和程序的输出以及 Run 的返回值
+func run(t *testing.T, commands ...string) (string, error) {
+	t.Helper()
+
+	p := parser.New(lexer.New(testSource))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(commands, "\n") + "\n")
+	machine := vm.New(bytecode)
+	machine.SetIO(object.NewIO(strings.NewReader(""), &out, &out))
+	machine.AddHooks(New(testSource, bytecode, in, &out).Hooks())
+
+	err := machine.Run()
+	return out.String(), err
+}
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		expected string
+	}{
+		{
+			"breakpoint, backtrace and locals",
+			[]string{"b 2", "c", "bt", "locals", "print a * 10 + b", "n", "p sum", "c"},
+			`=> 1: let add = fn(a, b) {
+(mdb) breakpoint set at line 2
+(mdb) => 2: let sum = a + b;
+(mdb) #0 add at line 2
+#1 <main> at line 5
+(mdb) a = 1
+b = 2
+(mdb) 12
+(mdb) => 3: sum
+(mdb) 3
+(mdb) 3
+13
+`,
+		},
+		{
+			"step into and finish",
+			[]string{"s", "s", "s", "finish", "p total", "n", "p total", "q"},
+			`=> 1: let add = fn(a, b) {
+(mdb) => 5: let total = add(1, 2);
+(mdb) => 2: let sum = a + b;
+(mdb) => 3: sum
+(mdb) => 5: let total = add(1, 2);
+(mdb) ERROR: identifier not found: total
+(mdb) => 6: puts(total);
+(mdb) 3
+(mdb) `,
+		},
+		{
+			"next steps over calls",
+			[]string{"n", "n", "n", "n", "locals", "q"},
+			`=> 1: let add = fn(a, b) {
+(mdb) => 5: let total = add(1, 2);
+(mdb) => 6: puts(total);
+(mdb) 3
+=> 7: let offset = 10;
+(mdb) => 8: let shift = fn(x) { fn(y) { x + y + offset } };
+(mdb) add = <fn add at line 1>
+total = 3
+offset = 10
+(mdb) `,
+		},
+		{
+			"free variables",
+			[]string{"b 8", "c", "c", "c", "locals", "p x + y", "c"},
+			`=> 1: let add = fn(a, b) {
+(mdb) breakpoint set at line 8
+(mdb) 3
+=> 8: let shift = fn(x) { fn(y) { x + y + offset } };
+(mdb) => 8: let shift = fn(x) { fn(y) { x + y + offset } };
+(mdb) => 8: let shift = fn(x) { fn(y) { x + y + offset } };
+(mdb) y = 2
+x = 1 (free)
+(mdb) 3
+(mdb) 13
+`,
+		},
+		{
+			"errors",
+			[]string{"b 4", "b 99", "b x", "clear 2", "finish", "p (", "frobnicate", "q"},
+			`=> 1: let add = fn(a, b) {
+(mdb) no code at line 4
+(mdb) no code at line 99
+(mdb) invalid line number "x"
+(mdb) no breakpoint at line 2
+(mdb) finish is not meaningful in the outermost frame
+(mdb) syntax error: no prefix parse function for EOF found
+(mdb) unknown command "frobnicate", type help for a list of commands
+(mdb) `,
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.commands...)
+		if tt.commands[len(tt.commands)-1] == "q" {
+			if err != ErrQuit {
+				t.Errorf("%s: wrong error. got=%v, want=%v", tt.name, err, ErrQuit)
+			}
+		} else if err != nil {
+			t.Errorf("%s: vm error: %s", tt.name, err)
+		}
+
+		if out != tt.expected {
+			t.Errorf("%s: wrong output.\ngot:\n%s\nwant:\n%s", tt.name, out, tt.expected)
+		}
+	}
+}
+
+func TestDisasm(t *testing.T) {
+	out, _ := run(t, "b 3", "c", "disasm", "q")
+
+	expected := `add:
+   0000    2  OpGetLocal 0
+   0002    2  OpGetLocal 1
+   0004    2  OpAdd
+   0005    2  OpSetLocal 2
+=> 0007    3  OpGetLocal 2
+   0009    3  OpReturnValue
+`
+	if !strings.Contains(out, expected) {
+		t.Errorf("wrong disasm output.\ngot:\n%s\nwant:\n%s", out, expected)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tokensCommand monkey tokens [--json] <file>，输出词法分析的结果，注释按位置插入
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as a JSON array")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	source, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	tokens := lexTokens(string(source))
+	if *asJSON {
+		return printJSON(tokens)
+	}
+
+	for _, tok := range tokens {
+		fmt.Printf("%d:%d\t%s\t%s\n", tok.Line, tok.Column, tok.Type, strconv.Quote(tok.Literal))
+	}
+	return 0
+}
+
+// lexTokens 返回包括 EOF 和注释在内的所有 token
+func lexTokens(source string) []token.Token {
+	l := lexer.New(source)
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	for _, comment := range l.Comments() {
+		tokens = append(tokens, comment.Token)
+	}
+	sort.SliceStable(tokens, func(i, j int) bool {
+		if tokens[i].Line != tokens[j].Line {
+			return tokens[i].Line < tokens[j].Line
+		}
+		return tokens[i].Column < tokens[j].Column
+	})
+
+	return tokens
+}
+
+// astCommand monkey ast [--json] <file>，输出语法分析的结果
+// --json 的输出可以用 ast.DecodeProgramJSON 还原
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the AST as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	program, ok := parseFile(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+
+	if *asJSON {
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return printJSON(json.RawMessage(data))
+	}
+
+	depth := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+
+		tok := ast.TokenOf(node)
+		name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+		fmt.Printf("%s%s %d:%d %s\n", strings.Repeat("  ", depth), name, tok.Line, tok.Column, strconv.Quote(tok.Literal))
+		depth++
+		return true
+	})
+	return 0
+}
+
+// printJSON 以缩进的形式把 v 输出到标准输出
+func printJSON(v interface{}) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out.WriteByte('\n')
+
+	_, _ = out.WriteTo(os.Stdout)
+	return 0
+}
@@ -1,141 +1,233 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/limit"
 	"github.com/Shea11012/interpreter_in_go/object"
+	"math"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
+// Evaluator 保存一次求值过程中的状态，Limits 和 IO 需要在 Run 之前设置
+type Evaluator struct {
+	Limits limit.Limits
+	IO     *object.IO // 程序使用的标准输入输出，默认为进程的标准输入输出
+
+	builtins *builtin.Registry
+	runtime  *object.Runtime
+	meter    *limit.Meter
+	depth    int   // 当前函数调用深度
+	nesting  int   // 当前 Eval 的嵌套深度
+	err      error // 触发资源限制时记录的错误，记录后中止求值
+}
+
+func New() *Evaluator {
+	return NewWithRegistry(builtin.Default())
+}
+
+// NewWithRegistry 使用指定的内置函数注册表创建求值器
+func NewWithRegistry(registry *builtin.Registry) *Evaluator {
+	return &Evaluator{builtins: registry, IO: object.StdIO()}
+}
+
+// Eval 使用默认配置对 node 求值，触发资源限制时返回 *object.Error
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result, err := New().Run(context.Background(), node, env)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
+}
+
+// Run 对 node 求值，ctx 被取消或触发资源限制时中止求值并返回 limit 包中对应的错误
+func (e *Evaluator) Run(ctx context.Context, node ast.Node, env *object.Environment) (object.Object, error) {
+	e.meter = limit.NewMeter(ctx, e.Limits)
+	e.runtime = &object.Runtime{IO: e.IO, SizeLimit: e.meter.CheckSize, Call: e.call}
+	e.depth = 0
+	e.nesting = 0
+	e.err = nil
+
+	result := e.eval(node, env)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return result, nil
+}
+
+// abort 记录资源限制错误，返回的错误对象会沿着已有的错误处理逻辑向上传递
+func (e *Evaluator) abort(err error) object.Object {
+	e.err = err
+	return newError("%s", err)
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if e.err != nil {
+		return newError("%s", e.err)
+	}
+
+	if err := e.meter.Step(); err != nil {
+		return e.abort(err)
+	}
+
+	e.nesting++
+	defer func() { e.nesting-- }()
+	if max := e.meter.StackSizeLimit(math.MaxInt32); e.nesting > max {
+		return e.abort(&limit.StackSizeError{Limit: max})
+	}
+
 	switch nd := node.(type) {
 	// statements
 	case *ast.Program:
-		return evalProgram(nd, env)
+		return e.evalProgram(nd, env)
 	case *ast.ExpressionStatement:
-		return Eval(nd.Expression, env)
+		return e.eval(nd.Expression, env)
 	// expressions
 	case *ast.IntegerLiteral:
+		if nd.Big != nil {
+			return &object.BigInt{Value: nd.Big}
+		}
 		return &object.Integer{Value: nd.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(nd.Value)
 	case *ast.PrefixExpression:
-		right := Eval(nd.Right, env)
+		right := e.eval(nd.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(nd.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(nd.Left, env)
+		left := e.eval(nd.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(nd.Right, env)
+		right := e.eval(nd.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(nd.Operator, left, right)
+		return e.evalInfixExpression(nd.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(nd, env)
+		return e.evalBlockStatement(nd, env)
 	case *ast.IfExpression:
-		return evalIfExpression(nd, env)
+		return e.evalIfExpression(nd, env)
 	case *ast.ReturnStatement:
-		val := Eval(nd.ReturnValue, env)
+		val := e.eval(nd.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(nd.Value, env)
+		val := e.eval(nd.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(nd.Name.Value, val)
 	case *ast.Identifier:
-		return evalIdentifier(nd, env)
+		return e.evalIdentifier(nd, env)
 	case *ast.FunctionLiteral:
 		params := nd.Parameters
 		body := nd.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
+	case *ast.MacroLiteral:
+		return newError("macro literal outside of a top-level let statement")
 	case *ast.CallExpression:
-		function := Eval(nd.Function, env)
+		if isCallTo(nd, quoteName) {
+			return e.quote(nd, env)
+		}
+
+		function := e.eval(nd.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(nd.Arguments, env)
+		args := e.evalExpressions(nd.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return e.applyFunction(function, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: nd.Value}
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(nd.Elements, env)
+		elements := e.evalExpressions(nd.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 
+		if err := e.meter.CheckSize(len(elements)); err != nil {
+			return e.abort(err)
+		}
+
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(nd.Left, env)
+		left := e.eval(nd.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(nd.Index, env)
+		index := e.eval(nd.Index, env)
 		if isError(index) {
 			return index
 		}
 
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(nd, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(nd, env)
+		return e.evalHashLiteral(nd, env)
 	}
 
 	return nil
 }
 
 // evalHashLiteral 执行hash表达式
-func evalHashLiteral(hash *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(hash *ast.HashLiteral, env *object.Environment) object.Object {
+	result := object.NewHash(len(hash.Pairs))
 
-	for keyNode, valueNode := range hash.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range hash.Pairs {
+		key := e.eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashed, ok := object.HashKeyOf(key)
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		result.Set(hashed, object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	if err := e.meter.CheckSize(result.Len()); err != nil {
+		return e.abort(err)
+	}
+
+	return result
 }
 
 // evalIndexExpression 索引判断left和index类型
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+	case (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ) && index.Type() == object.INTEGER_OBJ:
+		result, _ := object.ElementAt(left, index)
+		return result
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -146,12 +238,12 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 // evalHashIndexExpression 执行hash索引
 func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
@@ -159,35 +251,73 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	return pair.Value
 }
 
-// evalArrayIndexExpression 索引访问数组
-func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
-	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+// evalSliceExpression 执行切片表达式，省略的边界按 null 处理
+func (e *Evaluator) evalSliceExpression(slice *ast.SliceExpression, env *object.Environment) object.Object {
+	left := e.eval(slice.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := make([]object.Object, 2)
+	for i, bound := range []ast.Expression{slice.Low, slice.High} {
+		if bound == nil {
+			bounds[i] = NULL
+			continue
+		}
 
-	if idx < 0 || idx > max {
-		return NULL
+		bounds[i] = e.eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
 	}
 
-	return arrayObject.Elements[idx]
+	result, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if max := e.meter.CallDepthLimit(limit.DefaultMaxCallDepth); e.depth >= max {
+			return e.abort(&limit.CallDepthError{Limit: max})
+		}
+
+		e.depth++
+		defer func() { e.depth-- }()
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
-			return result
+		result := fn.Fn(e.runtime, args...)
+		if result == nil {
+			return NULL
 		}
-		return NULL
+
+		if err := e.meter.CheckObject(result); err != nil {
+			return e.abort(err)
+		}
+
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// call 实现 object.Runtime.Call，函数的结果为错误对象时作为错误返回
+func (e *Evaluator) call(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := e.applyFunction(fn, args)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+
+	return result, nil
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -204,10 +334,10 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 	return env
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -218,22 +348,22 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
+	if fn, ok := e.builtins.Lookup(node.Value); ok {
+		return fn
 	}
 
 	return newError("identifier not found: " + node.Value)
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -246,16 +376,16 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(exp.Condition, env)
+func (e *Evaluator) evalIfExpression(exp *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(exp.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(exp.Consequence, env)
+		return e.eval(exp.Consequence, env)
 	} else if exp.Alternative != nil {
-		return Eval(exp.Alternative, env)
+		return e.eval(exp.Alternative, env)
 	} else {
 		return NULL
 	}
@@ -274,53 +404,67 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "<" || operator == ">":
+		return evalOrderingExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return e.evalStringInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func (e *Evaluator) evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
+	if err := e.meter.CheckSize(len(leftVal) + len(rightVal)); err != nil {
+		return e.abort(err)
+	}
+
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// evalOrderingExpression 比较字符串、数组等可排序对象的大小
+func evalOrderingExpression(operator string, left object.Object, right object.Object) object.Object {
+	result, ok := object.Compare(left, right)
+	if !ok {
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	if operator == "<" {
+		return nativeBoolToBooleanObject(result < 0)
+	}
+	return nativeBoolToBooleanObject(result > 0)
+}
+
+// evalIntegerInfixExpression 整数运算，溢出时结果自动提升为 BigInt
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "+", "-", "*", "/", "%":
+		result, err := object.IntegerArithmetic(operator, left, right)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
+	case "<", ">":
+		return evalOrderingExpression(operator, left, right)
 	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -338,12 +482,12 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(value object.Object) object.Object {
-	if value.Type() != object.INTEGER_OBJ {
+	result, ok := object.NegateInteger(value)
+	if !ok {
 		return newError("unknown operator: -%s", value.Type())
 	}
 
-	v := value.(*object.Integer).Value
-	return &object.Integer{Value: -v}
+	return result
 }
 
 func evalBangOperatorExpression(value object.Object) object.Object {
@@ -367,10 +511,10 @@ func nativeBoolToBooleanObject(value bool) *object.Boolean {
 	return FALSE
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/builtin"
 	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/limit"
 	"github.com/Shea11012/interpreter_in_go/object"
 	"github.com/Shea11012/interpreter_in_go/parser"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -330,7 +337,8 @@ func TestArrayIndexExpressions(t *testing.T) {
 		{"let myArray = [1,2,3];myArray[0] + myArray[1] + myArray[2];", 6},
 		{"let myArray = [1,2,3]; let i = myArray[0]; myArray[i]", 2},
 		{"[1,2,3][3]", nil},
-		{"[1,2,3][-1]", nil},
+		{"[1,2,3][-1]", 3},
+		{"[1,2,3][-4]", nil},
 	}
 
 	for i, tt := range tests {
@@ -376,12 +384,12 @@ func TestHashLiterals(t *testing.T) {
 		FALSE.HashKey():                            6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("hash was wrong num of pairs got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("hash was wrong num of pairs got=%d", result.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for give key in pairs")
 		}
@@ -415,6 +423,62 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   limit.Limits
+		expected error
+	}{
+		{`let f = fn(x) { f(x + 1) }; f(0);`, limit.Limits{}, &limit.CallDepthError{}},
+		{`let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(20);`, limit.Limits{MaxCallDepth: 10}, &limit.CallDepthError{}},
+		{`1 + 2 + 3 + 4 + 5`, limit.Limits{MaxInstructions: 5}, &limit.InstructionLimitError{}},
+		{`((((1 + 2) + 3) + 4) + 5)`, limit.Limits{MaxStackSize: 4}, &limit.StackSizeError{}},
+		{`[1, 2, 3, 4, 5]`, limit.Limits{MaxCollectionSize: 4}, &limit.CollectionSizeError{}},
+		{`{1: 1, 2: 2, 3: 3}`, limit.Limits{MaxCollectionSize: 2}, &limit.CollectionSizeError{}},
+		{`"hello" + " world"`, limit.Limits{MaxCollectionSize: 5}, &limit.CollectionSizeError{}},
+		{`push([1, 2], 3)`, limit.Limits{MaxCollectionSize: 2}, &limit.CollectionSizeError{}},
+		{`map([1, 2, 3], fn(x) { let f = fn(y) { f(y) }; f(x) })`, limit.Limits{MaxCallDepth: 50}, &limit.CallDepthError{}},
+		{`let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(20);`, limit.Limits{MaxCallDepth: 21}, nil},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		e := New()
+		e.Limits = tt.limits
+		_, err := e.Run(context.Background(), program, object.NewEnvironment())
+		if tt.expected == nil {
+			if err != nil {
+				t.Errorf("unexpected error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+
+		if reflect.TypeOf(err) != reflect.TypeOf(tt.expected) {
+			t.Errorf("wrong error type for %q. want=%T, got=%T (%v)", tt.input, tt.expected, err, err)
+		}
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	input := `
+	let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+	fib(30);
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := New().Run(ctx, program, object.NewEnvironment())
+	var canceled *limit.CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("expected CanceledError, got=%T (%v)", err, err)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error to wrap context.Canceled, got=%v", err)
+	}
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL got=%T (%+v)", obj, obj)
@@ -462,3 +526,288 @@ func testEval(input string) object.Object {
 
 	return Eval(program, env)
 }
+
+func TestBuiltinRegistry(t *testing.T) {
+	registry := builtin.NewPreset(builtin.PresetNoIO)
+	registry.Register(builtin.MustFromFunc("double", func(n int64) int64 { return n * 2 }))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`double(len([1, 2, 3]))`, 6},
+		{`reduce(map([1, 2], fn(x) { double(x) }), 0, fn(x, y) { x + y })`, 6},
+		{`puts(1)`, "identifier not found: puts"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated, err := NewWithRegistry(registry).Run(context.Background(), program, object.NewEnvironment())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("expected error %q, got=%+v", expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestRestrictedRegistry(t *testing.T) {
+	// map 和 reduce 与其他内置函数一样来自注册表，可以被替换或移除
+	registry := builtin.NewPreset(builtin.PresetNone)
+	registry.Register(builtin.MustFromFunc("map", func(n int64) int64 { return -n }))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map(1)`, -1},
+		{`reduce([1, 2], 0, fn(x, y) { x + y })`, "identifier not found: reduce"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated, err := NewWithRegistry(registry).Run(context.Background(), program, object.NewEnvironment())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("expected error %q, got=%+v", expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestRuntimeCall(t *testing.T) {
+	// try 调用函数，出错时返回错误信息
+	registry := builtin.Default()
+	registry.Register(builtin.BuiltinFn{
+		Name: "try",
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			result, err := rt.Call(args[0], args[1:]...)
+			if err != nil {
+				return &object.String{Value: "error: " + err.Error()}
+			}
+			return result
+		}},
+	})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try(fn(x) { x + 1 }, 1)`, 2},
+		{`try(len, "abc")`, 3},
+		{`try(fn() { 1 + "a" })`, "error: type mismatch: INTEGER + STRING"},
+		{`try(fn() { try(fn() { -true }) })`, "error: unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated, err := NewWithRegistry(registry).Run(context.Background(), program, object.NewEnvironment())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q, got=%s", expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestProgramIO(t *testing.T) {
+	input := `
+	let name = read_line();
+	puts("hello " + name);
+	print("a", 1);
+	eprint("oops");
+	read_all();
+`
+	var stdout, stderr bytes.Buffer
+	e := New()
+	e.IO = object.NewIO(strings.NewReader("monkey\nrest"), &stdout, &stderr)
+	program := parser.New(lexer.New(input)).ParseProgram()
+	result, err := e.Run(context.Background(), program, object.NewEnvironment())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stdout.String() != "hello monkey\na1" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+
+	if stderr.String() != "oops" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+
+	if result.Inspect() != "rest" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("世界")`, "2"},
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`join(split("a b c", " "), "-")`, "a-b-c"},
+		{`upper(trim("  monkey "))`, "MONKEY"},
+		{`index_of("世界 hello", "hello")`, "3"},
+		{`substr("世界 hello", 1, 3)`, "界 h"},
+		{`chars("a世")`, "[a, 世]"},
+		{`format("%s=%d %v", "x", 1, [true])`, "x=1 [true]"},
+		{`format("%d", "x")`, "ERROR: format: %d expects INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({1: 2, 3: 4})`, "2"},
+		{`keys({"a": 1})`, "[a]"},
+		{`values({"a": 1})`, "[1]"},
+		{`entries({"a": 1})`, "[[a, 1]]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [len(h), len(d), has_key(d, "a")]`, "[2, 1, false]"},
+		{`merge({"a": 1}, {"a": 2})["a"]`, "2"},
+		{`has_key({}, [fn(x) { x }])`, "ERROR: unusable as hash key: ARRAY"},
+		{`{"b": 1, "a": 2, 10: 3, 2: 4}`, "{b: 1, a: 2, 10: 3, 2: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`keys({"z": 1, "y": 2, "x": 3})`, "[z, y, x]"},
+		{`values(delete({"z": 1, "y": 2, "x": 3}, "y"))`, "[1, 3]"},
+		{`merge({"z": 1, "y": 2}, {"a": 3, "z": 4})`, "{z: 4, y: 2, a: 3}"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStructuralComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" == "a"`, "true"},
+		{`"a" != "a"`, "false"},
+		{`"a" == "b"`, "false"},
+		{`"a" < "b"`, "true"},
+		{`"abc" > "abd"`, "false"},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, "true"},
+		{`[1, 2] != [1, 3]`, "true"},
+		{`[1, 2] < [1, 3]`, "true"},
+		{`[1, 2, 0] > [1, 2]`, "true"},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 2}`, "false"},
+		{`1 == "1"`, "false"},
+		{`let f = fn() { 1 }; [f == f, fn() { 1 } == fn() { 1 }]`, "[true, false]"},
+		{`{[1, "a"]: "found"}[[1, "a"]]`, "found"},
+		{`{[1, "a"]: "found"}[[1, "b"]]`, "null"},
+		{`[true] > [false]`, "ERROR: unknown operator: ARRAY > ARRAY"},
+		{`{} < {}`, "ERROR: unknown operator: HASH < HASH"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][-10:10]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][-4]`, "null"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, "null"},
+		{`"héllo wörld"[2:5]`, "llo"},
+		{`"héllo wörld"[6:]`, "wörld"},
+		{`"héllo"[4:2]`, ""},
+		{`let a = [1, 2, 3]; let b = a[:]; [a == b, len(a[1:])]`, "[true, 2]"},
+		{`[1, 2]["a":]`, "ERROR: slice index must be INTEGER, got STRING"},
+		{`{}[1:2]`, "ERROR: slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`9223372036854775807 + 1`, "9223372036854775808"},
+		{`18446744073709551616`, "18446744073709551616"},
+		{`18446744073709551616 / 2 - 1`, "9223372036854775807"},
+		{`-9223372036854775808 == -9223372036854775807 - 1`, "true"},
+		{`-9223372036854775807 - 2`, "-9223372036854775809"},
+		{`-(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`let big = 9223372036854775807 * 4; big / 4`, "9223372036854775807"},
+		{`(9223372036854775807 + 1) - 1 == 9223372036854775807`, "true"},
+		{`(9223372036854775807 + 1) > 9223372036854775807`, "true"},
+		{`let f = fn(n) { if (n < 1) { 1 } else { n * f(n - 1) } }; f(25)`, "15511210043330985984000000"},
+		{`let k = 9223372036854775807 + 1; {k: "big"}[9223372036854775807 + 1]`, "big"},
+		{`{(9223372036854775807 + 1) * 2: "big"}[0 - 1300789964862373523]`, "null"},
+		{`has_key({(9223372036854775807 + 1) * 2: "big"}, 0 - 1300789964862373523)`, "false"},
+		{`[1, 2, 3][9223372036854775807 + 1]`, "null"},
+		{`7 % 3`, "1"},
+		{`-7 % 3`, "-1"},
+		{`(9223372036854775807 * 3) % 10`, "1"},
+		{`1 / 0`, "ERROR: division by zero"},
+		{`1 % 0`, "ERROR: division by zero"},
+		{`(9223372036854775807 + 1) / 0`, "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/object"
+)
+
+// DefineMacros 把程序顶层 let name = macro(...) 定义的宏保存到 env 中，并从程序中删除这些语句
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		macro, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{
+			Parameters: macro.Parameters,
+			Body:       macro.Body,
+			Env:        env,
+		})
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros 使用默认配置展开 program 中的宏调用，env 为 DefineMacros 使用的环境
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return New().ExpandMacros(context.Background(), program, env)
+}
+
+// ExpandMacros 展开 program 中对 env 中宏的调用，会直接修改传入的 program
+//
+// 宏的参数是没有求值的 AST(*object.Quote)，宏体的执行结果必须是 quote 返回的 AST，用它替换宏调用。
+// 宏体的执行受 Limits 限制，参数中的宏调用先展开，展开的结果不会再次展开
+func (e *Evaluator) ExpandMacros(ctx context.Context, program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if err != nil || !ok {
+			return node
+		}
+
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+
+		obj, ok := env.Get(ident.Value)
+		if !ok {
+			return node
+		}
+
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			return node
+		}
+
+		var result ast.Node
+		result, err = e.expandMacro(ctx, ident.Value, macro, call.Arguments)
+		if err != nil {
+			return node
+		}
+		return result
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expanded, nil
+}
+
+// expandMacro 执行一次宏调用，返回替换调用的 AST
+func (e *Evaluator) expandMacro(ctx context.Context, name string, macro *object.Macro, args []ast.Expression) (ast.Node, error) {
+	if len(args) != len(macro.Parameters) {
+		return nil, fmt.Errorf("wrong number of arguments to macro `%s`. got=%d, want=%d",
+			name, len(args), len(macro.Parameters))
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: args[i]})
+	}
+
+	evaluated, err := e.Run(ctx, macro.Body, env)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated = unwrapReturnValue(evaluated)
+	if evalErr, ok := evaluated.(*object.Error); ok {
+		return nil, fmt.Errorf("macro `%s`: %s", name, evalErr.Message)
+	}
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		got := "nothing"
+		if evaluated != nil {
+			got = string(evaluated.Type())
+		}
+		return nil, fmt.Errorf("macro `%s` must return a quoted AST node, got %s", name, got)
+	}
+
+	return quote.Node, nil
+}
@@ -0,0 +1,107 @@
+package evaluator
+
+import (
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"strconv"
+)
+
+const (
+	quoteName   = "quote"
+	unquoteName = "unquote"
+)
+
+// isCallTo 判断 call 是否是对名为 name 的标识符的调用
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// quote 返回参数没有求值的 AST，其中的 unquote(x) 会被替换为 x 求值的结果
+// quote 所在的函数可能被多次调用，替换在参数的副本上进行
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments to `%s`. got=%d, want=1", quoteName, len(call.Arguments))
+	}
+
+	var err object.Object
+	node := ast.Modify(ast.Copy(call.Arguments[0]), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if err != nil || !ok || !isCallTo(call, unquoteName) {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments to `%s`. got=%d, want=1", unquoteName, len(call.Arguments))
+			return node
+		}
+
+		unquoted := e.eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted
+			return node
+		}
+
+		result, ok := objectToExpression(unquoted)
+		if !ok {
+			err = newError("cannot unquote %s", unquoted.Type())
+			return node
+		}
+		return result
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// objectToExpression 把 unquote 的结果转换为可以放回 AST 中的表达式
+func objectToExpression(obj object.Object) (ast.Expression, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		literal := strconv.FormatInt(obj.Value, 10)
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal}, Value: obj.Value}, true
+	case *object.BigInt:
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: obj.Value.String()}, Big: obj.Value}, true
+	case *object.Boolean:
+		tok := token.Token{Type: token.FALSE, Literal: "false"}
+		if obj.Value {
+			tok = token.Token{Type: token.TRUE, Literal: "true"}
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, true
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: obj.Value}, Value: obj.Value}, true
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		for _, el := range obj.Elements {
+			exp, ok := objectToExpression(el)
+			if !ok {
+				return nil, false
+			}
+			array.Elements = append(array.Elements, exp)
+		}
+		return array, true
+	case *object.Hash:
+		hash := &ast.HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+		for _, pair := range obj.OrderedPairs() {
+			key, ok := objectToExpression(pair.Key)
+			if !ok {
+				return nil, false
+			}
+			value, ok := objectToExpression(pair.Value)
+			if !ok {
+				return nil, false
+			}
+			hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+		}
+		return hash, true
+	case *object.Quote:
+		exp, ok := obj.Node.(ast.Expression)
+		return exp, ok
+	}
+
+	return nil, false
+}
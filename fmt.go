@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/format"
+	"io/ioutil"
+	"os"
+)
+
+// fmtCommand monkey fmt [-d] <file>...，格式化文件
+// 使用 -d 时不修改文件，只输出 diff，有文件需要格式化时退出码为 1
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	diff := flags.Bool("d", false, "print a diff instead of rewriting the files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		if !formatFile(filename, *diff) {
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// formatFile 格式化一个文件，出错或 -d 时文件需要格式化返回 false
+func formatFile(filename string, diff bool) bool {
+	info, err := os.Stat(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	formatted, err := format.Source(source)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return false
+	}
+
+	if bytes.Equal(source, formatted) {
+		return true
+	}
+
+	if diff {
+		_, _ = os.Stdout.Write(format.Diff(filename+".orig", filename, source, formatted))
+		return false
+	}
+
+	if err := ioutil.WriteFile(filename, formatted, info.Mode()); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	return true
+}
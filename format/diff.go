@@ -0,0 +1,148 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext unified diff 中每个改动前后保留的行数
+const diffContext = 3
+
+type diffLine struct {
+	kind byte // ' '、'-' 或 '+'
+	text string
+}
+
+// Diff 返回从 a 到 b 的 unified diff，内容相同时返回 nil
+func Diff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	lines := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// oldPos[i] 和 newPos[i] 为 lines[i] 之前两边各有多少行
+	oldPos := make([]int, len(lines)+1)
+	newPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if l.kind != '+' {
+			oldPos[i+1]++
+		}
+		if l.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+
+		// 两处改动之间的相同行不超过 2*diffContext 时合并为一个 hunk
+		end := i
+		for end < len(lines) {
+			for end < len(lines) && lines[end].kind != ' ' {
+				end++
+			}
+			same := end
+			for same < len(lines) && lines[same].kind == ' ' {
+				same++
+			}
+			if same == len(lines) || same-end > 2*diffContext {
+				end += diffContext
+				if end > same {
+					end = same
+				}
+				break
+			}
+			end = same
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[end]), hunkRange(newPos[start], newPos[end]))
+		for _, l := range lines[start:end] {
+			out.WriteByte(l.kind)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return out.Bytes()
+}
+
+// hunkRange 返回 hunk 头中的行范围，from 和 to 为从 0 开始的半开区间
+func hunkRange(from, to int) string {
+	if to-from == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines 按行切分，每行保留结尾的换行符
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines 用最长公共子序列计算两组行之间的差异
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] 为 a[i:] 和 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,370 @@
+package format
+
+import (
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	indentWidth = 4  // 每一级缩进的空格数
+	maxWidth    = 80 // 参数、数组元素和键值对超过这个宽度时每个占一行
+)
+
+// Source 把源码格式化为规范的形式，源码有语法错误时返回错误
+//
+// 每条语句占一行并以 ; 结尾，代码块缩进 4 个空格，按优先级只保留必要的括号，
+// 语句之间的多个空行合并为一个，注释保留在原来的语句前后
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := &printer{
+		lines:    strings.Split(string(src), "\n"),
+		comments: program.Comments,
+	}
+
+	return []byte(pr.statements(program.Statements, token.Token{})), nil
+}
+
+type printer struct {
+	lines    []string        // 源码的每一行，用于判断空行和注释是否独占一行
+	comments []lexer.Comment // 还没有输出的注释
+	indent   int             // 当前的缩进级数
+	lastLine int             // 已经输出的 token 在源码中的最大行号
+}
+
+// statements 输出语句列表和其中的注释，end 为列表结束的位置，行号为 0 时表示文件结尾
+func (p *printer) statements(stmts []ast.Statement, end token.Token) string {
+	var out strings.Builder
+	prevLine := 0
+
+	// writeLine 输出一行，源码中与上一行之间有空行时保留一个空行
+	writeLine := func(text string, line int) {
+		if prevLine > 0 && line-1 > prevLine && p.blank(line-1) {
+			out.WriteString("\n")
+		}
+		out.WriteString(p.indentation() + text + "\n")
+	}
+
+	for i, stmt := range stmts {
+		start := ast.TokenOf(stmt)
+		for len(p.comments) > 0 && before(p.comments[0].Token, start) {
+			writeLine(p.comment(p.comments[0]), p.comments[0].Line)
+			prevLine = p.comments[0].Line
+			p.comments = p.comments[1:]
+		}
+
+		next := end
+		if i+1 < len(stmts) {
+			next = ast.TokenOf(stmts[i+1])
+		}
+
+		p.lastLine = start.Line
+		text := p.statement(stmt)
+		after := p.trailingComments(next)
+		if len(after) > 0 && !p.ownLine(after[0].Token) {
+			text += " " + p.comment(after[0])
+			after = after[1:]
+		}
+
+		writeLine(text, start.Line)
+		prevLine = p.lastLine
+		for _, c := range after {
+			out.WriteString(p.indentation() + p.comment(c) + "\n")
+			prevLine = c.Line
+		}
+	}
+
+	for len(p.comments) > 0 && before(p.comments[0].Token, end) {
+		writeLine(p.comment(p.comments[0]), p.comments[0].Line)
+		prevLine = p.comments[0].Line
+		p.comments = p.comments[1:]
+	}
+
+	return out.String()
+}
+
+// trailingComments 取出应当跟在当前语句后面的注释：与语句代码同一行的注释，以及语句内部的注释，
+// 位于 next 之前的其他注释留给下一条语句
+func (p *printer) trailingComments(next token.Token) []lexer.Comment {
+	n := 0
+	for i, c := range p.comments {
+		if !before(c.Token, next) {
+			break
+		}
+		if c.Line <= p.lastLine || !p.ownLine(c.Token) {
+			n = i + 1
+		}
+	}
+
+	after := p.comments[:n]
+	p.comments = p.comments[n:]
+	for _, c := range after {
+		p.see(c.Token)
+	}
+
+	return after
+}
+
+func (p *printer) statement(stmt ast.Statement) string {
+	col := p.indent * indentWidth
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		prefix := "let " + stmt.Name.Value + " = "
+		return prefix + p.expression(stmt.Value, col+len(prefix)) + ";"
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			return "return;"
+		}
+		return "return " + p.expression(stmt.ReturnValue, col+len("return ")) + ";"
+	case *ast.ExpressionStatement:
+		return p.expression(stmt.Expression, col) + ";"
+	case *ast.BlockStatement:
+		return p.block(stmt)
+	}
+
+	return ""
+}
+
+// block 输出代码块，其中的语句比当前多缩进一级
+func (p *printer) block(block *ast.BlockStatement) string {
+	p.indent++
+	body := p.statements(block.Statements, block.Rbrace)
+	p.indent--
+	p.see(block.Rbrace)
+
+	if body == "" {
+		return "{}"
+	}
+	return "{\n" + body + p.indentation() + "}"
+}
+
+// expression 输出表达式，col 为表达式开始时所在的列，用于决定列表是否换行
+func (p *printer) expression(exp ast.Expression, col int) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.see(exp.Token)
+		return exp.Value
+	case *ast.IntegerLiteral:
+		p.see(exp.Token)
+		if exp.Token.Literal != "" {
+			return exp.Token.Literal
+		}
+		if exp.Big != nil {
+			return exp.Big.String()
+		}
+		return strconv.FormatInt(exp.Value, 10)
+	case *ast.StringLiteral:
+		p.see(exp.Token)
+		return `"` + exp.Value + `"`
+	case *ast.Boolean:
+		p.see(exp.Token)
+		return strconv.FormatBool(exp.Value)
+	case *ast.PrefixExpression:
+		p.see(exp.Token)
+		right := p.operand(exp.Right, parser.PREFIX, col+len(exp.Operator))
+		if exp.Operator == "-" && strings.HasPrefix(right, "-") {
+			// 避免输出 --x
+			right = "(" + right + ")"
+		}
+		return exp.Operator + right
+	case *ast.InfixExpression:
+		p.see(exp.Token)
+		precedence := parser.Precedence(token.Type(exp.Operator))
+		left := p.operand(exp.Left, precedence, col)
+		// 中缀操作符都是左结合的，右边优先级相同时也需要括号
+		middle := " " + exp.Operator + " "
+		right := p.operand(exp.Right, precedence+1, advance(col, left+middle))
+		return left + middle + right
+	case *ast.IfExpression:
+		p.see(exp.Token)
+		condition := p.expression(exp.Condition, col+len("if ("))
+		out := "if (" + condition + ") " + p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			out += " else " + p.block(exp.Alternative)
+		}
+		return out
+	case *ast.FunctionLiteral:
+		p.see(exp.Token)
+		return p.function("fn", exp.Parameters, exp.Body, col)
+	case *ast.MacroLiteral:
+		p.see(exp.Token)
+		return p.function("macro", exp.Parameters, exp.Body, col)
+	case *ast.CallExpression:
+		function := p.operand(exp.Function, parser.CALL, col)
+		p.see(exp.Token)
+		return function + p.list("(", ")", p.items(exp.Arguments), advance(col, function))
+	case *ast.ArrayLiteral:
+		p.see(exp.Token)
+		return p.list("[", "]", p.items(exp.Elements), col)
+	case *ast.HashLiteral:
+		p.see(exp.Token)
+		pairs := make([]func(int) string, 0, len(exp.Pairs))
+		for _, pair := range exp.Pairs {
+			pair := pair
+			pairs = append(pairs, func(col int) string {
+				key := p.expression(pair.Key, col) + ": "
+				return key + p.expression(pair.Value, advance(col, key))
+			})
+		}
+		return p.list("{", "}", pairs, col)
+	case *ast.IndexExpression:
+		left := p.operand(exp.Left, parser.INDEX, col)
+		p.see(exp.Token)
+		return left + "[" + p.expression(exp.Index, advance(col, left+"[")) + "]"
+	case *ast.SliceExpression:
+		left := p.operand(exp.Left, parser.INDEX, col)
+		p.see(exp.Token)
+		out := left + "["
+		if exp.Low != nil {
+			out += p.expression(exp.Low, advance(col, out))
+		}
+		out += ":"
+		if exp.High != nil {
+			out += p.expression(exp.High, advance(col, out))
+		}
+		return out + "]"
+	}
+
+	return ""
+}
+
+// function 输出函数或宏
+func (p *printer) function(keyword string, parameters []*ast.Identifier, body *ast.BlockStatement, col int) string {
+	params := make([]func(int) string, 0, len(parameters))
+	for _, param := range parameters {
+		name := param.Value
+		params = append(params, func(int) string { return name })
+	}
+
+	return keyword + p.list("(", ")", params, col+len(keyword)) + " " + p.block(body)
+}
+
+// operand 输出作为操作数的表达式，优先级低于 precedence 时加上括号
+func (p *printer) operand(exp ast.Expression, precedence int, col int) string {
+	if expressionPrecedence(exp) >= precedence {
+		return p.expression(exp, col)
+	}
+
+	return "(" + p.expression(exp, col+1) + ")"
+}
+
+// expressionPrecedence 表达式作为操作数时的优先级，与 parser 解析时使用的优先级一致
+func expressionPrecedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.Type(exp.Operator))
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+
+	return parser.INDEX
+}
+
+func (p *printer) items(exps []ast.Expression) []func(int) string {
+	items := make([]func(int) string, 0, len(exps))
+	for _, exp := range exps {
+		exp := exp
+		items = append(items, func(col int) string { return p.expression(exp, col) })
+	}
+
+	return items
+}
+
+// list 输出参数、数组元素或键值对列表
+// 能放在一行时放在一行，只有最后一个元素跨多行时也放在一行，否则每个元素占一行
+func (p *printer) list(open, close string, items []func(int) string, col int) string {
+	// 尝试放在一行前记录状态，放不下时恢复后重新输出
+	comments, lastLine := p.comments, p.lastLine
+
+	col += len(open)
+	rendered := make([]string, 0, len(items))
+	fit := true
+	for i, item := range items {
+		text := item(col)
+		if i < len(items)-1 {
+			text += ", "
+		}
+		rendered = append(rendered, text)
+
+		// 跨多行的元素只能是最后一个，且每个元素的第一行都不能超过宽度
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			fit = fit && i == len(items)-1 && col+utf8.RuneCountInString(text[:newline]) <= maxWidth
+		}
+		col = advance(col, text)
+	}
+
+	if fit && col+len(close) <= maxWidth {
+		return open + strings.Join(rendered, "") + close
+	}
+
+	p.comments, p.lastLine = comments, lastLine
+	p.indent++
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, p.indentation()+item(p.indent*indentWidth))
+	}
+	p.indent--
+
+	return open + "\n" + strings.Join(lines, ",\n") + "\n" + p.indentation() + close
+}
+
+// advance 返回输出 s 之后所在的列
+func advance(col int, s string) int {
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return utf8.RuneCountInString(s[i+1:])
+	}
+
+	return col + utf8.RuneCountInString(s)
+}
+
+func (p *printer) indentation() string {
+	return strings.Repeat(" ", p.indent*indentWidth)
+}
+
+// see 记录已经输出的 token 所在的行
+func (p *printer) see(tok token.Token) {
+	if tok.Line > p.lastLine {
+		p.lastLine = tok.Line
+	}
+}
+
+func (p *printer) comment(c lexer.Comment) string {
+	return strings.TrimRight(c.Literal, " \t\r")
+}
+
+// blank 判断源码中的第 line 行是否为空行
+func (p *printer) blank(line int) bool {
+	return line >= 1 && line <= len(p.lines) && strings.TrimSpace(p.lines[line-1]) == ""
+}
+
+// ownLine 判断注释在源码中是否独占一行
+func (p *printer) ownLine(c token.Token) bool {
+	if c.Line < 1 || c.Line > len(p.lines) {
+		return true
+	}
+
+	line := p.lines[c.Line-1]
+	if c.Column-1 > len(line) {
+		return true
+	}
+	return strings.TrimSpace(line[:c.Column-1]) == ""
+}
+
+// before 判断注释是否在 tok 之前，tok 的行号为 0 时表示文件结尾
+func before(c token.Token, tok token.Token) bool {
+	if tok.Line == 0 {
+		return true
+	}
+
+	return c.Line < tok.Line || c.Line == tok.Line && c.Column < tok.Column
+}
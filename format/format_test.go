@@ -0,0 +1,129 @@
+package format
+
+import (
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let   x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(-x); !(a == b); (-a)[0]; -a[0]; !!x", "-(-x);\n!(a == b);\n(-a)[0];\n-a[0];\n!!x;\n"},
+		{"a[1:]; a[:2]; a[ 1 : 2 ]", "a[1:];\na[:2];\na[1:2];\n"},
+		{`{"a":1,"b":[1,2]}; {}; []`, "{\"a\": 1, \"b\": [1, 2]};\n{};\n[];\n"},
+		{
+			"let add = fn(a,b){ return a+b }",
+			"let add = fn(a, b) {\n    return a + b;\n};\n",
+		},
+		{"let f = fn() {}", "let f = fn() {};\n"},
+		{
+			"let m = macro(a){quote(unquote(a)+1)}",
+			"let m = macro(a) {\n    quote(unquote(a) + 1);\n};\n",
+		},
+		{
+			"if (x > 1) { puts(1) } else { if (x) { 2 } }",
+			"if (x > 1) {\n    puts(1);\n} else {\n    if (x) {\n        2;\n    };\n};\n",
+		},
+		{
+			"map([1,2], fn(x){x*2})",
+			"map([1, 2], fn(x) {\n    x * 2;\n});\n",
+		},
+		{
+			"let result = some_function_name(first_argument_value, second_argument_value, third);",
+			"let result = some_function_name(\n    first_argument_value,\n    second_argument_value,\n    third\n);\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2; let c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"// header\nlet a = 1; // trailing\n\n// before b\nlet b = 2;\n// end",
+			"// header\nlet a = 1; // trailing\n\n// before b\nlet b = 2;\n// end\n",
+		},
+		{
+			"let f = fn() {\n  1\n  // before close\n}; // after\nf()",
+			"let f = fn() {\n    1;\n    // before close\n}; // after\nf();\n",
+		},
+		{"fn() {\n// only\n}", "fn() {\n    // only\n};\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		output, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("Source(%q) returned error: %s", tt.input, err)
+		}
+
+		if string(output) != tt.expected {
+			t.Errorf("wrong output for %q.\ngot=\n%s\nwant=\n%s", tt.input, output, tt.expected)
+		}
+
+		// 格式化是幂等的，并且不改变程序的含义
+		again, err := Source(output)
+		if err != nil {
+			t.Fatalf("formatted source does not parse: %s\n%s", err, output)
+		}
+		if string(again) != string(output) {
+			t.Errorf("formatting is not idempotent.\nfirst=\n%s\nsecond=\n%s", output, again)
+		}
+		if parse(t, tt.input) != parse(t, string(output)) {
+			t.Errorf("formatting changed the program %q.\ngot=\n%s", tt.input, output)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := Source([]byte("let = 1;")); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if d := Diff("a", "b", []byte("x\n"), []byte("x\n")); d != nil {
+		t.Errorf("expected no diff, got=%q", d)
+	}
+
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{
+			"1\n2\n3\n",
+			"1\nx\n3\n",
+			"--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+x\n 3\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,4 +8,3 @@\n 7\n 8\n 9\n-10\n",
+		},
+		{
+			"x",
+			"x\n",
+			"--- a\n+++ b\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+x\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if d := string(Diff("a", "b", []byte(tt.a), []byte(tt.b))); d != tt.expected {
+			t.Errorf("wrong diff.\ngot=\n%s\nwant=\n%s", d, tt.expected)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program.String()
+}
@@ -9,37 +9,68 @@ type Lexer struct {
 	position     int  // 当前字符所在位置
 	readPosition int  // 下一个字符所在位置
 	ch           byte // 当前字符
+	line         int  // 当前字符所在行
+	column       int  // 当前字符所在列
+	tokenLine    int  // 上一个 token 所在行
+
+	comments []Comment // 已经读过的注释
+}
+
+// Comment 是读到的一条注释
+type Comment struct {
+	token.Token
+	// Trailing 为 true 时注释前面同一行有代码，否则注释单独占一行
+	Trailing bool `json:"trailing,omitempty"`
 }
 
 func New(input string) *Lexer {
 	l := &Lexer{
 		input: input,
+		line:  1,
 	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	l.ch = l.peekChar()
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column++
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+// Comments 返回到目前为止读过的注释，按出现顺序排列
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
 
-	// 过滤空格、回车、tab
+func (l *Lexer) NextToken() token.Token {
+	// 过滤空格、回车、tab 和注释
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	tok := l.nextToken()
+	tok.Line = line
+	tok.Column = column
+	l.tokenLine = line
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{
-				Type:    token.EQ,
-				Literal: string(ch) + string(l.ch),
-			}
+			tok.Type = token.EQ
+			tok.Literal = string(ch) + string(l.ch)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -61,10 +92,8 @@ func (l *Lexer) NextToken() token.Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{
-				Type:    token.NOT_EQ,
-				Literal: string(ch) + string(l.ch),
-			}
+			tok.Type = token.NOT_EQ
+			tok.Literal = string(ch) + string(l.ch)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
@@ -72,6 +101,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.MINUS, l.ch)
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '<':
@@ -118,9 +149,31 @@ func (l *Lexer) readIdentifier() string {
 }
 
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+// readComment 读取 // 开始到行尾的注释
+func (l *Lexer) readComment() {
+	comment := Comment{
+		Token:    token.Token{Type: token.COMMENT, Line: l.line, Column: l.column},
+		Trailing: l.tokenLine == l.line,
+	}
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	comment.Literal = l.input[position:l.position]
+	l.comments = append(l.comments, comment)
 }
 
 func (l *Lexer) readNumber() string {
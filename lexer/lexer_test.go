@@ -136,3 +136,46 @@ if (5 < 10) {
 	}
 
 }
+
+func TestPositionsAndComments(t *testing.T) {
+	input := "let x = 1; // one\n  x == 2\n// end"
+
+	tests := []struct {
+		expectedType   token.Type
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.EQ, 2, 5},
+		{token.INT, 2, 8},
+		{token.EOF, 3, 7},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %d:%d, got=%s %d:%d",
+				i, tt.expectedType, tt.expectedLine, tt.expectedColumn, tok.Type, tok.Line, tok.Column)
+		}
+	}
+
+	comments := l.Comments()
+	expected := []Comment{
+		{Token: token.Token{Type: token.COMMENT, Literal: "// one", Line: 1, Column: 12}, Trailing: true},
+		{Token: token.Token{Type: token.COMMENT, Literal: "// end", Line: 3, Column: 1}},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. got=%d, want=%d", len(comments), len(expected))
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("comments[%d] wrong. got=%+v, want=%+v", i, c, expected[i])
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package limit
+
+import (
+	"context"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+)
+
+// DefaultMaxCallDepth 求值器允许的最大调用深度，MaxCallDepth 不能超过该值
+const DefaultMaxCallDepth = 1024
+
+// cancelCheckMask 每执行 cancelCheckMask+1 步检查一次 ctx 是否被取消，避免每条指令都做 select
+const cancelCheckMask = 1023
+
+// Limits 执行程序时的资源限制，字段为 0 表示不做限制(调用深度和栈大小仍受引擎自身上限约束)
+type Limits struct {
+	MaxInstructions   int64 // 最多执行的指令数，求值器中为求值的节点数
+	MaxCallDepth      int   // 最大函数调用深度
+	MaxStackSize      int   // 最大栈大小，求值器中为表达式嵌套深度
+	MaxCollectionSize int   // 单个数组、哈希允许的最大元素数，字符串允许的最大字节数
+}
+
+// InstructionLimitError 执行的指令数超过 Limits.MaxInstructions
+type InstructionLimitError struct {
+	Limit int64
+}
+
+func (e *InstructionLimitError) Error() string {
+	return fmt.Sprintf("instruction limit exceeded: limit=%d", e.Limit)
+}
+
+// CallDepthError 函数调用深度超过限制
+type CallDepthError struct {
+	Limit int
+}
+
+func (e *CallDepthError) Error() string {
+	return fmt.Sprintf("call depth limit exceeded: limit=%d", e.Limit)
+}
+
+// StackSizeError 栈大小超过限制
+type StackSizeError struct {
+	Limit int
+}
+
+func (e *StackSizeError) Error() string {
+	return fmt.Sprintf("stack overflow: limit=%d", e.Limit)
+}
+
+// CollectionSizeError 数组、哈希、字符串的长度超过 Limits.MaxCollectionSize
+type CollectionSizeError struct {
+	Limit int
+	Size  int
+}
+
+func (e *CollectionSizeError) Error() string {
+	return fmt.Sprintf("collection size limit exceeded: size=%d, limit=%d", e.Size, e.Limit)
+}
+
+// CanceledError 执行过程中 ctx 被取消或超时，Err 为 ctx.Err()
+type CanceledError struct {
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("execution canceled: %s", e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// Meter 记录一次执行的资源消耗，由 vm 和 evaluator 共用
+type Meter struct {
+	ctx    context.Context
+	done   <-chan struct{}
+	limits Limits
+	steps  int64
+}
+
+func NewMeter(ctx context.Context, limits Limits) *Meter {
+	return &Meter{ctx: ctx, done: ctx.Done(), limits: limits}
+}
+
+// Steps 已经执行的步数
+func (m *Meter) Steps() int64 {
+	return m.steps
+}
+
+// Step 记录执行了一步，超过指令数限制或 ctx 被取消时返回错误
+func (m *Meter) Step() error {
+	m.steps++
+	if m.limits.MaxInstructions > 0 && m.steps > m.limits.MaxInstructions {
+		return &InstructionLimitError{Limit: m.limits.MaxInstructions}
+	}
+
+	if m.done != nil && m.steps&cancelCheckMask == 0 {
+		select {
+		case <-m.done:
+			return &CanceledError{Err: m.ctx.Err()}
+		default:
+		}
+	}
+
+	return nil
+}
+
+// CallDepthLimit 实际生效的最大调用深度，engineMax 为引擎自身能支持的最大深度
+func (m *Meter) CallDepthLimit(engineMax int) int {
+	if m.limits.MaxCallDepth > 0 && m.limits.MaxCallDepth < engineMax {
+		return m.limits.MaxCallDepth
+	}
+
+	return engineMax
+}
+
+// StackSizeLimit 实际生效的最大栈大小，engineMax 为引擎自身能支持的最大栈大小
+func (m *Meter) StackSizeLimit(engineMax int) int {
+	if m.limits.MaxStackSize > 0 && m.limits.MaxStackSize < engineMax {
+		return m.limits.MaxStackSize
+	}
+
+	return engineMax
+}
+
+// CheckSize 检查集合长度
+func (m *Meter) CheckSize(size int) error {
+	if m.limits.MaxCollectionSize > 0 && size > m.limits.MaxCollectionSize {
+		return &CollectionSizeError{Limit: m.limits.MaxCollectionSize, Size: size}
+	}
+
+	return nil
+}
+
+// CheckObject 检查数组、哈希的元素数和字符串的字节数，其他类型直接通过
+func (m *Meter) CheckObject(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return m.CheckSize(len(obj.Elements))
+	case *object.Hash:
+		return m.CheckSize(obj.Len())
+	case *object.String:
+		return m.CheckSize(len(obj.Value))
+	}
+
+	return nil
+}
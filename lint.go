@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/lint"
+	"github.com/Shea11012/interpreter_in_go/tester"
+	"os"
+	"strings"
+)
+
+// lintCommand monkey lint <file>...，有检查结果时退出码为 1
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	// 测试文件中可以使用断言函数，test_ 函数由测试框架调用
+	linter, testLinter := lint.New(), lint.NewWithRegistry(tester.Registry())
+	testLinter.External = tester.IsTest
+	status := 0
+	for _, filename := range flags.Args() {
+		program, ok := parseFile(filename)
+		if !ok {
+			status = 1
+			continue
+		}
+
+		l := linter
+		if strings.HasSuffix(filename, tester.FileSuffix) {
+			l = testLinter
+		}
+
+		for _, finding := range l.Lint(program) {
+			fmt.Printf("%s:%s\n", filename, finding)
+			status = 1
+		}
+	}
+
+	return status
+}
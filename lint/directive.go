@@ -0,0 +1,69 @@
+package lint
+
+import (
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"strings"
+)
+
+const (
+	ignoreDirective     = "lint:ignore"
+	fileIgnoreDirective = "lint:file-ignore"
+)
+
+// ignoreRules 忽略指令中的规则集合，为空时表示忽略所有规则
+type ignoreRules map[string]bool
+
+func (r ignoreRules) match(rule string) bool {
+	return len(r) == 0 || r[rule]
+}
+
+// suppress 根据注释中的忽略指令过滤检查结果
+func suppress(findings []Finding, comments []lexer.Comment) []Finding {
+	lines := make(map[int][]ignoreRules)
+	var file []ignoreRules
+
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Literal, "//"))
+		switch {
+		case strings.HasPrefix(text, fileIgnoreDirective):
+			file = append(file, parseRules(strings.TrimPrefix(text, fileIgnoreDirective)))
+		case strings.HasPrefix(text, ignoreDirective):
+			rules := parseRules(strings.TrimPrefix(text, ignoreDirective))
+			// 行尾的注释作用于所在行，单独一行的注释作用于下一行
+			line := comment.Line
+			if !comment.Trailing {
+				line++
+			}
+			lines[line] = append(lines[line], rules)
+		}
+	}
+
+	result := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if !ignored(f, file) && !ignored(f, lines[f.Line]) {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+func ignored(f Finding, rules []ignoreRules) bool {
+	for _, r := range rules {
+		if r.match(f.Rule) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRules 解析以逗号或空格分隔的规则列表
+func parseRules(s string) ignoreRules {
+	rules := make(ignoreRules)
+	for _, rule := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		rules[rule] = true
+	}
+
+	return rules
+}
@@ -0,0 +1,299 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"sort"
+	"strings"
+)
+
+// 检查规则的 ID，用于输出和注释中的忽略指令
+const (
+	RuleUnusedLet    = "unused-let"    // let 声明的变量没有被使用
+	RuleUnusedParam  = "unused-param"  // 函数参数没有被使用
+	RuleShadow       = "shadow"        // 声明遮蔽了外层函数中的变量或内置函数
+	RuleUnreachable  = "unreachable"   // return 之后的语句不会被执行
+	RuleBuiltinArity = "builtin-arity" // 调用内置函数时参数个数不对
+	RuleUndefined    = "undefined"     // 标识符无法解析
+)
+
+// Finding 一条检查结果，Line 和 Column 从 1 开始
+type Finding struct {
+	Rule    string
+	Message string
+	Line    int
+	Column  int
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", f.Line, f.Column, f.Rule, f.Message)
+}
+
+// Linter 对 AST 做静态检查，标识符按编译器的 SymbolTable 规则解析
+//
+// 以 _ 开头的变量和参数不会被报告为未使用
+// 注释 `// lint:ignore rule1,rule2` 写在行尾时忽略所在行的检查结果，单独占一行时忽略下一行的检查结果，
+// 不写规则时忽略所有规则，
+// `// lint:file-ignore rule1,rule2` 忽略整个文件中的检查结果
+type Linter struct {
+	// External 返回 true 的顶层变量会在文件之外使用，不报告为未使用，如测试文件中的 test_ 函数
+	External func(name string) bool
+
+	registry *builtin.Registry
+}
+
+func New() *Linter {
+	return NewWithRegistry(builtin.Default())
+}
+
+// NewWithRegistry 使用指定的内置函数注册表检查，应当与编译时使用的注册表相同
+func NewWithRegistry(registry *builtin.Registry) *Linter {
+	return &Linter{registry: registry}
+}
+
+// Lint 检查 program，返回按位置排序的检查结果
+func (l *Linter) Lint(program *ast.Program) []Finding {
+	symbolTable := compiler.NewSymbolTable()
+	for i, name := range l.registry.Names() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	c := &checker{registry: l.registry, external: l.External}
+	c.scope = newScope(symbolTable, nil)
+	c.statements(program.Statements)
+	c.closeScope()
+
+	findings := suppress(c.findings, program.Comments)
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+
+	return findings
+}
+
+// binding 一个 let 或参数声明，rule 为未使用时报告的规则，为空时不报告
+type binding struct {
+	name  string
+	token token.Token
+	rule  string
+	used  bool
+}
+
+// scope 与编译器一样，只有函数会创建新的作用域
+type scope struct {
+	table    *compiler.SymbolTable
+	bindings map[string]*binding
+	declared []*binding
+	outer    *scope
+}
+
+func newScope(table *compiler.SymbolTable, outer *scope) *scope {
+	return &scope{table: table, bindings: make(map[string]*binding), outer: outer}
+}
+
+type checker struct {
+	registry *builtin.Registry
+	external func(name string) bool
+	scope    *scope
+	findings []Finding
+}
+
+func (c *checker) report(rule string, tok token.Token, format string, a ...interface{}) {
+	c.findings = append(c.findings, Finding{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	afterReturn, reported := false, false
+	for _, stmt := range stmts {
+		// 只报告 return 之后的第一条语句
+		if afterReturn && !reported {
+			c.report(RuleUnreachable, ast.TokenOf(stmt), "unreachable statement after return")
+			reported = true
+		}
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			afterReturn = true
+		}
+
+		c.statement(stmt)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// 编译器先定义变量再编译右边的表达式，这里保持一致
+		c.declare(stmt.Name, RuleUnusedLet)
+		c.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.block(stmt)
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) {
+	if block != nil {
+		c.statements(block.Statements)
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		c.resolve(exp)
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		c.block(exp.Consequence)
+		c.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		c.function(exp)
+	case *ast.CallExpression:
+		c.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+		c.checkArity(exp)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			c.expression(pair.Key)
+			c.expression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.SliceExpression:
+		c.expression(exp.Left)
+		if exp.Low != nil {
+			c.expression(exp.Low)
+		}
+		if exp.High != nil {
+			c.expression(exp.High)
+		}
+	}
+}
+
+func (c *checker) function(fn *ast.FunctionLiteral) {
+	c.scope = newScope(compiler.NewEnclosedSymbolTable(c.scope.table), c.scope)
+
+	if fn.Name != "" {
+		// 函数内部对自身的引用不算作使用外层的 let
+		c.scope.table.DefineFunctionName(fn.Name)
+		c.scope.bindings[fn.Name] = &binding{name: fn.Name}
+	}
+
+	for _, p := range fn.Parameters {
+		c.declare(p, RuleUnusedParam)
+	}
+
+	c.block(fn.Body)
+	c.closeScope()
+}
+
+// declare 在当前作用域定义变量，与外层作用域的变量或内置函数重名时报告遮蔽
+// 同一个作用域中重复 let 是常见的写法，不做报告
+func (c *checker) declare(ident *ast.Identifier, rule string) {
+	name := ident.Value
+	if _, ok := c.scope.bindings[name]; !ok {
+		if outer := c.scope.outer.lookup(name); outer != nil {
+			if outer.rule != "" {
+				c.report(RuleShadow, ident.Token, "%s shadows the declaration at %d:%d", name, outer.token.Line, outer.token.Column)
+			}
+		} else if _, ok := c.registry.Get(name); ok {
+			c.report(RuleShadow, ident.Token, "%s shadows the builtin function", name)
+		}
+	}
+
+	c.scope.table.Define(name)
+	b := &binding{name: name, token: ident.Token, rule: rule}
+	c.scope.bindings[name] = b
+	c.scope.declared = append(c.scope.declared, b)
+}
+
+// resolve 按编译器的规则解析标识符，并标记对应的声明已被使用
+func (c *checker) resolve(ident *ast.Identifier) {
+	if _, ok := c.scope.table.Resolve(ident.Value); !ok {
+		c.report(RuleUndefined, ident.Token, "undefined variable %s", ident.Value)
+		return
+	}
+
+	if b := c.scope.lookup(ident.Value); b != nil {
+		b.used = true
+	}
+}
+
+// closeScope 离开当前作用域，报告其中没有使用的声明
+func (c *checker) closeScope() {
+	for _, b := range c.scope.declared {
+		// 重复声明时只检查最后一次
+		if b.used || c.scope.bindings[b.name] != b || strings.HasPrefix(b.name, "_") {
+			continue
+		}
+		if c.scope.outer == nil && c.external != nil && c.external(b.name) {
+			continue
+		}
+
+		switch b.rule {
+		case RuleUnusedLet:
+			c.report(b.rule, b.token, "%s is declared but never used", b.name)
+		case RuleUnusedParam:
+			c.report(b.rule, b.token, "parameter %s is never used", b.name)
+		}
+	}
+
+	c.scope = c.scope.outer
+}
+
+// checkArity 检查内置函数调用的参数个数，被同名变量遮蔽的内置函数不检查
+func (c *checker) checkArity(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return
+	}
+
+	symbol, ok := c.scope.table.Resolve(ident.Value)
+	if !ok || symbol.Scope != compiler.BuiltinScope {
+		return
+	}
+
+	def, ok := c.registry.Get(ident.Value)
+	if !ok || def.Arity == nil || def.Arity.Accepts(len(call.Arguments)) {
+		return
+	}
+
+	c.report(RuleBuiltinArity, ident.Token, "wrong number of arguments to `%s`. got=%d, want=%s",
+		ident.Value, len(call.Arguments), def.Arity)
+}
+
+// lookup 从内到外查找声明，s 为 nil 时返回 nil
+func (s *scope) lookup(name string) *binding {
+	for scope := s; scope != nil; scope = scope.outer {
+		if b, ok := scope.bindings[name]; ok {
+			return b
+		}
+	}
+
+	return nil
+}
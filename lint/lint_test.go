@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x);", nil},
+		{"let x = 1;", []string{"1:5: unused-let: x is declared but never used"}},
+		{"let _x = 1; let f = fn(_a) { 1 }; f(1);", nil},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15: unused-param: parameter b is never used"}},
+		{
+			"let x = 1; let f = fn() { let x = 2; x }; f() + x;",
+			[]string{"1:31: shadow: x shadows the declaration at 1:5"},
+		},
+		{"let f = fn(len) { len }; f(1);", []string{"1:12: shadow: len shadows the builtin function"}},
+		{"let x = 1; let x = x + 1; puts(x);", nil},
+		{
+			"let f = fn() { return 1; puts(2); puts(3); }; f();",
+			[]string{"1:26: unreachable: unreachable statement after return"},
+		},
+		{
+			"len(1, 2); substr(\"a\"); puts();",
+			[]string{
+				"1:1: builtin-arity: wrong number of arguments to `len`. got=2, want=1",
+				"1:12: builtin-arity: wrong number of arguments to `substr`. got=1, want=2 or 3",
+			},
+		},
+		{"let f = fn(len) { len(1, 2) }; f(1);", []string{"1:12: shadow: len shadows the builtin function"}},
+		{"puts(y);", []string{"1:6: undefined: undefined variable y"}},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) } }; fib(3);", nil},
+		{
+			"let f = fn(a) {\n  fn(b) { a + c }\n};\nf(1);",
+			[]string{
+				"2:6: unused-param: parameter b is never used",
+				"2:15: undefined: undefined variable c",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		assertFindings(t, tt.input, tt.expected)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; // lint:ignore", nil},
+		{"// lint:ignore unused-let\nlet x = 1;", nil},
+		{"// lint:ignore shadow\nlet x = 1;", []string{"2:5: unused-let: x is declared but never used"}},
+		{"// lint:ignore unused-let\n\nlet x = 1;", []string{"3:5: unused-let: x is declared but never used"}},
+		{"let x = y; // lint:ignore unused-let, undefined", nil},
+		{"let x = 1; // lint:ignore unused-let\nlet y = 2;", []string{"2:5: unused-let: y is declared but never used"}},
+		{"let f = fn() {\n  1\n} // lint:ignore\nlet y = 2;", []string{
+			"1:5: unused-let: f is declared but never used",
+			"4:5: unused-let: y is declared but never used",
+		}},
+		{"// lint:file-ignore undefined\nputs(a);\n\nputs(b);", nil},
+		{"// lint:file-ignore\nlet x = 1;\nputs(a);", nil},
+	}
+
+	for _, tt := range tests {
+		assertFindings(t, tt.input, tt.expected)
+	}
+}
+
+func assertFindings(t *testing.T, input string, expected []string) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	var got []string
+	for _, f := range New().Lint(program) {
+		got = append(got, f.String())
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong findings for %q.\ngot=%q\nwant=%q", input, got, expected)
+	}
+}
+
+func TestExternal(t *testing.T) {
+	input := "let test_a = fn() { let test_b = 1; };\nlet other = 1;"
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	linter := New()
+	linter.External = func(name string) bool { return strings.HasPrefix(name, "test_") }
+
+	var got []string
+	for _, f := range linter.Lint(program) {
+		got = append(got, f.String())
+	}
+
+	// 只有顶层变量视为在文件之外使用
+	expected := []string{
+		"1:25: unused-let: test_b is declared but never used",
+		"2:5: unused-let: other is declared but never used",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong findings.\ngot=%q\nwant=%q", got, expected)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/lsp"
+	"os"
+)
+
+// lspCommand monkey lsp，通过标准输入输出运行语言服务器
+func lspCommand(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := lsp.New().Run(os.Stdin, os.Stdout); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "lsp: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/limit"
+	"github.com/Shea11012/interpreter_in_go/lint"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// diagnosticSource 诊断中显示的来源
+const diagnosticSource = "monkey"
+
+// macroTimeout 检查编译错误时展开宏的最长时间，宏体是用户代码，可能不会结束
+const macroTimeout = time.Second
+
+// document 一个打开的文档
+type document struct {
+	src         source
+	diagnostics []Diagnostic
+	// index 最后一次解析成功时的结果，文档有语法错误时保留上一次的结果用于跳转和补全
+	index *index
+}
+
+// update 用新的内容重新分析文档，external 返回 true 的顶层变量不报告为未使用，见 lint.Linter
+func (d *document) update(text string, registry *builtin.Registry, external func(name string) bool) {
+	d.src = newSource(text)
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if errs := p.ErrorsWithPosition(); len(errs) != 0 {
+		d.diagnostics = make([]Diagnostic, 0, len(errs))
+		for _, err := range errs {
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.src.tokenRange(err.Token),
+				Severity: SeverityError,
+				Source:   diagnosticSource,
+				Message:  err.Message,
+			})
+		}
+		return
+	}
+
+	d.index = newIndex(program, d.src, registry)
+	d.diagnostics = []Diagnostic{}
+
+	undefined := make(map[string]bool)
+	for _, tok := range d.index.undefined {
+		message := "undefined variable " + tok.Literal
+		undefined[message] = true
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.src.tokenRange(tok),
+			Severity: SeverityError,
+			Source:   diagnosticSource,
+			Message:  message,
+		})
+	}
+
+	if err := compileCheck(program, registry); err != nil && !undefined[err.Error()] {
+		// 编译错误没有位置，放在文档开头
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Source:   diagnosticSource,
+			Message:  err.Error(),
+		})
+	}
+
+	linter := lint.NewWithRegistry(registry)
+	linter.External = external
+	for _, finding := range linter.Lint(program) {
+		if finding.Rule == lint.RuleUndefined {
+			continue
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.src.wordRange(finding.Line, finding.Column),
+			Severity: SeverityWarning,
+			Code:     finding.Rule,
+			Source:   diagnosticSource,
+			Message:  finding.Message,
+		})
+	}
+}
+
+// compileCheck 展开宏之后编译 program，返回编译错误，program 本身不会被修改
+// 宏展开时的输出被丢弃，不会写到与编辑器通信的标准输出
+func compileCheck(program *ast.Program, registry *builtin.Registry) error {
+	program = ast.Copy(program).(*ast.Program)
+
+	ctx, cancel := context.WithTimeout(context.Background(), macroTimeout)
+	defer cancel()
+
+	e := evaluator.NewWithRegistry(registry)
+	e.IO = object.NewIO(strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	e.Limits = limit.Limits{MaxCallDepth: 1000, MaxCollectionSize: 1 << 20}
+
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded, err := e.ExpandMacros(ctx, program, env)
+	if err != nil {
+		return fmt.Errorf("macro expansion failed: %s", err)
+	}
+
+	return compiler.NewWithRegistry(registry).Compile(expanded)
+}
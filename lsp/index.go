@@ -0,0 +1,386 @@
+package lsp
+
+import (
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/token"
+	"sort"
+	"strings"
+)
+
+type definitionKind int
+
+const (
+	definitionLet definitionKind = iota
+	definitionParam
+	definitionBuiltin
+)
+
+// definition 一个 let、参数或内置函数，内置函数没有声明的位置
+type definition struct {
+	name  string
+	kind  definitionKind
+	token token.Token
+	fn    *ast.FunctionLiteral // let 绑定的函数，用于悬停提示
+}
+
+// occurrence 标识符在文档中的一次出现，decl 为 true 时是声明
+type occurrence struct {
+	token token.Token
+	def   *definition
+	decl  bool
+}
+
+// scope 与编译器一样，只有函数会创建新的作用域
+// start 和 end 为函数的 fn 和右花括号，全局作用域的为零值
+type scope struct {
+	table    *compiler.SymbolTable
+	defs     map[string]*definition
+	declared []*definition
+	outer    *scope
+	children []*scope
+	start    token.Token
+	end      token.Token
+}
+
+func newScope(table *compiler.SymbolTable, outer *scope) *scope {
+	return &scope{table: table, defs: make(map[string]*definition), outer: outer}
+}
+
+// lookup 从内到外查找声明，s 为 nil 时返回 nil
+func (s *scope) lookup(name string) *definition {
+	for scope := s; scope != nil; scope = scope.outer {
+		if def, ok := scope.defs[name]; ok {
+			return def
+		}
+	}
+
+	return nil
+}
+
+// index 一次解析成功的文档中所有标识符的解析结果，标识符按编译器的 SymbolTable 规则解析
+type index struct {
+	src         source
+	registry    *builtin.Registry
+	root        *scope
+	occurrences []occurrence  // 按位置排序
+	undefined   []token.Token // 无法解析的标识符
+	builtins    map[string]*definition
+	symbols     []DocumentSymbol
+}
+
+func newIndex(program *ast.Program, src source, registry *builtin.Registry) *index {
+	table := compiler.NewSymbolTable()
+	for i, name := range registry.Names() {
+		table.DefineBuiltin(i, name)
+	}
+
+	idx := &index{
+		src:      src,
+		registry: registry,
+		root:     newScope(table, nil),
+		builtins: make(map[string]*definition),
+	}
+
+	b := &indexer{index: idx, scope: idx.root}
+	b.statements(program.Statements)
+
+	sort.SliceStable(idx.occurrences, func(i, j int) bool {
+		return tokenBefore(idx.occurrences[i].token, idx.occurrences[j].token)
+	})
+	idx.symbols = functionSymbols(program, src)
+
+	return idx
+}
+
+// indexer 遍历 AST 建立 index，遍历的顺序与编译器编译的顺序一致
+type indexer struct {
+	index *index
+	scope *scope
+}
+
+func (b *indexer) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		b.statement(stmt)
+	}
+}
+
+func (b *indexer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// 编译器先定义变量再编译右边的表达式
+		def := b.declare(stmt.Name, definitionLet)
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			def.fn = fn
+		}
+		b.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		b.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		b.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		b.block(stmt)
+	}
+}
+
+func (b *indexer) block(block *ast.BlockStatement) {
+	if block != nil {
+		b.statements(block.Statements)
+	}
+}
+
+// expression 遍历表达式，宏体中的 quote 和 unquote 在展开时才有意义，不做解析
+func (b *indexer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		b.resolve(exp)
+	case *ast.PrefixExpression:
+		b.expression(exp.Right)
+	case *ast.InfixExpression:
+		b.expression(exp.Left)
+		b.expression(exp.Right)
+	case *ast.IfExpression:
+		b.expression(exp.Condition)
+		b.block(exp.Consequence)
+		b.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		b.function(exp)
+	case *ast.CallExpression:
+		b.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			b.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			b.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			b.expression(pair.Key)
+			b.expression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		b.expression(exp.Left)
+		b.expression(exp.Index)
+	case *ast.SliceExpression:
+		b.expression(exp.Left)
+		if exp.Low != nil {
+			b.expression(exp.Low)
+		}
+		if exp.High != nil {
+			b.expression(exp.High)
+		}
+	}
+}
+
+func (b *indexer) function(fn *ast.FunctionLiteral) {
+	s := newScope(compiler.NewEnclosedSymbolTable(b.scope.table), b.scope)
+	s.start = fn.Token
+	if fn.Body != nil {
+		s.end = fn.Body.Rbrace
+	}
+	b.scope.children = append(b.scope.children, s)
+
+	if fn.Name != "" {
+		// 函数内部对自身的引用指向外层的 let
+		s.table.DefineFunctionName(fn.Name)
+		if def := b.scope.lookup(fn.Name); def != nil {
+			s.defs[fn.Name] = def
+		}
+	}
+
+	b.scope = s
+	for _, p := range fn.Parameters {
+		b.declare(p, definitionParam)
+	}
+	b.block(fn.Body)
+	b.scope = s.outer
+}
+
+func (b *indexer) declare(ident *ast.Identifier, kind definitionKind) *definition {
+	b.scope.table.Define(ident.Value)
+	def := &definition{name: ident.Value, kind: kind, token: ident.Token}
+	b.scope.defs[ident.Value] = def
+	b.scope.declared = append(b.scope.declared, def)
+	b.index.occurrences = append(b.index.occurrences, occurrence{token: ident.Token, def: def, decl: true})
+
+	return def
+}
+
+func (b *indexer) resolve(ident *ast.Identifier) {
+	symbol, ok := b.scope.table.Resolve(ident.Value)
+	if !ok {
+		b.index.undefined = append(b.index.undefined, ident.Token)
+		return
+	}
+
+	def := b.scope.lookup(ident.Value)
+	if def == nil && symbol.Scope == compiler.BuiltinScope {
+		def = b.index.builtin(ident.Value)
+	}
+	if def != nil {
+		b.index.occurrences = append(b.index.occurrences, occurrence{token: ident.Token, def: def})
+	}
+}
+
+func (idx *index) builtin(name string) *definition {
+	def, ok := idx.builtins[name]
+	if !ok {
+		def = &definition{name: name, kind: definitionBuiltin}
+		idx.builtins[name] = def
+	}
+
+	return def
+}
+
+// occurrenceAt 返回 pos 处的标识符，光标紧跟在标识符后面时也算
+func (idx *index) occurrenceAt(pos Position) (occurrence, bool) {
+	line, column := idx.src.column(pos)
+	for _, occ := range idx.occurrences {
+		tok := occ.token
+		if tok.Line == line && tok.Column <= column && column <= tok.Column+len(tok.Literal) {
+			return occ, true
+		}
+	}
+
+	return occurrence{}, false
+}
+
+// references 返回 def 的所有出现位置
+func (idx *index) references(def *definition, includeDeclaration bool) []occurrence {
+	var refs []occurrence
+	for _, occ := range idx.occurrences {
+		if occ.def == def && (includeDeclaration || !occ.decl) {
+			refs = append(refs, occ)
+		}
+	}
+
+	return refs
+}
+
+// completions 返回 pos 处可以使用的标识符：所在函数及外层函数中在 pos 之前声明的变量和参数，
+// 以及没有被遮蔽的内置函数
+func (idx *index) completions(pos Position) []CompletionItem {
+	line, column := idx.src.column(pos)
+	cursor := token.Token{Line: line, Column: column}
+
+	s := idx.root
+	for inner := s; inner != nil; {
+		s, inner = inner, nil
+		for _, child := range s.children {
+			if tokenBefore(child.start, cursor) && !tokenBefore(child.end, cursor) {
+				inner = child
+				break
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	items := []CompletionItem{}
+	for ; s != nil; s = s.outer {
+		for _, def := range s.declared {
+			if seen[def.name] || !tokenBefore(def.token, cursor) {
+				continue
+			}
+			seen[def.name] = true
+
+			item := CompletionItem{Label: def.name, Kind: CompletionVariable}
+			if def.fn != nil {
+				item.Kind = CompletionFunction
+				item.Detail = functionDetail(def.fn)
+			}
+			items = append(items, item)
+		}
+	}
+
+	for _, name := range idx.registry.Names() {
+		if seen[name] {
+			continue
+		}
+
+		item := CompletionItem{Label: name, Kind: CompletionFunction}
+		if fn, ok := idx.registry.Get(name); ok {
+			item.Detail = fn.Signature
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// hover 返回标识符的说明，内置函数显示签名
+func (idx *index) hover(def *definition) string {
+	switch def.kind {
+	case definitionBuiltin:
+		fn, ok := idx.registry.Get(def.name)
+		if !ok {
+			return "builtin " + def.name
+		}
+		if fn.Signature != "" {
+			return fn.Signature
+		}
+		if fn.Arity != nil {
+			return "builtin " + def.name + ", arguments: " + fn.Arity.String()
+		}
+		return "builtin " + def.name
+	case definitionParam:
+		return "parameter " + def.name
+	}
+
+	if def.fn != nil {
+		return "let " + def.name + " = " + functionDetail(def.fn)
+	}
+	return "let " + def.name
+}
+
+// functionDetail 返回函数的参数列表，如 fn(a, b)
+func functionDetail(fn *ast.FunctionLiteral) string {
+	params := make([]string, 0, len(fn.Parameters))
+	for _, p := range fn.Parameters {
+		params = append(params, p.Value)
+	}
+
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// functionSymbols 返回 node 中 let 绑定的函数，函数内部定义的函数作为子符号
+func functionSymbols(node ast.Node, src source) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	ast.Inspect(node, func(n ast.Node) bool {
+		let, ok := n.(*ast.LetStatement)
+		if !ok {
+			return true
+		}
+
+		fn, ok := let.Value.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+
+		end := fn.Token
+		if fn.Body != nil {
+			end = fn.Body.Rbrace
+		}
+		symbols = append(symbols, DocumentSymbol{
+			Name:   let.Name.Value,
+			Detail: functionDetail(fn),
+			Kind:   SymbolFunction,
+			Range: Range{
+				Start: src.position(let.Token.Line, let.Token.Column),
+				End:   src.position(end.Line, end.Column+len(end.Literal)),
+			},
+			SelectionRange: src.tokenRange(let.Name.Token),
+			Children:       functionSymbols(fn.Body, src),
+		})
+
+		return false
+	})
+
+	return symbols
+}
+
+// tokenBefore 判断 a 是否在 b 之前
+func tokenBefore(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
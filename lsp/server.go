@@ -0,0 +1,286 @@
+// Package lsp 实现 Monkey 的 Language Server Protocol 服务器，通过标准输入输出与编辑器通信
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/tester"
+	"io"
+	"strings"
+)
+
+// ErrExitWithoutShutdown 收到 exit 之前没有收到 shutdown，按协议进程应以退出码 1 结束
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server 语言服务器，支持诊断、跳转到定义、查找引用、悬停提示、补全和文档符号
+//
+// 文档按全量同步，每次打开或修改后重新分析并推送诊断：语法错误、无法解析的标识符、
+// 展开宏之后的编译错误，以及 lint 的检查结果
+type Server struct {
+	registry     *builtin.Registry
+	testRegistry *builtin.Registry // 测试文件使用的注册表，为 nil 时与其他文档相同
+	docs         map[string]*document
+	conn         *conn
+	shutdown     bool
+}
+
+// New 使用标准内置函数分析文档，以 _test.mk 结尾的测试文件中还可以使用 tester 包提供的断言函数
+func New() *Server {
+	s := NewWithRegistry(builtin.Default())
+	s.testRegistry = tester.Registry()
+	return s
+}
+
+// NewWithRegistry 使用指定的内置函数注册表分析文档，应当与运行时使用的注册表相同
+func NewWithRegistry(registry *builtin.Registry) *Server {
+	return &Server{registry: registry, docs: make(map[string]*document)}
+}
+
+// Run 从 r 读取请求并把响应写到 w，收到 exit 或输入结束时返回
+// 收到 exit 之前没有收到 shutdown 时返回 ErrExitWithoutShutdown
+func (s *Server) Run(r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for {
+		body, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.conn.replyError(nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(&msg)
+		if msg.ID == nil {
+			// 通知没有响应
+			continue
+		}
+
+		if rerr != nil {
+			err = s.conn.replyError(msg.ID, rerr)
+		} else {
+			err = s.conn.reply(msg.ID, result)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// 全量同步，最后一次修改就是完整的内容
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.open(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []Diagnostic{})
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/references":
+		var params referenceParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(params), nil
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func decodeParams(msg *message, v interface{}) *responseError {
+	if len(msg.Params) == 0 {
+		return &responseError{Code: codeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
+
+func (s *Server) initialize() interface{} {
+	type serverInfo struct {
+		Name string `json:"name"`
+	}
+	type capabilities struct {
+		TextDocumentSync       int                    `json:"textDocumentSync"`
+		DefinitionProvider     bool                   `json:"definitionProvider"`
+		ReferencesProvider     bool                   `json:"referencesProvider"`
+		HoverProvider          bool                   `json:"hoverProvider"`
+		CompletionProvider     map[string]interface{} `json:"completionProvider"`
+		DocumentSymbolProvider bool                   `json:"documentSymbolProvider"`
+	}
+
+	return struct {
+		Capabilities capabilities `json:"capabilities"`
+		ServerInfo   serverInfo   `json:"serverInfo"`
+	}{
+		Capabilities: capabilities{
+			TextDocumentSync:       1, // 全量同步
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			HoverProvider:          true,
+			CompletionProvider:     map[string]interface{}{},
+			DocumentSymbolProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "monkey"},
+	}
+}
+
+// open 打开或更新文档，分析后推送诊断
+func (s *Server) open(uri, text string) *responseError {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &document{}
+		s.docs[uri] = doc
+	}
+
+	// 测试文件中可以使用断言函数，test_ 函数由测试框架调用
+	if s.testRegistry != nil && strings.HasSuffix(uri, tester.FileSuffix) {
+		doc.update(text, s.testRegistry, tester.IsTest)
+	} else {
+		doc.update(text, s.registry, nil)
+	}
+	return s.publish(uri, doc.diagnostics)
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) *responseError {
+	err := s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	if err != nil {
+		return &responseError{Code: codeInvalidRequest, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// lookup 返回文档中 pos 处的标识符，没有打开或没有解析成功过的文档返回 false
+func (s *Server) lookup(uri string, pos Position) (*index, occurrence, bool) {
+	doc, ok := s.docs[uri]
+	if !ok || doc.index == nil {
+		return nil, occurrence{}, false
+	}
+
+	occ, ok := doc.index.occurrenceAt(pos)
+	return doc.index, occ, ok
+}
+
+// definition 返回声明的位置，内置函数没有位置，返回 null
+func (s *Server) definition(params textDocumentPositionParams) interface{} {
+	uri := params.TextDocument.URI
+	idx, occ, ok := s.lookup(uri, params.Position)
+	if !ok || occ.def.kind == definitionBuiltin {
+		return nil
+	}
+
+	return Location{URI: uri, Range: idx.src.tokenRange(occ.def.token)}
+}
+
+func (s *Server) references(params referenceParams) []Location {
+	uri := params.TextDocument.URI
+	locations := []Location{}
+	idx, occ, ok := s.lookup(uri, params.Position)
+	if !ok {
+		return locations
+	}
+
+	for _, ref := range idx.references(occ.def, params.Context.IncludeDeclaration) {
+		locations = append(locations, Location{URI: uri, Range: idx.src.tokenRange(ref.token)})
+	}
+
+	return locations
+}
+
+func (s *Server) hover(params textDocumentPositionParams) interface{} {
+	idx, occ, ok := s.lookup(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil
+	}
+
+	r := idx.src.tokenRange(occ.token)
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + idx.hover(occ.def) + "\n```"},
+		Range:    &r,
+	}
+}
+
+func (s *Server) completion(params textDocumentPositionParams) []CompletionItem {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok || doc.index == nil {
+		return []CompletionItem{}
+	}
+
+	return doc.index.completions(params.Position)
+}
+
+func (s *Server) documentSymbol(params documentSymbolParams) []DocumentSymbol {
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok || doc.index == nil || doc.index.symbols == nil {
+		return []DocumentSymbol{}
+	}
+
+	return doc.index.symbols
+}
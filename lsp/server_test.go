@@ -0,0 +1,287 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.mk"
+
+const testSource = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let total = add(1, 2);
+puts(len("héllo"), total);
+`
+
+// received 服务器写出的一条消息
+type received struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func request(id int, method string, params interface{}) string {
+	data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	return string(data)
+}
+
+func notify(method string, params interface{}) string {
+	data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	return string(data)
+}
+
+func position(id int, method string, line, character int) string {
+	return request(id, method, map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     Position{Line: line, Character: character},
+		"context":      map[string]bool{"includeDeclaration": true},
+	})
+}
+
+// runSession 依次发送 messages，返回服务器写出的所有消息和 Run 的返回值
+func runSession(t *testing.T, messages ...string) ([]received, error) {
+	t.Helper()
+
+	var in bytes.Buffer
+	for _, msg := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+
+	var out bytes.Buffer
+	runErr := New().Run(&in, &out)
+
+	var replies []received
+	c := newConn(&out, nil)
+	for {
+		body, err := c.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading server output: %s", err)
+		}
+
+		var r received
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatalf("invalid server output %s: %s", body, err)
+		}
+		replies = append(replies, r)
+	}
+
+	return replies, runErr
+}
+
+// result 返回对请求 id 的响应
+func result(t *testing.T, replies []received, id int, v interface{}) {
+	t.Helper()
+	for _, r := range replies {
+		if r.ID == nil || *r.ID != id {
+			continue
+		}
+		if r.Error != nil {
+			t.Fatalf("request %d failed: %s", id, r.Error.Message)
+		}
+		if err := json.Unmarshal(r.Result, v); err != nil {
+			t.Fatalf("invalid result for request %d: %s", id, err)
+		}
+		return
+	}
+
+	t.Fatalf("no response for request %d", id)
+}
+
+func TestSession(t *testing.T) {
+	replies, err := runSession(t,
+		request(1, "initialize", map[string]interface{}{}),
+		notify("initialized", map[string]interface{}{}),
+		notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": testURI, "version": 1, "text": testSource},
+		}),
+		position(2, "textDocument/definition", 4, 13),
+		position(3, "textDocument/references", 1, 14),
+		position(4, "textDocument/hover", 5, 6),
+		position(5, "textDocument/completion", 2, 4),
+		request(6, "textDocument/documentSymbol", map[string]interface{}{
+			"textDocument": map[string]string{"uri": testURI},
+		}),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": testURI, "version": 2},
+			"contentChanges": []map[string]string{{"text": testSource + "let x = ;"}},
+		}),
+		// 有语法错误时使用上一次解析成功的结果
+		position(7, "textDocument/definition", 5, 21),
+		request(8, "unknown/method", nil),
+		request(9, "shutdown", nil),
+		notify("exit", nil),
+	)
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	result(t, replies, 1, &init)
+	if init.Capabilities["definitionProvider"] != true || init.Capabilities["textDocumentSync"] != float64(1) {
+		t.Errorf("wrong capabilities. got=%v", init.Capabilities)
+	}
+
+	var definition Location
+	result(t, replies, 2, &definition)
+	if want := (Range{Position{0, 4}, Position{0, 7}}); definition.URI != testURI || definition.Range != want {
+		t.Errorf("wrong definition. got=%+v, want=%+v", definition, want)
+	}
+
+	var references []Location
+	result(t, replies, 3, &references)
+	var lines []int
+	for _, ref := range references {
+		lines = append(lines, ref.Range.Start.Line, ref.Range.Start.Character)
+	}
+	if want := []int{0, 13, 1, 14}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("wrong references. got=%v, want=%v", lines, want)
+	}
+
+	var hover Hover
+	result(t, replies, 4, &hover)
+	if !strings.Contains(hover.Contents.Value, "len(STRING | ARRAY | HASH) INTEGER") {
+		t.Errorf("wrong hover. got=%q", hover.Contents.Value)
+	}
+
+	var completions []CompletionItem
+	result(t, replies, 5, &completions)
+	labels := make(map[string]bool)
+	for _, item := range completions {
+		labels[item.Label] = true
+	}
+	for _, name := range []string{"a", "b", "sum", "add", "puts", "len"} {
+		if !labels[name] {
+			t.Errorf("completion is missing %s", name)
+		}
+	}
+	if labels["total"] {
+		t.Errorf("completion should not contain total, which is declared later")
+	}
+
+	var symbols []DocumentSymbol
+	result(t, replies, 6, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "add" || symbols[0].Detail != "fn(a, b)" ||
+		symbols[0].Range != (Range{Position{0, 0}, Position{3, 1}}) {
+		t.Errorf("wrong document symbols. got=%+v", symbols)
+	}
+
+	var stale Location
+	result(t, replies, 7, &stale)
+	if want := (Range{Position{4, 4}, Position{4, 9}}); stale.Range != want {
+		t.Errorf("wrong definition after a syntax error. got=%+v, want=%+v", stale.Range, want)
+	}
+
+	var published int
+	for _, r := range replies {
+		if r.ID != nil && *r.ID == 8 {
+			if r.Error == nil || r.Error.Code != codeMethodNotFound {
+				t.Errorf("unknown method should fail with %d. got=%+v", codeMethodNotFound, r.Error)
+			}
+		}
+		if r.Method == "textDocument/publishDiagnostics" {
+			published++
+		}
+	}
+	if published != 2 {
+		t.Errorf("wrong number of published diagnostics. got=%d, want=2", published)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	_, err := runSession(t, notify("exit", nil))
+	if err != ErrExitWithoutShutdown {
+		t.Fatalf("wrong error. got=%v, want=%v", err, ErrExitWithoutShutdown)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = ;", []string{"0:8-0:9 error: no prefix parse function for ; found"}},
+		{"puts(y);", []string{"0:5-0:6 error: undefined variable y"}},
+		{"let len = 1; puts(len);", []string{"0:4-0:7 warning: len shadows the builtin function"}},
+		{"let m = macro() { 1 }; m();", []string{
+			"0:0-0:0 error: macro expansion failed: macro `m` must return a quoted AST node, got INTEGER",
+		}},
+		{"puts(\"héllo\", z);", []string{"0:14-0:15 error: undefined variable z"}},
+		{testSource, nil},
+	}
+
+	for _, tt := range tests {
+		doc := &document{}
+		doc.update(tt.input, New().registry, nil)
+
+		var got []string
+		for _, d := range doc.diagnostics {
+			severity := "error"
+			if d.Severity == SeverityWarning {
+				severity = "warning"
+			}
+			got = append(got, fmt.Sprintf("%d:%d-%d:%d %s: %s", d.Range.Start.Line, d.Range.Start.Character,
+				d.Range.End.Line, d.Range.End.Character, severity, d.Message))
+		}
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong diagnostics for %q.\ngot=%q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTestFileDiagnostics(t *testing.T) {
+	source := "let test_a = fn() { assert_eq(1, 1) };"
+	open := func(uri string) string {
+		return notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": source},
+		})
+	}
+
+	replies, err := runSession(t, open("file:///math_test.mk"), open("file:///math.mk"))
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	// 测试文件中可以使用断言函数，test_ 函数不报告为未使用
+	expected := map[string][]string{
+		"file:///math_test.mk": nil,
+		"file:///math.mk": {
+			"undefined variable assert_eq",
+			"test_a is declared but never used",
+		},
+	}
+
+	got := make(map[string][]string)
+	for _, r := range replies {
+		if r.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(r.Params, &params); err != nil {
+			t.Fatalf("invalid diagnostics: %s", err)
+		}
+		got[params.URI] = nil
+		for _, d := range params.Diagnostics {
+			got[params.URI] = append(got[params.URI], d.Message)
+		}
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong diagnostics.\ngot=%q\nwant=%q", got, expected)
+	}
+}
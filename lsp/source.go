@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"github.com/Shea11012/interpreter_in_go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// source 按行切分的文档内容，用于在 token 的位置和 LSP 的位置之间转换
+//
+// token 的行列从 1 开始，列按字节计数；LSP 的行列从 0 开始，列按 UTF-16 编码单元计数
+type source []string
+
+func newSource(text string) source {
+	return strings.Split(text, "\n")
+}
+
+// position 把 token 的行列转换为 LSP 的位置，超出范围时取最近的合法位置
+func (s source) position(line, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(s) {
+		last := s[len(s)-1]
+		return Position{Line: len(s) - 1, Character: utf16Len(last)}
+	}
+
+	text := s[line-1]
+	offset := column - 1
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	return Position{Line: line - 1, Character: utf16Len(text[:offset])}
+}
+
+// column 把 LSP 的位置转换为 token 的行列
+func (s source) column(pos Position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(s) {
+		return pos.Line + 1, pos.Character + 1
+	}
+
+	text := s[pos.Line]
+	n := 0
+	for i, r := range text {
+		if n >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		n += utf16RuneLen(r)
+	}
+
+	return pos.Line + 1, len(text) + 1
+}
+
+// tokenRange 返回 token 字面量在文档中的范围
+func (s source) tokenRange(tok token.Token) Range {
+	return Range{
+		Start: s.position(tok.Line, tok.Column),
+		End:   s.position(tok.Line, tok.Column+len(tok.Literal)),
+	}
+}
+
+// wordRange 返回从 line:column 开始的单词的范围，用于只有位置没有 token 的检查结果
+func (s source) wordRange(line, column int) Range {
+	end := column
+	if line >= 1 && line <= len(s) {
+		text := s[line-1]
+		for end >= 1 && end-1 < len(text) && isWordByte(text[end-1]) {
+			end++
+		}
+	}
+
+	return Range{Start: s.position(line, column), End: s.position(line, end)}
+}
+
+func isWordByte(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_'
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+
+	return n
+}
+
+// utf16RuneLen 字符按 UTF-16 编码时占用的编码单元数，非法的字节按一个字符计算
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+
+	return 1
+}
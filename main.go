@@ -7,7 +7,62 @@ import (
 	user2 "os/user"
 )
 
+const usage = `usage:
+  monkey                      start the REPL
+  monkey run [-O] <file>      compile and run a file
+      [--profile] [--profile-top n] [--profile-folded file]
+                              print a function profile after the run
+      [--trace] [--trace-events file]
+                              print every instruction, or write a timeline
+      [--cover] [--coverprofile file]
+                              report line coverage, merged into file
+  monkey cover [-html file] [-o file] <profile>...
+                              merge coverage profiles and report them
+  monkey test [-v] [-run regexp] [--cover] [--coverprofile file] [path...]
+                              run the test_ functions in *_test.mk files
+  monkey debug <file>         run a file in the bytecode debugger
+  monkey lint <file>...       report suspicious code
+  monkey fmt [-d] <file>...   format files in place, or print a diff with -d
+  monkey tokens [--json] <file>
+                              print the tokens of a file
+  monkey ast [--json] <file>  print the syntax tree of a file
+  monkey lsp                  start a language server on stdin and stdout
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		startRepl()
+		return
+	}
+
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runCommand(os.Args[2:]))
+	case "test":
+		os.Exit(testCommand(os.Args[2:]))
+	case "cover":
+		os.Exit(coverCommand(os.Args[2:]))
+	case "debug":
+		os.Exit(debugCommand(os.Args[2:]))
+	case "lint":
+		os.Exit(lintCommand(os.Args[2:]))
+	case "fmt":
+		os.Exit(fmtCommand(os.Args[2:]))
+	case "tokens":
+		os.Exit(tokensCommand(os.Args[2:]))
+	case "ast":
+		os.Exit(astCommand(os.Args[2:]))
+	case "lsp":
+		os.Exit(lspCommand(os.Args[2:]))
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+func startRepl() {
 	user, err := user2.Current()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,143 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+)
+
+// Equal 判断两个对象的值是否相等
+// 整数(包括 BigInt)、布尔值、null 和字符串按值比较，数组逐个元素比较，哈希比较键值对且与插入顺序无关，
+// 函数等其他对象只有同一个对象才相等
+func Equal(left Object, right Object) bool {
+	if left == right {
+		return true
+	}
+
+	if left == nil || right == nil || left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *Integer:
+		if right, ok := right.(*Integer); ok {
+			return left.Value == right.Value
+		}
+		result, ok := compareIntegers(left, right)
+		return ok && result == 0
+	case *BigInt:
+		result, ok := compareIntegers(left, right)
+		return ok && result == 0
+	case *Boolean:
+		return left.Value == right.(*Boolean).Value
+	case *Null:
+		return true
+	case *String:
+		return left.Value == right.(*String).Value
+	case *Array:
+		right := right.(*Array)
+		if len(left.Elements) != len(right.Elements) {
+			return false
+		}
+
+		for i, el := range left.Elements {
+			if !Equal(el, right.Elements[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *Hash:
+		right := right.(*Hash)
+		if left.Len() != right.Len() {
+			return false
+		}
+
+		for key, pair := range left.pairs {
+			other, ok := right.pairs[key]
+			if !ok || !Equal(pair.Value, other.Value) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Compare 比较两个对象的大小，left 小于、等于、大于 right 时分别返回 -1、0、1
+// 支持整数、字符串(按 unicode 码点)和数组(按元素的字典序)，不支持比较时 ok 为 false
+func Compare(left Object, right Object) (result int, ok bool) {
+	if left.Type() != right.Type() {
+		return 0, false
+	}
+
+	switch left := left.(type) {
+	case *Integer:
+		other, ok := right.(*Integer)
+		if !ok {
+			return compareIntegers(left, right)
+		}
+
+		rightValue := other.Value
+		switch {
+		case left.Value < rightValue:
+			return -1, true
+		case left.Value > rightValue:
+			return 1, true
+		}
+		return 0, true
+	case *BigInt:
+		return compareIntegers(left, right)
+	case *String:
+		return strings.Compare(left.Value, right.(*String).Value), true
+	case *Array:
+		right := right.(*Array)
+		for i, el := range left.Elements {
+			if i >= len(right.Elements) {
+				return 1, true
+			}
+
+			result, ok := Compare(el, right.Elements[i])
+			if !ok {
+				return 0, false
+			}
+			if result != 0 {
+				return result, true
+			}
+		}
+
+		if len(left.Elements) < len(right.Elements) {
+			return -1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// HashKeyOf 返回对象作为哈希键时的 HashKey，只由可哈希的值组成的数组也可以作为键
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		h := fnv.New64a()
+		var buf [8]byte
+		for _, el := range obj.Elements {
+			key, ok := HashKeyOf(el)
+			if !ok {
+				return HashKey{}, false
+			}
+
+			_, _ = h.Write([]byte(key.Type))
+			binary.LittleEndian.PutUint64(buf[:], key.Value)
+			_, _ = h.Write(buf[:])
+		}
+
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+	}
+
+	return HashKey{}, false
+}
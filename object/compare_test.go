@@ -0,0 +1,101 @@
+package object
+
+import (
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	hash := func(pairs ...interface{}) Object {
+		h := NewHash(0)
+		for i := 0; i < len(pairs); i += 2 {
+			key, _ := FromGo(pairs[i])
+			value, _ := FromGo(pairs[i+1])
+			hashKey, _ := HashKeyOf(key)
+			h.Set(hashKey, HashPair{Key: key, Value: value})
+		}
+		return h
+	}
+	array := func(v interface{}) Object {
+		obj, _ := FromGo(v)
+		return obj
+	}
+	fn := &Builtin{}
+
+	tests := []struct {
+		left     Object
+		right    Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{NULL, NULL, true},
+		{TRUE, &Boolean{Value: true}, true},
+		{array([]int{1, 2}), array([]int{1, 2}), true},
+		{array([]int{1, 2}), array([]int{1}), false},
+		{array([]interface{}{[]int{1}, "a"}), array([]interface{}{[]int{1}, "a"}), true},
+		{hash("a", 1, "b", 2), hash("b", 2, "a", 1), true},
+		{hash("a", 1), hash("a", 2), false},
+		{hash("a", 1), hash("b", 1), false},
+		{fn, fn, true},
+		{fn, &Builtin{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := Equal(tt.left, tt.right); got != tt.expected {
+			t.Errorf("Equal(%s, %s) wrong. want=%t, got=%t", tt.left.Inspect(), tt.right.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	array := func(v interface{}) Object {
+		obj, _ := FromGo(v)
+		return obj
+	}
+
+	tests := []struct {
+		left     Object
+		right    Object
+		expected int
+		ok       bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 2}, -1, true},
+		{&String{Value: "b"}, &String{Value: "a"}, 1, true},
+		{&String{Value: "z"}, &String{Value: "é"}, -1, true},
+		{array([]int{1, 2}), array([]int{1, 3}), -1, true},
+		{array([]int{1, 2}), array([]int{1}), 1, true},
+		{array([]string{"a"}), array([]string{"a"}), 0, true},
+		{array([]interface{}{1}), array([]interface{}{"a"}), 0, false},
+		{TRUE, FALSE, 0, false},
+		{&Integer{Value: 1}, &String{Value: "a"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		result, ok := Compare(tt.left, tt.right)
+		if result != tt.expected || ok != tt.ok {
+			t.Errorf("Compare(%s, %s) wrong. want=(%d, %t), got=(%d, %t)",
+				tt.left.Inspect(), tt.right.Inspect(), tt.expected, tt.ok, result, ok)
+		}
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a, _ := HashKeyOf(&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}})
+	b, _ := HashKeyOf(&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}})
+	c, _ := HashKeyOf(&Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}})
+
+	if a != b {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+
+	if a == c {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	if _, ok := HashKeyOf(&Array{Elements: []Object{&Hash{}}}); ok {
+		t.Errorf("array containing a hash should not be hashable")
+	}
+}
@@ -0,0 +1,521 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// tagName 结构体字段通过 `monkey:"name"` 指定在 Hash 中的键名，`monkey:"-"` 表示忽略该字段
+const tagName = "monkey"
+
+var objectType = reflect.TypeOf((*Object)(nil)).Elem()
+
+// ConversionError Go 值与 Object 之间转换失败，Path 为出错的位置，如 servers[1].port
+type ConversionError struct {
+	Path string
+	Msg  string
+}
+
+func (e *ConversionError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+func conversionError(path string, format string, a ...interface{}) *ConversionError {
+	return &ConversionError{Path: path, Msg: fmt.Sprintf(format, a...)}
+}
+
+// FromGo 将 Go 值转换为 Object
+// 整数 -> Integer，string -> String，bool -> Boolean，slice/array -> Array(nil slice 为空数组)，map/struct -> Hash，nil -> NULL
+// 指针和 interface 会被解引用，Object 类型的值原样返回
+func FromGo(v interface{}) (Object, error) {
+	return fromValue(reflect.ValueOf(v), "")
+}
+
+func fromValue(v reflect.Value, path string) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return NULL, nil
+			}
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromValue(v.Elem(), path)
+
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, conversionError(path, "integer %d overflows INTEGER", u)
+		}
+		return &Integer{Value: int64(u)}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		// nil slice 与空 slice 一样转换为空数组
+		elements := make([]Object, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			el, err := fromValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+
+		// map 的遍历顺序不固定，按键排序后再放入 Hash
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessMapKey(keys[i], keys[j])
+		})
+
+		hash := NewHash(len(keys))
+		for _, k := range keys {
+			elemPath := fmt.Sprintf("%s[%v]", path, k.Interface())
+			key, err := fromValue(k, elemPath)
+			if err != nil {
+				return nil, err
+			}
+
+			hashKey, ok := HashKeyOf(key)
+			if !ok {
+				return nil, conversionError(elemPath, "unusable as hash key: %s", key.Type())
+			}
+
+			value, err := fromValue(v.MapIndex(k), elemPath)
+			if err != nil {
+				return nil, err
+			}
+
+			hash.Set(hashKey, HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+
+	case reflect.Struct:
+		hash := NewHash(0)
+		for _, f := range structFields(v.Type()) {
+			field, ok := fieldByIndex(v, f.index)
+			if !ok || f.omitEmpty && field.IsZero() {
+				continue
+			}
+
+			value, err := fromValue(field, joinPath(path, f.name))
+			if err != nil {
+				return nil, err
+			}
+
+			key := &String{Value: f.name}
+			hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	}
+
+	return nil, conversionError(path, "unsupported Go type %s", v.Type())
+}
+
+// lessMapKey map 键的排序规则，先按 Kind，同一 Kind 的数字和字符串按自然顺序，其余按 fmt.Sprint 的结果
+func lessMapKey(a reflect.Value, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// ToGo 将 Object 转换为 Go 值并写入 target，target 必须是非 nil 的指针
+// 转换规则与 FromGo 相反，NULL 会被转换为对应类型的零值，目标类型为 interface{} 时使用
+// int64、string、bool、[]interface{}、map[string]interface{} 等默认类型
+func ToGo(obj Object, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return conversionError("", "target must be a non-nil pointer, got %T", target)
+	}
+
+	return toValue(obj, rv.Elem(), "")
+}
+
+func toValue(obj Object, v reflect.Value, path string) error {
+	t := v.Type()
+
+	// 目标本身是 Object 类型时不做转换
+	if t.Implements(objectType) {
+		if obj == nil {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+
+		ov := reflect.ValueOf(obj)
+		if !ov.Type().AssignableTo(t) {
+			return mismatch(path, obj, t)
+		}
+		v.Set(ov)
+		return nil
+	}
+
+	if obj == nil || obj.Type() == NULL_OBJ {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := toValue(obj, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Interface:
+		native, err := toNative(obj, path)
+		if err != nil {
+			return err
+		}
+
+		nv := reflect.ValueOf(native)
+		if !nv.Type().AssignableTo(t) {
+			return conversionError(path, "cannot convert %s to %s", obj.Type(), t)
+		}
+		v.Set(nv)
+		return nil
+
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+		v.SetBool(b.Value)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if big, ok := obj.(*BigInt); ok {
+			return conversionError(path, "integer %s overflows %s", big.Value, t)
+		}
+
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+		if v.OverflowInt(i.Value) {
+			return conversionError(path, "integer %d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if big, ok := obj.(*BigInt); ok {
+			return conversionError(path, "integer %s overflows %s", big.Value, t)
+		}
+
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return conversionError(path, "integer %d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+		return nil
+
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+		v.SetString(s.Value)
+		return nil
+
+	case reflect.Slice:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			if err := toValue(el, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+
+	case reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+		if len(arr.Elements) != t.Len() {
+			return conversionError(path, "array length %d does not match %s", len(arr.Elements), t)
+		}
+
+		for i, el := range arr.Elements {
+			if err := toValue(el, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+
+		m := reflect.MakeMapWithSize(t, len(hash.pairs))
+		for _, pair := range hash.pairs {
+			elemPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+			key := reflect.New(t.Key()).Elem()
+			if err := toValue(pair.Key, key, elemPath); err != nil {
+				return err
+			}
+
+			value := reflect.New(t.Elem()).Elem()
+			if err := toValue(pair.Value, value, elemPath); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(path, obj, t)
+		}
+
+		for _, f := range structFields(t) {
+			key := &String{Value: f.name}
+			pair, ok := hash.pairs[key.HashKey()]
+			if !ok {
+				continue
+			}
+
+			field, ok := fieldByIndexAlloc(v, f.index)
+			if !ok {
+				continue
+			}
+			if err := toValue(pair.Value, field, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return conversionError(path, "unsupported Go type %s", t)
+}
+
+// toNative 将 Object 转换为不指定目标类型时的默认 Go 值
+func toNative(obj Object, path string) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		result := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			v, err := toNative(el, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	case *Hash:
+		// 键全部为字符串时使用 map[string]interface{}，否则使用 map[interface{}]interface{}
+		allStrings := true
+		for _, pair := range obj.pairs {
+			if pair.Key.Type() != STRING_OBJ {
+				allStrings = false
+				break
+			}
+		}
+
+		if allStrings {
+			result := make(map[string]interface{}, len(obj.pairs))
+			for _, pair := range obj.pairs {
+				key := pair.Key.(*String).Value
+				v, err := toNative(pair.Value, joinPath(path, key))
+				if err != nil {
+					return nil, err
+				}
+				result[key] = v
+			}
+			return result, nil
+		}
+
+		result := make(map[interface{}]interface{}, len(obj.pairs))
+		for _, pair := range obj.pairs {
+			elemPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+			key, err := toNative(pair.Key, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			v, err := toNative(pair.Value, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+	}
+
+	return nil, conversionError(path, "cannot convert %s to a Go value", obj.Type())
+}
+
+type fieldInfo struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields 解析结构体的可导出字段，匿名结构体字段会被展开
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, inner := range structFields(ft) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, fieldInfo{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: opts == "omitempty",
+		})
+	}
+
+	return fields
+}
+
+// fieldByIndex 与 reflect.Value.FieldByIndex 相同，途经 nil 指针时返回 false
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// fieldByIndexAlloc 与 reflect.Value.FieldByIndex 相同，但会为途经的 nil 指针分配内存，
+// 途经的 nil 指针是不可导出的匿名字段时无法赋值，返回 false
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+func mismatch(path string, obj Object, t reflect.Type) error {
+	return conversionError(path, "cannot convert %s to %s", obj.Type(), t)
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
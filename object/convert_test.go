@@ -0,0 +1,288 @@
+package object
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type testServer struct {
+	Host    string `monkey:"host"`
+	Port    int    `monkey:"port"`
+	Enabled bool   `monkey:"enabled"`
+}
+
+type testConfig struct {
+	Name    string            `monkey:"name"`
+	Servers []testServer      `monkey:"servers"`
+	Labels  map[string]string `monkey:"labels"`
+	Backup  *testServer       `monkey:"backup"`
+	Retries *int              `monkey:"retries,omitempty"`
+	Secret  string            `monkey:"-"`
+	Count   uint8
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{1, "1"},
+		{int8(-3), "-3"},
+		{uint32(7), "7"},
+		{"monkey", "monkey"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[]int(nil), "[]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]interface{}{1, "a", false, nil}, "[1, a, false, null]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{&testServer{Host: "localhost", Port: 80}, "{enabled: false, host: localhost, port: 80}"},
+		{(*testServer)(nil), "null"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+
+		if got := inspectSorted(obj); got != tt.expected {
+			t.Errorf("FromGo(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFromGoMapOrder(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{map[int]string{10: "a", 2: "b", -1: "c"}, "{-1: c, 2: b, 10: a}"},
+		{map[string]int{"b": 1, "a": 2, "B": 3}, "{B: 3, a: 2, b: 1}"},
+		{map[interface{}]int{"x": 1, 10: 2, 9: 3, true: 4}, "{true: 4, 9: 3, 10: 2, x: 1}"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo(%#v) returned error: %s", tt.input, err)
+		}
+
+		if got := obj.Inspect(); got != tt.expected {
+			t.Errorf("FromGo(%#v) wrong order. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFromGoSingletons(t *testing.T) {
+	obj, _ := FromGo(true)
+	if obj != TRUE {
+		t.Errorf("FromGo(true) is not TRUE singleton")
+	}
+
+	obj, _ = FromGo(nil)
+	if obj != NULL {
+		t.Errorf("FromGo(nil) is not NULL singleton")
+	}
+}
+
+func TestFromGoErrors(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{1.5, "unsupported Go type float64"},
+		{uint64(1 << 63), "integer 9223372036854775808 overflows INTEGER"},
+		{[]interface{}{1, func() {}}, "[1]: unsupported Go type func()"},
+		{map[string]interface{}{"x": []float32{1}}, "[x][0]: unsupported Go type float32"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromGo(tt.input)
+		if err == nil {
+			t.Errorf("FromGo(%#v) expected error", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestToGoRoundTrip(t *testing.T) {
+	retries := 3
+	input := testConfig{
+		Name: "prod",
+		Servers: []testServer{
+			{Host: "a", Port: 1, Enabled: true},
+			{Host: "b", Port: 2},
+		},
+		Labels:  map[string]string{"team": "core"},
+		Backup:  &testServer{Host: "c", Port: 3},
+		Retries: &retries,
+		Secret:  "hidden",
+		Count:   9,
+		private: 1,
+	}
+
+	obj, err := FromGo(input)
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	hash := obj.(*Hash)
+	if _, ok := hash.Get((&String{Value: "Secret"}).HashKey()); ok {
+		t.Errorf("ignored field was converted")
+	}
+
+	var output testConfig
+	err = ToGo(obj, &output)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+
+	input.Secret = ""
+	input.private = 0
+	if !reflect.DeepEqual(input, output) {
+		t.Errorf("round trip mismatch.\nwant=%+v\ngot =%+v", input, output)
+	}
+}
+
+type testLevel struct {
+	Level int `monkey:"level"`
+}
+
+type testEmbedded struct {
+	*testLevel
+	Name string `monkey:"name"`
+}
+
+func TestToGoUnexportedEmbedded(t *testing.T) {
+	obj, err := FromGo(map[string]interface{}{"name": "x", "level": 3})
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	// nil 的不可导出匿名指针无法分配，跳过其中的字段
+	var output testEmbedded
+	if err := ToGo(obj, &output); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if output.Name != "x" || output.testLevel != nil {
+		t.Errorf("wrong result. got=%+v", output)
+	}
+
+	output = testEmbedded{testLevel: &testLevel{}}
+	if err := ToGo(obj, &output); err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if output.Name != "x" || output.Level != 3 {
+		t.Errorf("wrong result. got=%+v", output)
+	}
+}
+
+func TestToGoNative(t *testing.T) {
+	obj, err := FromGo(map[string]interface{}{
+		"a": []int{1, 2},
+		"b": nil,
+		"c": "s",
+	})
+	if err != nil {
+		t.Fatalf("FromGo returned error: %s", err)
+	}
+
+	var output interface{}
+	err = ToGo(obj, &output)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"a": []interface{}{int64(1), int64(2)},
+		"b": nil,
+		"c": "s",
+	}
+	if !reflect.DeepEqual(expected, output) {
+		t.Errorf("wrong native value. want=%#v, got=%#v", expected, output)
+	}
+
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10)
+	err = ToGo(NewInteger(huge), &output)
+	if err != nil {
+		t.Fatalf("ToGo returned error: %s", err)
+	}
+	if got, ok := output.(*big.Int); !ok || got.Cmp(huge) != 0 {
+		t.Errorf("wrong native big integer. want=%s, got=%#v", huge, output)
+	}
+
+	var target Object
+	err = ToGo(obj, &target)
+	if err != nil || target != obj {
+		t.Errorf("ToGo into Object should keep the original object. got=%v, err=%v", target, err)
+	}
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i int
+	var i8 int8
+	var u uint
+	var s string
+	var arr [2]int
+	var cfg testConfig
+
+	tests := []struct {
+		input    interface{}
+		target   interface{}
+		expected string
+	}{
+		{"x", &i, "cannot convert STRING to int"},
+		{300, &i8, "integer 300 overflows int8"},
+		{-1, &u, "integer -1 overflows uint"},
+		{1, &s, "cannot convert INTEGER to string"},
+		{[]int{1}, &arr, "array length 1 does not match [2]int"},
+		{map[string]interface{}{"servers": []interface{}{map[string]interface{}{"port": "80"}}}, &cfg,
+			"servers[0].port: cannot convert STRING to int"},
+		{1, i, "target must be a non-nil pointer, got int"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo returned error: %s", err)
+		}
+
+		err = ToGo(obj, tt.target)
+		if err == nil {
+			t.Errorf("ToGo(%s) expected error", obj.Inspect())
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+// inspectSorted 与 Inspect 相同，但 Hash 的键按字符串排序，便于比较
+func inspectSorted(obj Object) string {
+	hash, ok := obj.(*Hash)
+	if !ok {
+		return obj.Inspect()
+	}
+
+	pairs := make([]string, 0, hash.Len())
+	for _, pair := range hash.OrderedPairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+inspectSorted(pair.Value))
+	}
+	sort.Strings(pairs)
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
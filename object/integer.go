@@ -0,0 +1,180 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// ErrDivisionByZero 除以 0 或对 0 取模
+var ErrDivisionByZero = errors.New("division by zero")
+
+// BigInt 超出 int64 范围的整数
+// 整数运算溢出时自动提升为 BigInt，结果回到 int64 范围内时再降回 Integer，
+// 所以 BigInt 的值一定不在 int64 范围内，对用户来说两者都是 INTEGER
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() Type {
+	return INTEGER_OBJ
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+// bigIntKeyType BigInt 的 HashKey 使用单独的类型，散列值不会与 Integer 的 HashKey 冲突
+const bigIntKeyType Type = "BIGINT"
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(b.Value.String()))
+	return HashKey{Type: bigIntKeyType, Value: h.Sum64()}
+}
+
+// NewInteger 根据 value 的大小返回 Integer 或 BigInt
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInt{Value: value}
+}
+
+// IsInteger 判断 obj 是否为 Integer 或 BigInt
+func IsInteger(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	}
+
+	return false
+}
+
+// IntegerArithmetic 计算两个整数的 +、-、*、/、%，除法和取模向 0 取整
+// int64 运算溢出时结果提升为 BigInt，除数为 0 时返回 ErrDivisionByZero
+func IntegerArithmetic(operator string, left Object, right Object) (Object, error) {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		if (operator == "/" || operator == "%") && r.Value == 0 {
+			return nil, ErrDivisionByZero
+		}
+
+		if result, ok := int64Arithmetic(operator, l.Value, r.Value); ok {
+			return &Integer{Value: result}, nil
+		}
+	}
+
+	a, ok := bigValue(left)
+	if !ok {
+		return nil, fmt.Errorf("unknown integer operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	b, ok := bigValue(right)
+	if !ok {
+		return nil, fmt.Errorf("unknown integer operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/", "%":
+		if b.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if operator == "/" {
+			result.Quo(a, b)
+		} else {
+			result.Rem(a, b)
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return NewInteger(result), nil
+}
+
+// NegateInteger 返回 -obj，-math.MinInt64 会提升为 BigInt
+func NegateInteger(obj Object) (Object, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		if obj.Value != math.MinInt64 {
+			return &Integer{Value: -obj.Value}, true
+		}
+		return NewInteger(new(big.Int).Neg(big.NewInt(obj.Value))), true
+	case *BigInt:
+		return NewInteger(new(big.Int).Neg(obj.Value)), true
+	}
+
+	return nil, false
+}
+
+// compareIntegers 比较两个整数的大小，其中可能有 BigInt
+func compareIntegers(left Object, right Object) (int, bool) {
+	a, ok := bigValue(left)
+	if !ok {
+		return 0, false
+	}
+
+	b, ok := bigValue(right)
+	if !ok {
+		return 0, false
+	}
+
+	return a.Cmp(b), true
+}
+
+// bigValue 将 Integer 或 BigInt 转换为 big.Int
+func bigValue(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInt:
+		return obj.Value, true
+	}
+
+	return nil, false
+}
+
+// int64Arithmetic 计算 int64 之间的运算，溢出时 ok 为 false，调用前需要排除除数为 0
+func int64Arithmetic(operator string, a int64, b int64) (result int64, ok bool) {
+	switch operator {
+	case "+":
+		result = a + b
+		// 两个同号的数相加，结果的符号不同时溢出
+		return result, (a >= 0) != (b >= 0) || (result >= 0) == (a >= 0)
+	case "-":
+		result = a - b
+		return result, (a >= 0) == (b >= 0) || (result >= 0) == (a >= 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		result = a * b
+		if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, false
+		}
+		return result, result/b == a
+	case "/":
+		if a == math.MinInt64 && b == -1 {
+			return 0, false
+		}
+		return a / b, true
+	case "%":
+		if b == -1 {
+			return 0, true
+		}
+		return a % b, true
+	}
+
+	return 0, false
+}
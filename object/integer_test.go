@@ -0,0 +1,88 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestIntegerArithmetic(t *testing.T) {
+	bigInt := func(s string) Object {
+		v, _ := new(big.Int).SetString(s, 10)
+		return &BigInt{Value: v}
+	}
+	integer := func(v int64) Object {
+		return &Integer{Value: v}
+	}
+
+	tests := []struct {
+		operator string
+		left     Object
+		right    Object
+		expected string
+		big      bool
+	}{
+		{"+", integer(1), integer(2), "3", false},
+		{"%", integer(-7), integer(3), "-1", false},
+		{"+", integer(math.MaxInt64), integer(1), "9223372036854775808", true},
+		{"-", integer(math.MinInt64), integer(1), "-9223372036854775809", true},
+		{"*", integer(math.MaxInt64), integer(2), "18446744073709551614", true},
+		{"*", integer(math.MinInt64), integer(-1), "9223372036854775808", true},
+		{"/", integer(math.MinInt64), integer(-1), "9223372036854775808", true},
+		{"%", integer(math.MinInt64), integer(-1), "0", false},
+		{"-", bigInt("9223372036854775808"), integer(1), "9223372036854775807", false},
+		{"/", bigInt("18446744073709551616"), integer(4), "4611686018427387904", false},
+		{"%", bigInt("18446744073709551617"), integer(10), "7", false},
+		{"*", bigInt("18446744073709551616"), bigInt("18446744073709551616"), "340282366920938463463374607431768211456", true},
+	}
+
+	for _, tt := range tests {
+		result, err := IntegerArithmetic(tt.operator, tt.left, tt.right)
+		if err != nil {
+			t.Errorf("%s %s %s returned error: %s", tt.left.Inspect(), tt.operator, tt.right.Inspect(), err)
+			continue
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s %s %s wrong result. want=%s, got=%s", tt.left.Inspect(), tt.operator, tt.right.Inspect(), tt.expected, result.Inspect())
+		}
+
+		if _, isBig := result.(*BigInt); isBig != tt.big {
+			t.Errorf("%s %s %s wrong result type. want big=%t, got=%T", tt.left.Inspect(), tt.operator, tt.right.Inspect(), tt.big, result)
+		}
+	}
+
+	for _, operator := range []string{"/", "%"} {
+		for _, left := range []Object{integer(1), bigInt("18446744073709551616")} {
+			if _, err := IntegerArithmetic(operator, left, integer(0)); err != ErrDivisionByZero {
+				t.Errorf("%s %s 0 wrong error. want=%v, got=%v", left.Inspect(), operator, ErrDivisionByZero, err)
+			}
+		}
+	}
+
+	negated, _ := NegateInteger(integer(math.MinInt64))
+	if negated.Inspect() != "9223372036854775808" {
+		t.Errorf("wrong negation of MinInt64: %s", negated.Inspect())
+	}
+
+	big1, big2 := bigInt("18446744073709551616"), bigInt("18446744073709551616")
+	if !Equal(big1, big2) || Equal(big1, integer(0)) {
+		t.Errorf("wrong equality of big integers")
+	}
+
+	if result, ok := Compare(integer(math.MaxInt64), big1); !ok || result != -1 {
+		t.Errorf("wrong comparison of integer and big integer: %d %t", result, ok)
+	}
+
+	key1, _ := HashKeyOf(big1)
+	key2, _ := HashKeyOf(big2)
+	if key1 != key2 {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+
+	// 2^64 的散列值与这个 int64 相同，两者的 HashKey 不能相等
+	colliding, _ := HashKeyOf(integer(-1300789964862373523))
+	if key1 == colliding {
+		t.Errorf("big integer and integer have the same hash key")
+	}
+}
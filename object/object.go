@@ -25,6 +25,15 @@ const (
 	HASH_OBJ             = "HASH"
 	COMPILE_FUNCTION_OBJ = "COMPILE_FUNCTION_OBJ"
 	CLOSURE_OBJ          = "CLOSURE"
+	QUOTE_OBJ            = "QUOTE"
+	MACRO_OBJ            = "MACRO"
+)
+
+// NULL TRUE FALSE 为各执行引擎共用的单例，引擎中通过指针比较判断真假
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
 )
 
 type Object interface {
@@ -139,6 +148,13 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+
+	// 以下为调试信息，不影响执行
+	Name       string         // let 绑定的函数名，匿名函数为空
+	Line       int            // 函数定义所在的行，未知时为 0
+	Lines      code.LineTable // 指令对应的源码行
+	LocalNames []string       // 局部变量名，下标为局部变量的索引，同名变量重复定义时之前的为空
+	FreeNames  []string       // 自由变量名，下标为自由变量的索引
 }
 
 func (c *CompiledFunction) Type() Type {
@@ -180,7 +196,7 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
-type BuiltFunction func(args ...Object) Object
+type BuiltFunction func(rt *Runtime, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltFunction
@@ -226,8 +242,83 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash 保留键的插入顺序，只能通过 NewHash 创建、通过 Set 和 Delete 修改，pairs 和 keys 始终一致
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs map[HashKey]HashPair
+	keys  []HashKey // 键的插入顺序
+}
+
+// NewHash 创建空的 Hash，size 为预分配的容量
+func NewHash(size int) *Hash {
+	return &Hash{
+		pairs: make(map[HashKey]HashPair, size),
+		keys:  make([]HashKey, 0, size),
+	}
+}
+
+// Set 设置键值对，键已存在时只更新值，保留原来的位置
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.pairs == nil {
+		h.pairs = make(map[HashKey]HashPair)
+	}
+
+	if _, ok := h.pairs[key]; !ok {
+		h.keys = append(h.keys, key)
+	}
+	h.pairs[key] = pair
+}
+
+// Get 按 HashKey 查找键值对
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.pairs[key]
+	return pair, ok
+}
+
+// Delete 删除键值对，其余键的顺序不变
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.pairs[key]; !ok {
+		return
+	}
+
+	delete(h.pairs, key)
+	for i, k := range h.keys {
+		if k == key {
+			h.keys = append(h.keys[:i], h.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len 键值对的数量
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Keys 按插入顺序返回所有 HashKey
+func (h *Hash) Keys() []HashKey {
+	keys := make([]HashKey, len(h.keys))
+	copy(keys, h.keys)
+	return keys
+}
+
+// Copy 复制 Hash，键值对中的对象本身不会被复制
+func (h *Hash) Copy() *Hash {
+	result := NewHash(len(h.keys))
+	for _, k := range h.keys {
+		result.Set(k, h.pairs[k])
+	}
+
+	return result
+}
+
+// OrderedPairs 按插入顺序返回所有键值对
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, k := range h.keys {
+		pairs = append(pairs, h.pairs[k])
+	}
+
+	return pairs
 }
 
 func (h *Hash) Type() Type {
@@ -237,8 +328,9 @@ func (h *Hash) Type() Type {
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
-	pairs := make([]string, 0, len(h.Pairs))
-	for _, pair := range h.Pairs {
+	orderedPairs := h.OrderedPairs()
+	pairs := make([]string, 0, len(orderedPairs))
+	for _, pair := range orderedPairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -248,3 +340,44 @@ func (h *Hash) Inspect() string {
 
 	return out.String()
 }
+
+// Quote quote(expr) 的结果，保存没有求值的 AST 节点
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() Type {
+	return QUOTE_OBJ
+}
+
+// Inspect 返回节点的源码形式，宏可以用它输出表达式本身
+func (q *Quote) Inspect() string {
+	return q.Node.String()
+}
+
+// Macro 宏展开阶段使用的宏定义
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() Type {
+	return MACRO_OBJ
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := make([]string, 0, len(m.Parameters))
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
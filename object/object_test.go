@@ -22,3 +22,49 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have some hash keys")
 	}
 }
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash(0)
+	for _, key := range []string{"c", "a", "b", "a"} {
+		k := &String{Value: key}
+		hash.Set(k.HashKey(), HashPair{Key: k, Value: &Integer{Value: int64(hash.Len())}})
+	}
+
+	if got := hash.Inspect(); got != "{c: 0, a: 3, b: 2}" {
+		t.Errorf("wrong order after Set. got=%q", got)
+	}
+
+	copied := hash.Copy()
+	hash.Delete((&String{Value: "a"}).HashKey())
+	hash.Delete((&String{Value: "x"}).HashKey())
+	if got := hash.Inspect(); got != "{c: 0, b: 2}" {
+		t.Errorf("wrong order after Delete. got=%q", got)
+	}
+
+	if got := copied.Inspect(); got != "{c: 0, a: 3, b: 2}" {
+		t.Errorf("Delete modified the copy. got=%q", got)
+	}
+}
+
+func TestStdIOSharesStdin(t *testing.T) {
+	if StdIO().Stdin != StdIO().Stdin {
+		t.Errorf("StdIO created a new reader for os.Stdin")
+	}
+}
+
+func TestHashZeroValue(t *testing.T) {
+	var hash Hash
+	if _, ok := hash.Get((&Integer{Value: 1}).HashKey()); ok || hash.Len() != 0 || hash.Inspect() != "{}" {
+		t.Errorf("zero value Hash is not empty. got=%q", hash.Inspect())
+	}
+
+	for _, key := range []int64{3, 1, 2} {
+		k := &Integer{Value: key}
+		hash.Set(k.HashKey(), HashPair{Key: k, Value: TRUE})
+	}
+	hash.Delete((&Integer{Value: 1}).HashKey())
+
+	if got := hash.Inspect(); got != "{3: true, 2: true}" {
+		t.Errorf("wrong order. got=%q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// IO 程序运行时使用的标准输入、标准输出和标准错误
+type IO struct {
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewIO 创建 IO，stdin 已经是 *bufio.Reader 时直接使用，保证与调用方共用同一个缓冲区
+func NewIO(stdin io.Reader, stdout io.Writer, stderr io.Writer) *IO {
+	reader, ok := stdin.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(stdin)
+	}
+
+	return &IO{Stdin: reader, Stdout: stdout, Stderr: stderr}
+}
+
+var (
+	stdinOnce   sync.Once
+	stdinReader *bufio.Reader
+)
+
+// StdIO 使用进程标准输入输出的 IO，所有调用共用同一个 os.Stdin 的缓冲区，
+// 避免一个 IO 预读的输入另一个 IO 读不到
+func StdIO() *IO {
+	stdinOnce.Do(func() {
+		stdinReader = bufio.NewReader(os.Stdin)
+	})
+
+	return NewIO(stdinReader, os.Stdout, os.Stderr)
+}
+
+// Runtime 内置函数被调用时可以访问的运行时环境
+type Runtime struct {
+	IO *IO
+	// SizeLimit 检查即将创建的集合大小是否超出限制，为 nil 时不限制。
+	// 字符串按字节数计算，与 len 返回的字符数不同，含有多字节字符的字符串在 len 小于限制时也可能超出限制
+	SizeLimit func(size int) error
+	// Call 调用 Monkey 函数或内置函数，运行出错时返回错误，为 nil 时内置函数不能回调函数
+	Call func(fn Object, args ...Object) (Object, error)
+}
+
+// CheckSize 内置函数在分配大块内存之前调用，提前发现超出限制的结果
+func (rt *Runtime) CheckSize(size int) error {
+	if rt == nil || rt.SizeLimit == nil {
+		return nil
+	}
+
+	return rt.SizeLimit(size)
+}
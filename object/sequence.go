@@ -0,0 +1,129 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// 数组和字符串的下标访问和切片，字符串按 unicode 字符(rune)计算下标
+//
+// 下标规则:
+//   - 负数下标从末尾开始计算，-1 表示最后一个元素
+//   - x[i] 越界时返回 null
+//   - x[low:high] 包含 low 不包含 high，省略 low 表示从头开始，省略 high 表示到末尾
+//   - 切片的边界先按负数规则换算，再截断到 [0, len(x)]，low >= high 时返回空数组或空字符串
+
+// ElementAt 返回数组的元素或字符串中的字符，seq 不是数组或字符串、index 不是整数时 ok 为 false
+func ElementAt(seq Object, idx Object) (result Object, ok bool) {
+	index, ok := integerIndex(idx)
+	if !ok {
+		return nil, false
+	}
+
+	switch seq := seq.(type) {
+	case *Array:
+		i, ok := normalizeIndex(index, len(seq.Elements))
+		if !ok {
+			return NULL, true
+		}
+		return seq.Elements[i], true
+	case *String:
+		runes := []rune(seq.Value)
+		i, ok := normalizeIndex(index, len(runes))
+		if !ok {
+			return NULL, true
+		}
+		return &String{Value: string(runes[i])}, true
+	}
+
+	return nil, false
+}
+
+// Slice 返回 seq[low:high]，low 或 high 为 NULL 时表示省略
+func Slice(seq Object, low Object, high Object) (Object, error) {
+	var length int
+	var runes []rune
+	switch seq := seq.(type) {
+	case *Array:
+		length = len(seq.Elements)
+	case *String:
+		runes = []rune(seq.Value)
+		length = len(runes)
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", seq.Type())
+	}
+
+	start, err := sliceBound(low, 0, length)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := sliceBound(high, length, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if start > end {
+		start = end
+	}
+
+	if arr, ok := seq.(*Array); ok {
+		elements := make([]Object, end-start)
+		copy(elements, arr.Elements[start:end])
+		return &Array{Elements: elements}, nil
+	}
+
+	return &String{Value: string(runes[start:end])}, nil
+}
+
+// normalizeIndex 将负数下标换算为从头开始的下标，越界时 ok 为 false
+func normalizeIndex(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+
+	return int(index), true
+}
+
+// integerIndex 返回整数下标，超出 int64 范围的 BigInt 换算为 int64 的最大值或最小值，一定会越界
+func integerIndex(index Object) (int64, bool) {
+	switch index := index.(type) {
+	case *Integer:
+		return index.Value, true
+	case *BigInt:
+		if index.Value.Sign() < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	}
+
+	return 0, false
+}
+
+// sliceBound 计算切片的边界，bound 为 NULL 时使用默认值
+func sliceBound(bound Object, def int, length int) (int, error) {
+	if _, isNull := bound.(*Null); bound == nil || isNull {
+		return def, nil
+	}
+
+	i, ok := integerIndex(bound)
+	if !ok {
+		return 0, fmt.Errorf("slice index must be INTEGER, got %s", bound.Type())
+	}
+	if i < 0 {
+		i += int64(length)
+	}
+
+	switch {
+	case i < 0:
+		return 0, nil
+	case i > int64(length):
+		return length, nil
+	}
+
+	return int(i), nil
+}
@@ -0,0 +1,293 @@
+package optimizer
+
+import (
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/token"
+)
+
+// Optimize 在编译之前化简 AST，会直接修改传入的 program
+//
+// 目前包括:
+//   - 折叠字面量之间的算术、比较和字符串拼接，以及 !true、-5 这样的前缀表达式
+//   - 条件为字面量的 if 只保留会执行的分支
+//   - 删除没有副作用且结果没有被使用的表达式语句
+//
+// 运行时才会出现的错误(如除以 0、类型不匹配)和溢出为 BigInt 的运算不会被折叠，保持原来的行为
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = optimizeStatements(program.Statements)
+	return program
+}
+
+// optimizeStatements 优化语句列表，最后一条语句是整个列表的值，必须保留
+func optimizeStatements(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+		stmt = optimizeStatement(stmt)
+
+		exp, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+
+		if ifExp, ok := exp.Expression.(*ast.IfExpression); ok {
+			if taken, ok := constantBranch(ifExp); ok && canInline(taken, last) {
+				if taken != nil {
+					result = append(result, taken.Statements...)
+				}
+				continue
+			}
+		}
+
+		if !last && isPure(exp.Expression) {
+			continue
+		}
+
+		result = append(result, stmt)
+	}
+
+	return result
+}
+
+func optimizeStatement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = optimizeExpression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = optimizeExpression(stmt.Expression)
+	case *ast.BlockStatement:
+		optimizeBlock(stmt)
+	}
+
+	return stmt
+}
+
+func optimizeBlock(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = optimizeStatements(block.Statements)
+	}
+}
+
+func optimizeExpression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = optimizeExpression(exp.Right)
+		return foldPrefix(exp)
+	case *ast.InfixExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Right = optimizeExpression(exp.Right)
+		return foldInfix(exp)
+	case *ast.IfExpression:
+		exp.Condition = optimizeExpression(exp.Condition)
+		optimizeBlock(exp.Consequence)
+		optimizeBlock(exp.Alternative)
+
+		// 作为表达式使用时，只有分支中只有一个表达式才能直接替换
+		if taken, ok := constantBranch(exp); ok && taken != nil && len(taken.Statements) == 1 {
+			if stmt, ok := taken.Statements[0].(*ast.ExpressionStatement); ok {
+				return stmt.Expression
+			}
+		}
+	case *ast.FunctionLiteral:
+		optimizeBlock(exp.Body)
+	case *ast.CallExpression:
+		exp.Function = optimizeExpression(exp.Function)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = optimizeExpression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			exp.Elements[i] = optimizeExpression(el)
+		}
+	case *ast.HashLiteral:
+		for i, pair := range exp.Pairs {
+			exp.Pairs[i] = ast.HashPair{Key: optimizeExpression(pair.Key), Value: optimizeExpression(pair.Value)}
+		}
+	case *ast.IndexExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Index = optimizeExpression(exp.Index)
+	case *ast.SliceExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		if exp.Low != nil {
+			exp.Low = optimizeExpression(exp.Low)
+		}
+		if exp.High != nil {
+			exp.High = optimizeExpression(exp.High)
+		}
+	}
+
+	return exp
+}
+
+// foldPrefix 折叠 !字面量 和 -整数
+func foldPrefix(exp *ast.PrefixExpression) ast.Expression {
+	switch exp.Operator {
+	case "!":
+		// ! 只对 false 和 null 返回 true，其它字面量都返回 false
+		switch right := exp.Right.(type) {
+		case *ast.Boolean:
+			return newBoolean(!right.Value)
+		case *ast.IntegerLiteral, *ast.StringLiteral:
+			return newBoolean(false)
+		}
+	case "-":
+		if right, ok := literalValue(exp.Right); ok {
+			if negated, ok := object.NegateInteger(right); ok {
+				return newInteger(negated)
+			}
+		}
+	}
+
+	return exp
+}
+
+// foldInfix 折叠两边都是同类型字面量的中缀表达式，可能在运行时出错的表达式保持不变
+func foldInfix(exp *ast.InfixExpression) ast.Expression {
+	left, ok := literalValue(exp.Left)
+	if !ok {
+		return exp
+	}
+
+	right, ok := literalValue(exp.Right)
+	if !ok || left.Type() != right.Type() {
+		return exp
+	}
+
+	switch exp.Operator {
+	case "==":
+		return newBoolean(object.Equal(left, right))
+	case "!=":
+		return newBoolean(!object.Equal(left, right))
+	case "<", ">":
+		result, ok := object.Compare(left, right)
+		if !ok {
+			return exp
+		}
+		if exp.Operator == "<" {
+			return newBoolean(result < 0)
+		}
+		return newBoolean(result > 0)
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		switch exp.Operator {
+		case "+", "-", "*", "/", "%":
+			// 除以 0 需要在运行时报错，保持不变
+			result, err := object.IntegerArithmetic(exp.Operator, left, right)
+			if err != nil {
+				return exp
+			}
+			return newInteger(result)
+		}
+	case *object.String:
+		if exp.Operator == "+" {
+			return newString(left.Value + right.(*object.String).Value)
+		}
+	}
+
+	return exp
+}
+
+// constantBranch 条件为字面量时返回会执行的分支，没有 else 且条件为假时返回 nil
+func constantBranch(exp *ast.IfExpression) (*ast.BlockStatement, bool) {
+	condition, ok := literalValue(exp.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	if b, ok := condition.(*object.Boolean); ok && !b.Value {
+		return exp.Alternative, true
+	}
+
+	return exp.Consequence, true
+}
+
+// canInline 判断 if 语句能否替换为分支中的语句
+// 位于列表末尾时 if 的值会被使用，只有分支以表达式语句结尾时替换后的值才相同
+func canInline(taken *ast.BlockStatement, last bool) bool {
+	if !last {
+		return true
+	}
+
+	if taken == nil || len(taken.Statements) == 0 {
+		return false
+	}
+
+	_, ok := taken.Statements[len(taken.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// isPure 表达式求值时既没有副作用也不会出错
+func isPure(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			if !isPure(el) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			// 键必须可以哈希，否则会在运行时报错
+			if _, ok := literalValue(pair.Key); !ok || !isPure(pair.Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// literalValue 将整数、字符串和布尔字面量转换为对应的对象
+func literalValue(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		if exp.Big != nil {
+			return &object.BigInt{Value: exp.Big}, true
+		}
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	case *ast.Boolean:
+		return &object.Boolean{Value: exp.Value}, true
+	}
+
+	return nil, false
+}
+
+// newInteger 返回 Integer 或 BigInt 对应的字面量
+func newInteger(value object.Object) *ast.IntegerLiteral {
+	lit := &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: value.Inspect()}}
+	switch value := value.(type) {
+	case *object.Integer:
+		lit.Value = value.Value
+	case *object.BigInt:
+		lit.Big = value.Value
+	}
+
+	return lit
+}
+
+func newString(value string) *ast.StringLiteral {
+	return &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: value},
+		Value: value,
+	}
+}
+
+func newBoolean(value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+	}
+
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+}
@@ -0,0 +1,116 @@
+package optimizer
+
+import (
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"-5", "-5"},
+		{"-(2 + 3)", "-5"},
+		{"!true", "false"},
+		{"!!false", "false"},
+		{"!5", "false"},
+		{"1 < 2", "true"},
+		{"2 > 1 == false", "false"},
+		{`"a" + "b" + "c"`, "abc"},
+		{`"a" == "a"`, "true"},
+		{`"a" < "b"`, "true"},
+		{"let x = 2 * 3;", "let x = 6;"},
+		{"fn(a) { return a + (1 + 1); }", "fn(a) return (a + 2);"},
+		{"[1 + 1, {2 * 2: 3}][0]", "([2, {4:3}][0])"},
+		{"1 / 0", "(1 / 0)"},
+		{"7 % 3", "1"},
+		{"1 % 0", "(1 % 0)"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"1 + true", "(1 + true)"},
+		{`1 + "a"`, `(1 + a)`},
+		{"true > false", "(true > false)"},
+		{"x + 1 + 2", "((x + 1) + 2)"},
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (false) { 1 } else { 2 }", "2"},
+		{"if (1 > 2) { 1 } else { x; 2 }", "x2"},
+		{"if (false) { 1 }; 5", "5"},
+		{"if (false) { 1 }", "iffalse 1"},
+		{"let y = if (1 < 2) { 10 } else { 20 };", "let y = 10;"},
+		{"if (x) { 1 + 1 }", "ifx 2"},
+		{"1; \"a\"; [1, 2]; x; 3", "x3"},
+		{"fn() { 1; 2 }", "fn() 2"},
+		{"f(); 1", "f()1"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input))
+		if got := program.String(); got != tt.expected {
+			t.Errorf("Optimize(%q) wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestOptimizePreservesResults 优化前后在虚拟机和求值器中的结果相同
+func TestOptimizePreservesResults(t *testing.T) {
+	tests := []string{
+		"1 + 2 * 3",
+		`"mon" + "key"`,
+		"let f = fn(x) { if (true) { let y = x * 2; y + 1 } else { 0 } }; f(3)",
+		"let f = fn() { if (false) { 1 } }; f()",
+		"let f = fn() { if (true) { return 1; }; 2 }; f()",
+		"if (false) { 1 } else { let a = 5; a * a }",
+		"let a = [1, 2, 3]; a[1 + 1]",
+		"let h = {\"a\" + \"b\": 1 + 1}; h[\"ab\"]",
+		"[1, 2] == [1, 2]",
+		"1 + true",
+		"9223372036854775807 + 1 - 1",
+		"(9223372036854775807 + 1) % 7",
+		`"a" - "b"`,
+		"-true",
+	}
+
+	for _, input := range tests {
+		expected := runVM(t, parse(t, input))
+		if got := runVM(t, Optimize(parse(t, input))); got != expected {
+			t.Errorf("vm result changed for %q. want=%q, got=%q", input, expected, got)
+		}
+
+		expected = evaluator.Eval(parse(t, input), object.NewEnvironment()).Inspect()
+		if got := evaluator.Eval(Optimize(parse(t, input)), object.NewEnvironment()).Inspect(); got != expected {
+			t.Errorf("evaluator result changed for %q. want=%q, got=%q", input, expected, got)
+		}
+	}
+}
+
+func runVM(t *testing.T, program *ast.Program) string {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	return machine.LastPoppedStackElem().Inspect()
+}
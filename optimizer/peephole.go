@@ -0,0 +1,257 @@
+package optimizer
+
+import (
+	"github.com/Shea11012/interpreter_in_go/code"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"sort"
+)
+
+// OptimizeBytecode 对编译结果做窥孔优化，返回新的 Bytecode，不修改传入的指令和常量
+//
+// 目前包括:
+//   - 合并常量池中相同的整数和字符串常量，编译器生成的常量池已经去重，这一步用于其他来源的字节码
+//   - 跳转到 OpJump 的跳转直接跳到最终的目标(jump threading)
+//   - OpTrue、OpFalse、OpNull 后面紧跟 OpJumpNotTruthy 时改写为无条件跳转或删除
+//   - 删除 OpReturnValue、OpReturn、OpJump 之后不可达的指令
+//   - 删除压栈之后立即出栈的指令对
+//
+// 主程序中最后一次出栈的值会作为 LastPoppedStackElem 的结果，这个值对应的指令会被保留
+func OptimizeBytecode(bytecode *compiler.Bytecode) (*compiler.Bytecode, error) {
+	constants, remap := internConstants(bytecode.Constants)
+
+	for i, constant := range constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		instructions, lines, err := optimizeInstructions(fn.Instructions, fn.Lines, remap, false)
+		if err != nil {
+			return nil, err
+		}
+
+		optimized := *fn
+		optimized.Instructions = instructions
+		optimized.Lines = lines
+		constants[i] = &optimized
+	}
+
+	instructions, lines, err := optimizeInstructions(bytecode.Instructions, bytecode.Lines, remap, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiler.Bytecode{
+		Instructions: instructions,
+		Constants:    constants,
+		Lines:        lines,
+		GlobalNames:  bytecode.GlobalNames,
+	}, nil
+}
+
+// constantKey 用于比较整数和字符串常量是否相同
+type constantKey struct {
+	typ   object.Type
+	value interface{}
+}
+
+// internConstants 合并相同的整数和字符串常量，remap 为原下标到新下标的映射
+func internConstants(constants []object.Object) ([]object.Object, []int) {
+	result := make([]object.Object, 0, len(constants))
+	remap := make([]int, len(constants))
+	seen := make(map[constantKey]int)
+
+	for i, constant := range constants {
+		var key constantKey
+		switch constant := constant.(type) {
+		case *object.Integer:
+			key = constantKey{typ: constant.Type(), value: constant.Value}
+		case *object.String:
+			key = constantKey{typ: constant.Type(), value: constant.Value}
+		default:
+			remap[i] = len(result)
+			result = append(result, constant)
+			continue
+		}
+
+		if index, ok := seen[key]; ok {
+			remap[i] = index
+			continue
+		}
+
+		seen[key] = len(result)
+		remap[i] = len(result)
+		result = append(result, constant)
+	}
+
+	return result, remap
+}
+
+// pushOps 只压入一个值，没有其它副作用的指令
+var pushOps = map[code.Opcode]bool{
+	code.OpConstant:       true,
+	code.OpTrue:           true,
+	code.OpFalse:          true,
+	code.OpNull:           true,
+	code.OpGetGlobal:      true,
+	code.OpGetLocal:       true,
+	code.OpGetFree:        true,
+	code.OpCurrentClosure: true,
+}
+
+func optimizeInstructions(ins code.Instructions, lines code.LineTable, remap []int, main bool) (code.Instructions, code.LineTable, error) {
+	instructions, err := code.Decode(ins)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range instructions {
+		switch instructions[i].Op {
+		case code.OpConstant, code.OpClosure, code.OpGetBuiltin:
+			operands := append([]int(nil), instructions[i].Operands...)
+			operands[0] = remap[operands[0]]
+			instructions[i].Operands = operands
+		}
+	}
+
+	for {
+		changed := false
+		instructions, changed = peephole(instructions, main)
+		if !changed {
+			break
+		}
+	}
+
+	assembled, lines := code.AssembleWithLines(instructions, lines)
+	return assembled, lines, nil
+}
+
+// peephole 做一遍优化，返回优化后的指令以及是否有改动
+func peephole(instructions []code.Instruction, main bool) ([]code.Instruction, bool) {
+	normalizeTargets(instructions)
+	changed := threadJumps(instructions)
+	targets := jumpTargets(instructions)
+
+	// keepPop 主程序中最后一个出栈的指令，它的值是程序的结果
+	keepPop := -1
+	if main {
+		for i := len(instructions) - 1; i >= 0; i-- {
+			if op := instructions[i].Op; op == code.OpPop || op == code.OpSetGlobal {
+				keepPop = i
+				break
+			}
+		}
+	}
+
+	result := make([]code.Instruction, 0, len(instructions))
+	for i := 0; i < len(instructions); i++ {
+		ins := instructions[i]
+		var next *code.Instruction
+		if i+1 < len(instructions) && !targets[instructions[i+1].Offset] {
+			next = &instructions[i+1]
+		}
+
+		switch {
+		case next != nil && pushOps[ins.Op] && next.Op == code.OpPop && i+1 != keepPop:
+			i++
+			changed = true
+			continue
+		case next != nil && next.Op == code.OpJumpNotTruthy && ins.Op == code.OpTrue:
+			i++
+			changed = true
+			continue
+		case next != nil && next.Op == code.OpJumpNotTruthy && (ins.Op == code.OpFalse || ins.Op == code.OpNull):
+			result = append(result, code.Instruction{Op: code.OpJump, Operands: next.Operands, Offset: ins.Offset})
+			i++
+			changed = true
+			continue
+		case ins.Op == code.OpJump && jumpsToNext(instructions, i):
+			changed = true
+			continue
+		}
+
+		result = append(result, ins)
+
+		// 无条件跳转或返回之后，直到下一个跳转目标之前的指令都不会被执行
+		if ins.Op == code.OpJump || ins.Op == code.OpReturnValue || ins.Op == code.OpReturn {
+			for i+1 < len(instructions) && !targets[instructions[i+1].Offset] {
+				i++
+				changed = true
+			}
+		}
+	}
+
+	return result, changed
+}
+
+// normalizeTargets 目标指令被删除后，跳转目标改为原位置之后第一条保留下来的指令
+func normalizeTargets(instructions []code.Instruction) {
+	for i := range instructions {
+		if !code.IsJump(instructions[i].Op) {
+			continue
+		}
+
+		target := instructions[i].Operands[0]
+		j := sort.Search(len(instructions), func(j int) bool {
+			return instructions[j].Offset >= target
+		})
+		if j < len(instructions) && instructions[j].Offset != target {
+			instructions[i].Operands = []int{instructions[j].Offset}
+		}
+	}
+}
+
+// threadJumps 跳转目标为 OpJump 时直接跳到它的目标
+func threadJumps(instructions []code.Instruction) bool {
+	index := make(map[int]int, len(instructions))
+	for i, ins := range instructions {
+		index[ins.Offset] = i
+	}
+
+	changed := false
+	for i := range instructions {
+		if !code.IsJump(instructions[i].Op) {
+			continue
+		}
+
+		target := instructions[i].Operands[0]
+		// 最多跳转 len(instructions) 次，避免跳转成环时死循环
+		for hops := 0; hops < len(instructions); hops++ {
+			j, ok := index[target]
+			if !ok || instructions[j].Op != code.OpJump || instructions[j].Operands[0] == target {
+				break
+			}
+			target = instructions[j].Operands[0]
+		}
+
+		if target != instructions[i].Operands[0] {
+			instructions[i].Operands = []int{target}
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// jumpTargets 所有跳转目标的原位置
+func jumpTargets(instructions []code.Instruction) map[int]bool {
+	targets := make(map[int]bool)
+	for _, ins := range instructions {
+		if code.IsJump(ins.Op) {
+			targets[ins.Operands[0]] = true
+		}
+	}
+
+	return targets
+}
+
+// jumpsToNext 判断第 i 条无条件跳转是否只是跳到紧跟着的下一条指令
+func jumpsToNext(instructions []code.Instruction, i int) bool {
+	target := instructions[i].Operands[0]
+	if i+1 == len(instructions) {
+		return target > instructions[i].Offset
+	}
+
+	return target > instructions[i].Offset && target <= instructions[i+1].Offset
+}
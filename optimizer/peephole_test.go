@@ -0,0 +1,228 @@
+package optimizer
+
+import (
+	"github.com/Shea11012/interpreter_in_go/code"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"testing"
+)
+
+func concat(instructions ...[]byte) code.Instructions {
+	var out code.Instructions
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestOptimizeBytecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     *compiler.Bytecode
+		expected  code.Instructions
+		constants int
+	}{
+		{
+			name: "intern constants",
+			input: &compiler.Bytecode{
+				Instructions: concat(
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpAdd),
+					code.Make(code.OpPop),
+				),
+				Constants: []object.Object{
+					&object.String{Value: "ok"},
+					&object.String{Value: "ok"},
+					&object.String{Value: "ok"},
+				},
+			},
+			expected: concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			),
+			constants: 1,
+		},
+		{
+			name: "constant condition",
+			// if (true) { 10 } else { 20 }
+			input: &compiler.Bytecode{
+				Instructions: concat(
+					code.Make(code.OpTrue),              // 0000
+					code.Make(code.OpJumpNotTruthy, 10), // 0001
+					code.Make(code.OpConstant, 0),       // 0004
+					code.Make(code.OpJump, 13),          // 0007
+					code.Make(code.OpConstant, 1),       // 0010
+					code.Make(code.OpPop),               // 0013
+				),
+				Constants: []object.Object{&object.Integer{Value: 10}, &object.Integer{Value: 20}},
+			},
+			expected: concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			),
+			constants: 2,
+		},
+		{
+			name: "thread jumps and drop unreachable code",
+			input: &compiler.Bytecode{
+				Instructions: concat(
+					code.Make(code.OpGetGlobal, 0),     // 0000
+					code.Make(code.OpJumpNotTruthy, 9), // 0003
+					code.Make(code.OpJump, 12),         // 0006
+					code.Make(code.OpJump, 16),         // 0009
+					code.Make(code.OpNull),             // 0012
+					code.Make(code.OpJump, 9),          // 0013
+					code.Make(code.OpConstant, 0),      // 0016
+					code.Make(code.OpPop),              // 0019
+				),
+				Constants: []object.Object{&object.Integer{Value: 1}},
+			},
+			expected: concat(
+				code.Make(code.OpGetGlobal, 0),     // 0000
+				code.Make(code.OpJumpNotTruthy, 7), // 0003
+				code.Make(code.OpNull),             // 0006
+				code.Make(code.OpConstant, 0),      // 0007
+				code.Make(code.OpPop),              // 0010
+			),
+			constants: 1,
+		},
+		{
+			name: "drop push pop pairs",
+			input: &compiler.Bytecode{
+				Instructions: concat(
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+				),
+				Constants: []object.Object{&object.Integer{Value: 1}},
+			},
+			expected: concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			),
+			constants: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		original := append(code.Instructions(nil), tt.input.Instructions...)
+
+		result, err := OptimizeBytecode(tt.input)
+		if err != nil {
+			t.Fatalf("%s: OptimizeBytecode returned error: %s", tt.name, err)
+		}
+
+		if result.Instructions.String() != tt.expected.String() {
+			t.Errorf("%s: wrong instructions.\nwant=\n%s\ngot=\n%s", tt.name, tt.expected, result.Instructions)
+		}
+
+		if len(result.Constants) != tt.constants {
+			t.Errorf("%s: wrong number of constants. want=%d, got=%d", tt.name, tt.constants, len(result.Constants))
+		}
+
+		if tt.input.Instructions.String() != original.String() {
+			t.Errorf("%s: input instructions were modified", tt.name)
+		}
+	}
+}
+
+func TestOptimizeBytecodeFunctions(t *testing.T) {
+	input := `
+	let f = fn(x) {
+		if (true) { return "ok"; }
+		"ok";
+		x;
+		return "unreachable";
+	};
+	["ok", f(1)]`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	result, err := OptimizeBytecode(comp.Bytecode())
+	if err != nil {
+		t.Fatalf("OptimizeBytecode returned error: %s", err)
+	}
+
+	var fn *object.CompiledFunction
+	strings := 0
+	for _, constant := range result.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFunction:
+			fn = constant
+		case *object.String:
+			if constant.Value == "ok" {
+				strings++
+			}
+		}
+	}
+
+	if strings != 1 {
+		t.Errorf("string constant not interned. got %d copies", strings)
+	}
+
+	expected := concat(
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpReturnValue),
+	)
+	if fn == nil || fn.Instructions.String() != expected.String() {
+		t.Errorf("wrong function instructions.\nwant=\n%s\ngot=\n%s", expected, fn.Instructions)
+	}
+}
+
+// TestOptimizeBytecodePreservesResults 优化前后虚拟机的执行结果相同
+func TestOptimizeBytecodePreservesResults(t *testing.T) {
+	tests := []string{
+		"1; 2; 3",
+		"let x = 5; x; 10",
+		"if (true) { 1 } else { 2 }",
+		"if (false) { 1 } else { 2 }",
+		"if (false) { 1 }",
+		"let a = 1; if (a > 0) { if (a > 1) { 2 } else { 3 } } else { 4 }",
+		"let f = fn(n) { if (n < 1) { return 0; } n + f(n - 1) }; f(10)",
+		`let s = "ok"; [s, "ok", "ok" + "ok", len("ok")]`,
+		"let g = fn() { let x = 1; x; true; 2 }; g()",
+		`let h = fn(x) { if (x) { "yes" } else { "no" } }; [h(true), h(false), h(if (false) { 1 })]`,
+		"let c = fn(a) { fn(b) { a + b } }; c(1)(2)",
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+
+		optimized, err := OptimizeBytecode(bytecode)
+		if err != nil {
+			t.Fatalf("OptimizeBytecode returned error: %s", err)
+		}
+
+		expected := runBytecode(t, bytecode)
+		if got := runBytecode(t, optimized); got != expected {
+			t.Errorf("result changed for %q. want=%q, got=%q", input, expected, got)
+		}
+	}
+}
+
+func runBytecode(t *testing.T, bytecode *compiler.Bytecode) string {
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	return machine.LastPoppedStackElem().Inspect()
+}
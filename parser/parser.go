@@ -5,6 +5,7 @@ import (
 	"github.com/Shea11012/interpreter_in_go/ast"
 	"github.com/Shea11012/interpreter_in_go/lexer"
 	"github.com/Shea11012/interpreter_in_go/token"
+	"math/big"
 	"strconv"
 )
 
@@ -29,6 +30,7 @@ var precedences = map[token.Type]int{
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
@@ -43,6 +45,7 @@ type Parser struct {
 	l *lexer.Lexer // 解析器
 
 	errors    []string    // 存放解析错误的所有信息
+	positions []Error     // 与 errors 一一对应，带有出错的位置
 	curToken  token.Token // 当前token
 	peekToken token.Token // 下一个token
 
@@ -68,6 +71,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -76,6 +80,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
@@ -95,6 +100,23 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Error 带有位置的解析错误
+type Error struct {
+	Message string
+	Token   token.Token // 出错位置的 token
+}
+
+// ErrorsWithPosition 与 Errors 相同，但每个错误带有出错的位置
+func (p *Parser) ErrorsWithPosition() []Error {
+	return p.positions
+}
+
+// addError 记录一个解析错误，tok 为出错位置的 token
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.positions = append(p.positions, Error{Message: msg, Token: tok})
+}
+
 // nextToken 获取下一个token和下下一个token
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
@@ -113,6 +135,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+
+	program.Comments = p.l.Comments()
 	return program
 }
 
@@ -189,7 +213,7 @@ func (p *Parser) expectPeek(t token.Type) bool {
 // peekError 格式化下一个类型的错误信息
 func (p *Parser) peekError(t token.Type) {
 	msg := fmt.Sprintf("expected next token to be %s,got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 // parseReturnStatement 将类型为return的token，解析为ReturnStatement
@@ -281,9 +305,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		// 超出 int64 范围的字面量在运行时是 BigInt
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -294,7 +325,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 // noPrefixParseFnError 格式化遇到未注册的token类型前缀表达式函数错误
 func (p *Parser) noPrefixParseFnError(t token.Type) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 // parsePrefixExpression 解析前缀表达式
@@ -321,6 +352,15 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// Precedence 获取中缀操作符的优先级，不是中缀操作符时返回 LOWEST
+func Precedence(t token.Type) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
+}
+
 // curPrecedence 获取当前token.Type优先级
 func (p *Parser) curPrecedence() int {
 	if p, ok := precedences[p.curToken.Type]; ok {
@@ -433,6 +473,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	block.Rbrace = p.curToken
 	return block
 }
 
@@ -458,6 +499,27 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return fnLiteral
 }
 
+// parseMacroLiteral 解析宏，参数和函数体与函数相同
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	macro.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	macro.Body = p.parseBlockStatement()
+
+	return macro
+}
+
 // parseFunctionParameters 解析函数参数
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	params := make([]*ast.Identifier, 0)
@@ -580,13 +642,38 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	return list
 }
 
-// parseIndexExpression 解析数组索引表达式
+// parseIndexExpression 解析数组索引表达式 x[i] 和切片表达式 x[low:high]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
-	// 跳过 [
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		// 跳过 [
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+// parseSliceExpression 解析切片表达式 : 之后的部分，curToken 为 : 之前的 token
+func (p *Parser) parseSliceExpression(tok token.Token, left ast.Expression, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+	// 跳过 :
 	p.nextToken()
 
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -597,10 +684,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 
 // parseHashLiteral 解析哈希表达式
 func (p *Parser) parseHashLiteral() ast.Expression {
-	hash := &ast.HashLiteral{
-		Token: p.curToken,
-		Pairs: make(map[ast.Expression]ast.Expression),
-	}
+	hash := &ast.HashLiteral{Token: p.curToken}
 
 	// 下一个token不是 }
 	for !p.peekTokenIs(token.RBRACE) {
@@ -614,7 +698,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		// 判断hash边界，且跳过 ,
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
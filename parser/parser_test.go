@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/ast"
 	"github.com/Shea11012/interpreter_in_go/lexer"
+	"reflect"
 	"testing"
 )
 
@@ -127,6 +128,28 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testLiteralExpression(t, stmt.Expression, 5)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "18446744073709551616;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Big == nil || literal.Big.String() != "18446744073709551616" {
+		t.Errorf("literal.Big not 18446744073709551616. got=%v", literal.Big)
+	}
+
+	if literal.TokenLiteral() != "18446744073709551616" {
+		t.Errorf("literal.TokenLiteral not 18446744073709551616. got=%s", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -178,6 +201,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
@@ -217,6 +241,8 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a * b * c", "((a * b) * c)"},
 		{"a * b / c", "((a * b) / c)"},
 		{"a + b / c", "(a + (b / c))"},
+		{"a * b % c", "((a * b) % c)"},
+		{"a + b % c", "(a + (b % c))"},
 		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
 		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
 		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
@@ -269,19 +295,25 @@ func TestParsingHashLiteralStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral got=%T", key)
+			t.Errorf("key is not ast.StringLiteral got=%T", pair.Key)
+			continue
 		}
 
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		if literal.String() != expected[i].key {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expected[i].key, literal.String())
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -333,10 +365,10 @@ func TestParsingHashLiteralWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral got=%T", key)
+			t.Errorf("key is not ast.StringLiteral got=%T", pair.Key)
 			continue
 		}
 
@@ -346,7 +378,7 @@ func TestParsingHashLiteralWithExpressions(t *testing.T) {
 			continue
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -563,6 +595,47 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"arr[1:3]", "(arr[1:3])"},
+		{"arr[:2]", "(arr[:2])"},
+		{"arr[1:]", "(arr[1:])"},
+		{"arr[:]", "(arr[:])"},
+		{"arr[-1 + 2:len(arr)]", "(arr[((-1) + 2):len(arr)])"},
+		{"s[0][1:][:1]", "(((s[0])[1:])[:1])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	l := lexer.New("arr[1:3]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, slice.Left, "arr") {
+		return
+	}
+	testLiteralExpression(t, slice.Low, 1)
+	testLiteralExpression(t, slice.High, 3)
+}
+
 func TestFunctionLiteralWithName(t *testing.T) {
 	input := `let myFunction = fn(){};`
 	l := lexer.New(input)
@@ -589,6 +662,105 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statement got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong, want 2, got=%d\n", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement got=%d\n", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("bodyStmt is not ast.ExpressionStatement got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := `
+	// comment
+	let add = fn(a, b) { return a + b; };
+	let m = macro(x) { quote(unquote(x)); };
+	if (!true) { add(1, 2)[0] } else { {"a": [1, 2][1:], "b": -3} };
+	fn() {}(); []; {};
+	`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+
+	decoded, err := ast.DecodeProgramJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeProgramJSON returned error: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, program) {
+		t.Fatalf("decoded program is not equal to the parsed program.\nencoded=%s", data)
+	}
+}
+
+func TestErrorsWithPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+		column   int
+	}{
+		{"let x 5;", "expected next token to be =,got INT instead", 1, 7},
+		{"let x = 1;\n  return );", "no prefix parse function for ) found", 2, 10},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.ErrorsWithPosition()
+		if len(errs) == 0 || len(errs) != len(p.Errors()) {
+			t.Fatalf("wrong number of errors for %q. got=%d, Errors()=%d", tt.input, len(errs), len(p.Errors()))
+		}
+
+		err := errs[0]
+		if err.Message != tt.expected || err.Message != p.Errors()[0] {
+			t.Errorf("wrong message for %q. got=%q, want=%q", tt.input, err.Message, tt.expected)
+		}
+		if err.Token.Line != tt.line || err.Token.Column != tt.column {
+			t.Errorf("wrong position for %q. got=%d:%d, want=%d:%d",
+				tt.input, err.Token.Line, err.Token.Column, tt.line, tt.column)
+		}
+	}
+}
+
 func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, operator string, right interface{}) bool {
 	opExp, ok := exp.(*ast.InfixExpression)
 	if !ok {
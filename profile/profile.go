@@ -0,0 +1,275 @@
+// Package profile 通过 vm.Hooks 统计每个函数的调用次数、执行的指令数和耗时
+package profile
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Function 一个函数的统计结果，函数按函数名和定义所在的行区分，同一个函数创建的多个闭包合并统计
+//
+// Instructions 和 Time 包含函数调用的其他函数，递归调用只计算最外层的一次；
+// SelfInstructions 和 SelfTime 只包含函数本身，内置函数的耗时算在调用者上
+type Function struct {
+	Name             string
+	Line             int
+	Calls            int64
+	Instructions     int64
+	SelfInstructions int64
+	Time             time.Duration
+	SelfTime         time.Duration
+}
+
+// String 返回函数名和定义所在的行，如 fib:3，主程序为 <main>
+func (f *Function) String() string {
+	if f.Line == 0 {
+		return f.Name
+	}
+
+	return fmt.Sprintf("%s:%d", f.Name, f.Line)
+}
+
+type key struct {
+	name string
+	line int
+}
+
+// node 调用树中的一个节点，从主程序到这个节点的路径为一个调用栈
+type node struct {
+	function *Function
+	children map[key]*node
+	self     int64 // 位于栈顶时执行的指令数
+}
+
+// frame 一次还没有返回的函数调用
+type frame struct {
+	node         *node
+	instructions int64     // 进入函数时已经执行的指令数
+	start        time.Time // 进入函数的时间
+}
+
+// Profiler 记录程序运行过程中每个函数的统计结果，通过 Hooks 添加到虚拟机上，运行结束后调用 Stop
+type Profiler struct {
+	functions map[key]*Function
+	root      *node
+	stack     []frame
+	active    map[*Function]int // 函数在调用栈中出现的次数，用于递归时只统计最外层的调用
+
+	instructions int64
+	last         time.Time // 上一次调用或返回的时间，之后的时间算在栈顶函数上
+	now          func() time.Time
+	stopped      bool
+}
+
+// New 创建一个 Profiler
+func New() *Profiler {
+	return &Profiler{
+		functions: make(map[key]*Function),
+		active:    make(map[*Function]int),
+		now:       time.Now,
+	}
+}
+
+// Hooks 返回需要添加到虚拟机上的回调
+func (p *Profiler) Hooks() *vm.Hooks {
+	return &vm.Hooks{
+		Instruction: p.instruction,
+		Call:        p.call,
+		Return:      p.ret,
+	}
+}
+
+func (p *Profiler) instruction(v *vm.VM) error {
+	if len(p.stack) == 0 {
+		// 第一条指令，主程序入栈
+		frames := v.Frames()
+		main := frames[len(frames)-1].Function()
+		p.root = &node{function: p.function(main)}
+		p.last = p.now()
+		p.push(p.root, p.last)
+	}
+
+	p.instructions++
+	p.stack[len(p.stack)-1].node.self++
+	p.stack[len(p.stack)-1].node.function.SelfInstructions++
+	return nil
+}
+
+func (p *Profiler) call(v *vm.VM, fn *object.CompiledFunction) {
+	if len(p.stack) == 0 {
+		return
+	}
+
+	now := p.elapse()
+	parent := p.stack[len(p.stack)-1].node
+	f := p.function(fn)
+	k := key{name: f.Name, line: f.Line}
+
+	child, ok := parent.children[k]
+	if !ok {
+		child = &node{function: f}
+		if parent.children == nil {
+			parent.children = make(map[key]*node)
+		}
+		parent.children[k] = child
+	}
+
+	p.push(child, now)
+}
+
+func (p *Profiler) ret(v *vm.VM, fn *object.CompiledFunction) {
+	if len(p.stack) <= 1 {
+		return
+	}
+
+	p.pop(p.elapse())
+}
+
+// Stop 结束统计，运行出错时还没有返回的函数在这里结束，可以重复调用
+func (p *Profiler) Stop() {
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+
+	if len(p.stack) == 0 {
+		return
+	}
+
+	now := p.elapse()
+	for len(p.stack) > 0 {
+		p.pop(now)
+	}
+}
+
+func (p *Profiler) function(fn *object.CompiledFunction) *Function {
+	k := key{name: vm.FunctionName(fn), line: fn.Line}
+	f, ok := p.functions[k]
+	if !ok {
+		f = &Function{Name: k.name, Line: k.line}
+		p.functions[k] = f
+	}
+
+	return f
+}
+
+func (p *Profiler) push(n *node, now time.Time) {
+	n.function.Calls++
+	p.active[n.function]++
+	p.stack = append(p.stack, frame{node: n, instructions: p.instructions, start: now})
+}
+
+func (p *Profiler) pop(now time.Time) {
+	top := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	f := top.node.function
+	p.active[f]--
+	if p.active[f] == 0 {
+		f.Instructions += p.instructions - top.instructions
+		f.Time += now.Sub(top.start)
+	}
+}
+
+// elapse 把上一次调用或返回之后的时间算在栈顶函数上，返回当前时间
+func (p *Profiler) elapse() time.Time {
+	now := p.now()
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].node.function.SelfTime += now.Sub(p.last)
+	}
+	p.last = now
+
+	return now
+}
+
+// Functions 返回所有函数的统计结果，按 SelfInstructions 从多到少排列
+func (p *Profiler) Functions() []*Function {
+	functions := make([]*Function, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if a.SelfInstructions != b.SelfInstructions {
+			return a.SelfInstructions > b.SelfInstructions
+		}
+		if a.Instructions != b.Instructions {
+			return a.Instructions > b.Instructions
+		}
+		return a.String() < b.String()
+	})
+
+	return functions
+}
+
+// WriteTable 输出 SelfInstructions 最多的 n 个函数，n 不大于 0 时输出所有函数
+func (p *Profiler) WriteTable(w io.Writer, n int) error {
+	functions := p.Functions()
+	if n > 0 && n < len(functions) {
+		functions = functions[:n]
+	}
+
+	total := p.instructions
+	if total == 0 {
+		total = 1
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "%10s %12s %7s %12s %7s %12s %12s  %s\n",
+		"calls", "self instrs", "self%", "instrs", "total%", "self time", "time", "function")
+	for _, f := range functions {
+		fmt.Fprintf(&out, "%10d %12d %6.2f%% %12d %6.2f%% %12s %12s  %s\n",
+			f.Calls,
+			f.SelfInstructions, percent(f.SelfInstructions, total),
+			f.Instructions, percent(f.Instructions, total),
+			f.SelfTime.Round(time.Microsecond), f.Time.Round(time.Microsecond),
+			f)
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func percent(n, total int64) float64 {
+	return float64(n) * 100 / float64(total)
+}
+
+// WriteFolded 按 flamegraph.pl 等火焰图工具使用的 folded 格式输出调用栈，
+// 每行为用 ; 分隔的调用栈和位于栈顶时执行的指令数，按调用栈排序
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	if p.root == nil {
+		return nil
+	}
+
+	var lines []string
+	var walk func(n *node, path string)
+	walk = func(n *node, path string) {
+		if path != "" {
+			path += ";"
+		}
+		path += n.function.String()
+
+		if n.self > 0 {
+			lines = append(lines, fmt.Sprintf("%s %d", path, n.self))
+		}
+		for _, child := range n.children {
+			walk(child, path)
+		}
+	}
+	walk(p.root, "")
+
+	sort.Strings(lines)
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package profile
+
+import (
+	"bytes"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"testing"
+	"time"
+)
+
+const testSource = `let fib = fn(n) {
+    if (n < 2) { return n; }
+    fib(n - 1) + fib(n - 2)
+};
+let twice = fn(f, x) { f(f(x)) };
+let inc = fn(x) { x + 1 };
+fib(3);
+twice(inc, 1);
+len("abc");`
+
+// run 运行 source 并返回统计结果，每次调用或返回时钟前进 1ms
+func run(t *testing.T, source string) (*Profiler, error) {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	profiler := New()
+	clock := time.Unix(0, 0)
+	profiler.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.AddHooks(profiler.Hooks())
+	err := machine.Run()
+	profiler.Stop()
+
+	return profiler, err
+}
+
+func TestFunctions(t *testing.T) {
+	profiler, err := run(t, testSource)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	functions := make(map[string]*Function)
+	var total int64
+	for _, f := range profiler.Functions() {
+		functions[f.String()] = f
+		total += f.SelfInstructions
+	}
+
+	if total != profiler.instructions {
+		t.Errorf("self instructions do not add up. got=%d, want=%d", total, profiler.instructions)
+	}
+
+	tests := []struct {
+		function string
+		calls    int64
+	}{
+		{"<main>", 1},
+		{"fib:1", 5},
+		{"twice:5", 1},
+		{"inc:6", 2},
+	}
+
+	for _, tt := range tests {
+		f, ok := functions[tt.function]
+		if !ok {
+			t.Errorf("function %s not found", tt.function)
+			continue
+		}
+		if f.Calls != tt.calls {
+			t.Errorf("%s: wrong calls. got=%d, want=%d", tt.function, f.Calls, tt.calls)
+		}
+		if f.Instructions < f.SelfInstructions {
+			t.Errorf("%s: instructions %d less than self instructions %d", tt.function, f.Instructions, f.SelfInstructions)
+		}
+		if f.Time < f.SelfTime {
+			t.Errorf("%s: time %s less than self time %s", tt.function, f.Time, f.SelfTime)
+		}
+	}
+
+	main := functions["<main>"]
+	if main.Instructions != profiler.instructions {
+		t.Errorf("wrong main instructions. got=%d, want=%d", main.Instructions, profiler.instructions)
+	}
+
+	// twice 包含两次 inc 调用
+	twice, inc := functions["twice:5"], functions["inc:6"]
+	if twice.Instructions != twice.SelfInstructions+inc.Instructions {
+		t.Errorf("wrong twice instructions. got=%d, want=%d", twice.Instructions, twice.SelfInstructions+inc.Instructions)
+	}
+
+	// 递归只计算最外层的调用，fib 不调用其他函数
+	fib := functions["fib:1"]
+	if fib.Instructions != fib.SelfInstructions {
+		t.Errorf("wrong fib instructions. got=%d, want=%d", fib.Instructions, fib.SelfInstructions)
+	}
+
+	// 时钟在每次调用和返回时前进，fib 最外层的调用从进入到返回经过 9 次调用和返回
+	if fib.Time != 9*time.Millisecond {
+		t.Errorf("wrong fib time. got=%s, want=%s", fib.Time, 9*time.Millisecond)
+	}
+	if fib.SelfTime != fib.Time {
+		t.Errorf("wrong fib self time. got=%s, want=%s", fib.SelfTime, fib.Time)
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	profiler, err := run(t, testSource)
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	var out bytes.Buffer
+	if err := profiler.WriteFolded(&out); err != nil {
+		t.Fatalf("WriteFolded error: %s", err)
+	}
+
+	var stacks []string
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		i := bytes.LastIndexByte(line, ' ')
+		stacks = append(stacks, string(line[:i]))
+	}
+
+	expected := []string{
+		"<main>",
+		"<main>;fib:1",
+		"<main>;fib:1;fib:1",
+		"<main>;fib:1;fib:1;fib:1",
+		"<main>;twice:5",
+		"<main>;twice:5;inc:6",
+	}
+	if len(stacks) != len(expected) {
+		t.Fatalf("wrong number of stacks. got=%q, want=%q", stacks, expected)
+	}
+	for i, stack := range expected {
+		if stacks[i] != stack {
+			t.Errorf("wrong stack %d. got=%q, want=%q", i, stacks[i], stack)
+		}
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	profiler, err := run(t, `let f = fn() { 1 + "a" }; f();`)
+	if err == nil {
+		t.Fatalf("expected a runtime error")
+	}
+
+	functions := profiler.Functions()
+	if len(functions) != 2 {
+		t.Fatalf("wrong number of functions. got=%d, want=2", len(functions))
+	}
+
+	for _, f := range functions {
+		if f.Calls != 1 || f.Instructions == 0 || f.Time == 0 {
+			t.Errorf("%s: unfinished call not recorded. got=%+v", f, *f)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := profiler.WriteTable(&out, 1); err != nil {
+		t.Fatalf("WriteTable error: %s", err)
+	}
+	if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != 2 {
+		t.Errorf("wrong number of lines. got=%d, want=2\n%s", lines, out.String())
+	}
+}
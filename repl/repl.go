@@ -1,38 +1,41 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/builtin"
 	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
 	"github.com/Shea11012/interpreter_in_go/lexer"
 	"github.com/Shea11012/interpreter_in_go/object"
 	"github.com/Shea11012/interpreter_in_go/parser"
 	"github.com/Shea11012/interpreter_in_go/vm"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	// 程序中的 read_line 等函数与 REPL 共用同一个输入缓冲区
+	programIO := object.NewIO(in, out, out)
 	constants := []object.Object{}
 	globals := make([]object.Object,vm.GlobalsSize)
 	symbolTable := compiler.NewSymbolTable()
+	registry := builtin.Default()
+	macroEnv := object.NewEnvironment()
 
-	for i, v := range builtin.BuiltinFns {
-		symbolTable.DefineBuiltin(i,v.Name)
+	for i, name := range registry.Names() {
+		symbolTable.DefineBuiltin(i, name)
 	}
 
 	for {
 		_, _ = fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := programIO.Stdin.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.New(strings.TrimRight(line, "\r\n"))
 		p := parser.New(l)
 
 		program := p.ParseProgram()
@@ -41,8 +44,15 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "Woops! Macro expansion failed:\n %s\n", err)
+			continue
+		}
+
 		comp := compiler.NewWithState(symbolTable,constants)
-		err := comp.Compile(program)
+		err = comp.Compile(expanded)
 		if err != nil {
 			_, _ = fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
 			continue
@@ -51,6 +61,8 @@ func Start(in io.Reader, out io.Writer) {
 		code := comp.Bytecode()
 		constants = code.Constants
 		machine := vm.NewWithGlobalsStore(code,globals)
+		machine.SetBuiltins(registry)
+		machine.SetIO(programIO)
 		err = machine.Run()
 		if err != nil {
 			_, _ = fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
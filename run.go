@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/cover"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/optimizer"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/profile"
+	"github.com/Shea11012/interpreter_in_go/trace"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// runCommand monkey run [-O] [--profile] [--trace] [--cover] <file>，返回进程的退出码
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	optimize := flags.Bool("O", false, "optimize the AST and the compiled bytecode")
+	profiling := flags.Bool("profile", false, "print a function profile to stderr after the run")
+	top := flags.Int("profile-top", 20, "print the `n` functions with the most self instructions, 0 for all")
+	folded := flags.String("profile-folded", "", "write folded stacks for flame graph tools to `file`, implies --profile")
+	tracing := flags.Bool("trace", false, "print every executed instruction to stderr")
+	events := flags.String("trace-events", "", "write function enter and exit events in the Chrome trace event format to `file`")
+	covering := flags.Bool("cover", false, "print a line coverage summary to stderr after the run")
+	coverProfile := flags.String("coverprofile", "", "merge the line coverage into `file`, implies --cover")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		_, _ = fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	program, ok := parseFile(flags.Arg(0))
+	if !ok {
+		return 1
+	}
+
+	bytecode, ok := compileProgram(program, *optimize)
+	if !ok {
+		return 1
+	}
+
+	machine := vm.New(bytecode)
+
+	var profiler *profile.Profiler
+	if *profiling || *folded != "" {
+		profiler = profile.New()
+		machine.AddHooks(profiler.Hooks())
+	}
+
+	var tracer *trace.Tracer
+	if *tracing {
+		tracer = trace.New(os.Stderr)
+		machine.AddHooks(tracer.Hooks())
+	}
+
+	var timeline *trace.Events
+	if *events != "" {
+		timeline = trace.NewEvents()
+		machine.AddHooks(timeline.Hooks())
+	}
+
+	var coverage *cover.Profile
+	if *covering || *coverProfile != "" {
+		coverage = cover.NewProfile()
+		machine.AddHooks(cover.NewRecorder(coverage.File(flags.Arg(0)), bytecode).Hooks())
+	}
+
+	status := 0
+	err := machine.Run()
+	if tracer != nil {
+		if flushErr := tracer.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "runtime error: %s\n", err)
+		status = 1
+	}
+
+	if profiler != nil && !writeProfile(profiler, *top, *folded) {
+		status = 1
+	}
+
+	if timeline != nil && !writeEvents(timeline, *events) {
+		status = 1
+	}
+
+	if coverage != nil && !writeCoverage(coverage, *coverProfile) {
+		status = 1
+	}
+
+	return status
+}
+
+// writeEvents 把函数进入和返回的事件写入 filename
+func writeEvents(timeline *trace.Events, filename string) bool {
+	timeline.Stop()
+	return writeFile(filename, timeline.WriteJSON)
+}
+
+// writeProfile 把函数统计表输出到标准错误，folded 不为空时把 folded 格式的调用栈写入这个文件
+func writeProfile(profiler *profile.Profiler, top int, folded string) bool {
+	profiler.Stop()
+	if err := profiler.WriteTable(os.Stderr, top); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	if folded == "" {
+		return true
+	}
+
+	return writeFile(folded, profiler.WriteFolded)
+}
+
+// writeFile 创建 filename 并用 write 写入内容，出错时把错误输出到标准错误
+func writeFile(filename string, write func(w io.Writer) error) bool {
+	f, err := os.Create(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+
+	return true
+}
+
+// compileProgram 展开宏并编译程序，optimize 为 true 时对 AST 和字节码做优化，出错时把错误输出到标准错误
+func compileProgram(program *ast.Program, optimize bool) (*compiler.Bytecode, bool) {
+	program, ok := expandMacros(program)
+	if !ok {
+		return nil, false
+	}
+
+	if optimize {
+		program = optimizer.Optimize(program)
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "compilation failed: %s\n", err)
+		return nil, false
+	}
+
+	bytecode := comp.Bytecode()
+	if optimize {
+		var err error
+		bytecode, err = optimizer.OptimizeBytecode(bytecode)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "optimization failed: %s\n", err)
+			return nil, false
+		}
+	}
+
+	return bytecode, true
+}
+
+// parseFile 读取并解析源文件，出错时把错误输出到标准错误
+func parseFile(filename string) (*ast.Program, bool) {
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+
+	return parseSource(filename, source)
+}
+
+// parseSource 解析 filename 的内容 source，出错时把错误输出到标准错误
+func parseSource(filename string, source []byte) (*ast.Program, bool) {
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", filename, msg)
+		}
+		return nil, false
+	}
+
+	return program, true
+}
+
+// expandMacros 定义并展开程序中的宏，出错时把错误输出到标准错误
+func expandMacros(program *ast.Program) (*ast.Program, bool) {
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded, err := evaluator.ExpandMacros(program, env)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "macro expansion failed: %s\n", err)
+		return nil, false
+	}
+
+	return expanded.(*ast.Program), true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/cover"
+	"github.com/Shea11012/interpreter_in_go/tester"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// testCommand monkey test [-v] [-run regexp] [--cover] [path...]，有测试失败时退出码为 1
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "print passing tests as well")
+	run := flags.String("run", "", "run only the tests whose name matches `regexp`")
+	covering := flags.Bool("cover", false, "print a line coverage summary to stderr after the tests")
+	coverProfile := flags.String("coverprofile", "", "merge the line coverage into `file`, implies --cover")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	runner := tester.New()
+	if *run != "" {
+		filter, err := regexp.Compile(*run)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid -run: %s\n", err)
+			return 2
+		}
+		runner.Filter = filter
+	}
+	if *covering || *coverProfile != "" {
+		runner.Cover = cover.NewProfile()
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, ok := findTestFiles(paths)
+	if !ok {
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	status := 0
+	for _, filename := range files {
+		if !testFile(runner, filename, *verbose) {
+			status = 1
+		}
+	}
+
+	if runner.Cover != nil && !writeCoverage(runner.Cover, *coverProfile) {
+		status = 1
+	}
+
+	return status
+}
+
+// testFile 运行一个测试文件并输出结果，所有测试都通过时返回 true
+func testFile(runner *tester.Runner, filename string, verbose bool) bool {
+	start := time.Now()
+	program, ok := parseFile(filename)
+	if !ok {
+		fmt.Printf("FAIL\t%s [build failed]\n", filename)
+		return false
+	}
+
+	results, err := runner.Run(filename, program)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		fmt.Printf("FAIL\t%s [build failed]\n", filename)
+		return false
+	}
+
+	passed := true
+	for _, result := range results {
+		if result.Passed() {
+			if verbose {
+				fmt.Printf("--- PASS: %s (%s)\n", result.Name, seconds(result.Duration))
+			}
+			continue
+		}
+
+		passed = false
+		fmt.Printf("--- FAIL: %s (%s)\n", result.Name, seconds(result.Duration))
+		location := filename
+		if result.Line != 0 {
+			location = fmt.Sprintf("%s:%d", filename, result.Line)
+		}
+		if _, ok := result.Err.(*tester.AssertionError); ok {
+			fmt.Printf("    %s: %s\n", location, result.Err)
+		} else {
+			fmt.Printf("    %s: runtime error: %s\n", location, result.Err)
+		}
+	}
+
+	switch {
+	case !passed:
+		fmt.Printf("FAIL\t%s\t%s\n", filename, seconds(time.Since(start)))
+	case len(results) == 0:
+		fmt.Printf("ok  \t%s\t%s [no tests to run]\n", filename, seconds(time.Since(start)))
+	default:
+		fmt.Printf("ok  \t%s\t%s\n", filename, seconds(time.Since(start)))
+	}
+
+	return passed
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// findTestFiles 返回 paths 中的测试文件，目录中递归查找以 _test.mk 结尾的文件，跳过以 . 开头的子目录
+func findTestFiles(paths []string) ([]string, bool) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return nil, false
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && name != path && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && strings.HasSuffix(name, tester.FileSuffix) {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return nil, false
+		}
+	}
+
+	return files, true
+}
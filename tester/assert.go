@@ -0,0 +1,169 @@
+package tester
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"strconv"
+	"strings"
+)
+
+// failFunc 报告断言失败，返回值作为断言函数的结果
+type failFunc func(message string) object.Object
+
+// Registry 包含标准内置函数和断言函数的注册表，用于在测试文件上做静态检查，
+// 在 Runner 之外调用时断言失败返回错误对象
+func Registry() *builtin.Registry {
+	return newRegistry(func(message string) object.Object {
+		return &object.Error{Message: message}
+	})
+}
+
+func newRegistry(fail failFunc) *builtin.Registry {
+	registry := builtin.Default()
+	registry.Register(assertMethod(fail))
+	registry.Register(assertEqMethod(fail))
+	registry.Register(assertErrorMethod(fail))
+	return registry
+}
+
+// assertMethod assert(cond[, message])，cond 不为真时失败
+func assertMethod(fail failFunc) builtin.BuiltinFn {
+	return builtin.BuiltinFn{
+		Name:      "assert",
+		Signature: "assert(OBJECT, STRING?) NULL",
+		Arity:     builtin.RangeArgs(1, 2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if isTruthy(args[0]) {
+				return object.NULL
+			}
+
+			return fail(failure("assert", args[1:], fmt.Sprintf("got %s", show(args[0]))))
+		}},
+	}
+}
+
+// assertEqMethod assert_eq(got, want[, message])，两个值不相等时失败，比较规则与 == 相同
+func assertEqMethod(fail failFunc) builtin.BuiltinFn {
+	return builtin.BuiltinFn{
+		Name:      "assert_eq",
+		Signature: "assert_eq(OBJECT, OBJECT, STRING?) NULL",
+		Arity:     builtin.RangeArgs(2, 3),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+
+			if object.Equal(args[0], args[1]) {
+				return object.NULL
+			}
+
+			return fail(failure("assert_eq", args[2:], fmt.Sprintf("got %s, want %s", show(args[0]), show(args[1]))))
+		}},
+	}
+}
+
+// assertErrorMethod assert_error(fn[, substr])，不带参数调用 fn，没有出错或错误信息不包含 substr 时失败，
+// 成功时返回错误信息
+func assertErrorMethod(fail failFunc) builtin.BuiltinFn {
+	return builtin.BuiltinFn{
+		Name:      "assert_error",
+		Signature: "assert_error(FUNCTION, STRING?) STRING",
+		Arity:     builtin.RangeArgs(1, 2),
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			switch args[0].Type() {
+			case object.CLOSURE_OBJ, object.FUNCTION_OBJ, object.BUILTIN_OBJ:
+			default:
+				return newError("first argument to `assert_error` must be function, got %s", args[0].Type())
+			}
+
+			var substr string
+			if len(args) == 2 {
+				s, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `assert_error` must be STRING, got %s", args[1].Type())
+				}
+				substr = s.Value
+			}
+
+			if rt == nil || rt.Call == nil {
+				return newError("`assert_error` cannot call functions here")
+			}
+
+			var message string
+			result, err := rt.Call(args[0])
+			switch {
+			case err != nil:
+				message = err.Error()
+			case result.Type() == object.ERROR_OBJ:
+				message = result.(*object.Error).Message
+			default:
+				return fail(fmt.Sprintf("assert_error: expected an error, got %s", show(result)))
+			}
+
+			if !strings.Contains(message, substr) {
+				return fail(fmt.Sprintf("assert_error: got error %q, want error containing %q", message, substr))
+			}
+
+			return &object.String{Value: message}
+		}},
+	}
+}
+
+// failure 返回断言失败的信息，有 message 参数时用它代替函数名
+func failure(name string, message []object.Object, detail string) string {
+	if len(message) == 0 {
+		return fmt.Sprintf("%s: %s", name, detail)
+	}
+
+	if s, ok := message[0].(*object.String); ok {
+		return fmt.Sprintf("%s: %s", s.Value, detail)
+	}
+
+	return fmt.Sprintf("%s: %s", message[0].Inspect(), detail)
+}
+
+// show 返回失败信息中值的文本形式，与 Inspect 相同，但字符串(包括数组和哈希中的)带引号，以区分 "1" 和 1
+func show(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.Array:
+		elements := make([]string, 0, len(obj.Elements))
+		for _, e := range obj.Elements {
+			elements = append(elements, show(e))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := make([]string, 0, obj.Len())
+		for _, pair := range obj.OrderedPairs() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", show(pair.Key), show(pair.Value)))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+
+	return obj.Inspect()
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,209 @@
+// Package tester 运行 Monkey 编写的测试：*_test.mk 文件中顶层 let 绑定的、名字以 test_ 开头的函数，
+// 文件的顶层代码只运行一次，每个测试在新的虚拟机中使用全局变量的副本调用测试函数，测试之间互不影响
+package tester
+
+import (
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/cover"
+	"github.com/Shea11012/interpreter_in_go/evaluator"
+	"github.com/Shea11012/interpreter_in_go/limit"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// FileSuffix 测试文件名的后缀
+	FileSuffix = "_test.mk"
+	// FunctionPrefix 测试函数名的前缀
+	FunctionPrefix = "test_"
+)
+
+// Test 文件中的一个测试函数
+type Test struct {
+	Name string
+	Line int
+}
+
+// Result 一个测试的运行结果，Err 为 nil 时测试通过
+type Result struct {
+	Test
+	Err      error
+	Line     int // 出错的源码行，没有行号信息时为 0
+	Duration time.Duration
+}
+
+// Passed 判断测试是否通过
+func (r *Result) Passed() bool {
+	return r.Err == nil
+}
+
+// AssertionError 断言失败
+type AssertionError struct {
+	Message string
+}
+
+func (e *AssertionError) Error() string {
+	return e.Message
+}
+
+// IsTest 判断顶层变量名是否为测试函数名
+func IsTest(name string) bool {
+	return strings.HasPrefix(name, FunctionPrefix)
+}
+
+// Tests 返回程序中的测试函数，按定义的顺序排列，同名的函数只保留第一个位置
+func Tests(program *ast.Program) []Test {
+	var tests []Test
+	seen := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !IsTest(let.Name.Value) || seen[let.Name.Value] {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); !ok {
+			continue
+		}
+
+		seen[let.Name.Value] = true
+		tests = append(tests, Test{Name: let.Name.Value, Line: let.Token.Line})
+	}
+
+	return tests
+}
+
+// Runner 运行测试文件
+type Runner struct {
+	// Filter 只运行函数名匹配的测试，为 nil 时运行所有测试
+	Filter *regexp.Regexp
+	// IO 测试程序使用的标准输入输出，默认为进程的标准输入输出
+	IO *object.IO
+	// Limits 顶层代码和每个测试分别使用的资源限制
+	Limits limit.Limits
+	// Cover 不为 nil 时把每个测试的行覆盖率记录到其中
+	Cover *cover.Profile
+
+	failure *AssertionError // 当前测试中的断言失败，在下一条指令执行之前停止运行
+	frame   *vm.Frame       // 当前测试正在执行的 frame，用于确定出错的源码行
+}
+
+// New 创建 Runner
+func New() *Runner {
+	return &Runner{IO: object.StdIO()}
+}
+
+// Run 运行 filename 中的测试，program 为解析后的文件，展开宏或编译出错时返回错误
+func (r *Runner) Run(filename string, program *ast.Program) ([]Result, error) {
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded, err := evaluator.ExpandMacros(program, env)
+	if err != nil {
+		return nil, fmt.Errorf("macro expansion failed: %s", err)
+	}
+	program = expanded.(*ast.Program)
+
+	registry := newRegistry(r.fail)
+	comp := compiler.NewWithRegistry(registry)
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	globals := make(map[string]int)
+	for i, name := range bytecode.GlobalNames {
+		globals[name] = i
+	}
+
+	// 顶层代码只运行一次，出错时所有测试都报告这个错误
+	setup := r.newVM(filename, bytecode, registry, make([]object.Object, vm.GlobalsSize))
+	setupLine, setupErr := r.run(setup.Run, 0)
+
+	var results []Result
+	for _, test := range Tests(program) {
+		if r.Filter != nil && !r.Filter.MatchString(test.Name) {
+			continue
+		}
+		if setupErr != nil {
+			results = append(results, Result{Test: test, Err: setupErr, Line: setupLine})
+			continue
+		}
+
+		// 每个测试使用全局变量的副本，测试中的赋值不会影响其他测试
+		store := make([]object.Object, len(setup.Globals()))
+		copy(store, setup.Globals())
+		machine := r.newVM(filename, bytecode, registry, store)
+		results = append(results, r.runTest(machine, test, globals[test.Name]))
+	}
+
+	return results, nil
+}
+
+// newVM 创建运行测试文件的虚拟机，globals 为全局变量的存储
+func (r *Runner) newVM(filename string, bytecode *compiler.Bytecode, registry *builtin.Registry, globals []object.Object) *vm.VM {
+	machine := vm.NewWithGlobalsStore(bytecode, globals)
+	machine.SetBuiltins(registry)
+	machine.SetIO(r.IO)
+	machine.SetLimits(r.Limits)
+	machine.AddHooks(&vm.Hooks{Instruction: r.instruction})
+	if r.Cover != nil {
+		machine.AddHooks(cover.NewRecorder(r.Cover.File(filename), bytecode).Hooks())
+	}
+
+	return machine
+}
+
+// runTest 在顶层代码运行之后的虚拟机中调用测试函数
+func (r *Runner) runTest(machine *vm.VM, test Test, global int) Result {
+	start := time.Now()
+	line, err := r.run(func() error {
+		_, err := machine.Call(machine.Globals()[global])
+		return err
+	}, test.Line)
+
+	return Result{Test: test, Err: err, Line: line, Duration: time.Since(start)}
+}
+
+// run 执行 fn 并返回出错的源码行和错误，没有执行任何指令就出错时(如测试函数有参数)行号为 line
+func (r *Runner) run(fn func() error, line int) (int, error) {
+	r.failure, r.frame = nil, nil
+
+	err := fn()
+	// 断言失败之后没有再执行指令时，Hooks 没有机会停止运行
+	if err == nil && r.failure != nil {
+		err = r.failure
+	}
+
+	switch {
+	case err == nil:
+		return 0, nil
+	case r.frame != nil:
+		return r.frame.Line(), err
+	default:
+		return line, err
+	}
+}
+
+func (r *Runner) instruction(v *vm.VM) error {
+	if r.failure != nil {
+		// 断言函数返回之后的第一条指令，与调用断言的指令在同一个 frame 中
+		r.frame = v.CurrentFrame()
+		return r.failure
+	}
+
+	r.frame = v.CurrentFrame()
+	return nil
+}
+
+// fail 记录断言失败，之后的第一条指令停止运行，嵌套在 assert_error 中的失败同样停止运行
+func (r *Runner) fail(message string) object.Object {
+	if r.failure == nil {
+		r.failure = &AssertionError{Message: message}
+	}
+
+	return object.NULL
+}
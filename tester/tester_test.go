@@ -0,0 +1,183 @@
+package tester
+
+import (
+	"github.com/Shea11012/interpreter_in_go/cover"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testSource = `let add = fn(a, b) { a + b };
+let divide = fn(a, b) { if (b == 0) { 1 + "zero" } else { a / b } };
+let test_pass = fn() {
+    assert(add(1, 1) == 2);
+    assert_eq(add(1, 2), 3, "add");
+    assert_eq(assert_error(fn() { divide(1, 0) }, "unspported"), "unspported types for binary operation: INTEGER STRING");
+    assert_error(fn() { len(1) });
+};
+let test_assert = fn() {
+    assert(add(1, 1) == 3, "one plus one");
+    puts("not reached");
+};
+let test_eq = fn() {
+    assert_eq([1, "2"], [1, 2]);
+};
+let test_no_error = fn() {
+    assert_error(fn() { divide(4, 2) });
+};
+let test_wrong_error = fn() {
+    assert_error(fn() { divide(4, 0) }, "division");
+};
+let test_nested = fn() {
+    assert_error(fn() { assert(false) });
+};
+let test_crash = fn() {
+    divide(1, 0)
+};
+let test_args = fn(x) { x };
+let test_value = 1;`
+
+// run 运行 testSource 中的测试，返回每个测试的名字、出错的行和错误信息
+func run(t *testing.T, runner *Runner) []string {
+	t.Helper()
+
+	p := parser.New(lexer.New(testSource))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	runner.IO = object.NewIO(strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	results, err := runner.Run("math_test.mk", program)
+	if err != nil {
+		t.Fatalf("Run error: %s", err)
+	}
+
+	var got []string
+	for _, r := range results {
+		switch r.Err.(type) {
+		case nil:
+			got = append(got, r.Name+" ok")
+		case *AssertionError:
+			got = append(got, r.Name+" "+strconv.Itoa(r.Line)+": "+r.Err.Error())
+		default:
+			got = append(got, r.Name+" "+strconv.Itoa(r.Line)+": runtime error: "+r.Err.Error())
+		}
+	}
+
+	return got
+}
+
+func TestRunner(t *testing.T) {
+	expected := []string{
+		"test_pass ok",
+		"test_assert 10: one plus one: got false",
+		`test_eq 14: assert_eq: got [1, "2"], want [1, 2]`,
+		"test_no_error 17: assert_error: expected an error, got 2",
+		`test_wrong_error 20: assert_error: got error "unspported types for binary operation: INTEGER STRING", want error containing "division"`,
+		"test_nested 23: assert: got false",
+		"test_crash 2: runtime error: unspported types for binary operation: INTEGER STRING",
+		"test_args 28: runtime error: wrong number of arguments: want=1, got=0",
+	}
+
+	if got := run(t, New()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong results.\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestFilter(t *testing.T) {
+	runner := New()
+	runner.Filter = regexp.MustCompile("pass|crash")
+
+	expected := []string{
+		"test_pass ok",
+		"test_crash 2: runtime error: unspported types for binary operation: INTEGER STRING",
+	}
+	if got := run(t, runner); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong results. got=%q, want=%q", got, expected)
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		output   string
+		expected []string
+	}{
+		{
+			"puts(\"setup\");\nlet test_a = fn() { 1 };\nlet test_b = fn() { 2 };",
+			"setup\n",
+			[]string{"test_a <nil>", "test_b <nil>"},
+		},
+		{
+			"let test_a = fn() { 1 };\nassert(false, \"top\");\nlet test_b = fn() { 2 };",
+			"",
+			[]string{"test_a 2: top: got false", "test_b 2: top: got false"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		var out strings.Builder
+		runner := New()
+		runner.IO = object.NewIO(strings.NewReader(""), &out, ioutil.Discard)
+		results, err := runner.Run("top_test.mk", program)
+		if err != nil {
+			t.Fatalf("Run error: %s", err)
+		}
+
+		var got []string
+		for _, r := range results {
+			if r.Err == nil {
+				got = append(got, r.Name+" <nil>")
+			} else {
+				got = append(got, r.Name+" "+strconv.Itoa(r.Line)+": "+r.Err.Error())
+			}
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong results for %q. got=%q, want=%q", tt.input, got, tt.expected)
+		}
+		if out.String() != tt.output {
+			t.Errorf("top level should run once for %q. output=%q, want=%q", tt.input, out.String(), tt.output)
+		}
+	}
+}
+
+func TestCover(t *testing.T) {
+	runner := New()
+	runner.Filter = regexp.MustCompile("^test_pass$")
+	runner.Cover = cover.NewProfile()
+	run(t, runner)
+
+	counts := runner.Cover.File("math_test.mk").Counts
+	if counts[4] != 1 || counts[10] != 0 {
+		t.Errorf("wrong coverage. got=%v", counts)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := Registry()
+	for _, name := range []string{"assert", "assert_eq", "assert_error", "len"} {
+		if _, ok := registry.Get(name); !ok {
+			t.Errorf("builtin %s not registered", name)
+		}
+	}
+
+	// Runner 之外断言失败返回错误对象
+	assertEq, _ := registry.Lookup("assert_eq")
+	result := assertEq.Fn(nil, &object.Integer{Value: 1}, &object.String{Value: "1"})
+	if err, ok := result.(*object.Error); !ok || err.Message != `assert_eq: got 1, want "1"` {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
@@ -4,9 +4,10 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	IDENT  = "IDENT"
-	INT    = "INT"
-	STRING = "STRING"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	STRING  = "STRING"
+	COMMENT = "COMMENT" // 从 // 开始到行尾，不会传给 parser
 
 	// Operators
 	ASSIGN   = "="
@@ -15,6 +16,7 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
 	EQ       = "=="
@@ -40,6 +42,7 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
 )
 
 var keywords = map[string]Type{
@@ -50,6 +53,7 @@ var keywords = map[string]Type{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"macro":  MACRO,
 }
 
 // LookupIdent 检测关键字
@@ -64,6 +68,8 @@ func LookupIdent(ident string) Type {
 type Type string
 
 type Token struct {
-	Type    Type
-	Literal string
+	Type    Type   `json:"type"`
+	Literal string `json:"literal"`
+	Line    int    `json:"line"`   // 所在行，从 1 开始，0 表示没有位置信息
+	Column  int    `json:"column"` // 所在列(字节)，从 1 开始
 }
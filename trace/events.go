@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io"
+	"time"
+)
+
+// Event Chrome trace event 格式中的一个事件，Phase 为 B 表示进入函数，E 表示返回
+type Event struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat"`
+	Phase     string                 `json:"ph"`
+	Timestamp float64                `json:"ts"` // 从开始运行经过的微秒数
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// Events 记录函数进入和返回的时间，可以导出为 Chrome trace event 格式，
+// 在 chrome://tracing 或 Perfetto 中按时间线查看，运行结束后调用 Stop
+type Events struct {
+	events []Event
+	stack  []string // 还没有返回的函数
+	start  time.Time
+	now    func() time.Time
+}
+
+// NewEvents 创建 Events
+func NewEvents() *Events {
+	return &Events{now: time.Now}
+}
+
+// Hooks 返回需要添加到虚拟机上的回调
+func (e *Events) Hooks() *vm.Hooks {
+	return &vm.Hooks{
+		Instruction: e.instruction,
+		Call:        e.call,
+		Return:      e.ret,
+	}
+}
+
+func (e *Events) instruction(v *vm.VM) error {
+	if e.start.IsZero() {
+		// 第一条指令，进入主程序
+		e.start = e.now()
+		frames := v.Frames()
+		e.begin(frames[len(frames)-1].Function(), e.start)
+	}
+
+	return nil
+}
+
+func (e *Events) call(v *vm.VM, fn *object.CompiledFunction) {
+	if len(e.stack) > 0 {
+		e.begin(fn, e.now())
+	}
+}
+
+func (e *Events) ret(v *vm.VM, fn *object.CompiledFunction) {
+	if len(e.stack) > 1 {
+		e.end(e.now())
+	}
+}
+
+// Stop 结束记录，运行出错时还没有返回的函数在这里结束，可以重复调用
+func (e *Events) Stop() {
+	now := e.now()
+	for len(e.stack) > 0 {
+		e.end(now)
+	}
+}
+
+func (e *Events) begin(fn *object.CompiledFunction, now time.Time) {
+	name := vm.FunctionName(fn)
+	if fn.Line != 0 {
+		name = fmt.Sprintf("%s:%d", name, fn.Line)
+	}
+
+	e.stack = append(e.stack, name)
+	e.events = append(e.events, e.event(name, "B", now))
+	if fn.Line != 0 {
+		e.events[len(e.events)-1].Args = map[string]interface{}{"line": fn.Line}
+	}
+}
+
+func (e *Events) end(now time.Time) {
+	name := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	e.events = append(e.events, e.event(name, "E", now))
+}
+
+func (e *Events) event(name string, phase string, now time.Time) Event {
+	return Event{
+		Name:      name,
+		Category:  "function",
+		Phase:     phase,
+		Timestamp: float64(now.Sub(e.start)) / float64(time.Microsecond),
+		PID:       1,
+		TID:       1,
+	}
+}
+
+// Events 返回记录的事件
+func (e *Events) Events() []Event {
+	return e.events
+}
+
+// WriteJSON 以 {"traceEvents": [...]} 的形式输出记录的事件
+func (e *Events) WriteJSON(w io.Writer) error {
+	events := e.events
+	if events == nil {
+		events = []Event{}
+	}
+
+	data, err := json.MarshalIndent(struct {
+		TraceEvents     []Event `json:"traceEvents"`
+		DisplayTimeUnit string  `json:"displayTimeUnit"`
+	}{events, "ns"}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
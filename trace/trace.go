@@ -0,0 +1,96 @@
+// Package trace 通过 vm.Hooks 记录虚拟机执行的每条指令，以及函数进入和返回的时间线
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Shea11012/interpreter_in_go/code"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultStackValues 每条指令默认显示的栈顶值的个数
+const DefaultStackValues = 3
+
+// maxValueLength 显示的值超过这个长度时截断
+const maxValueLength = 32
+
+// Tracer 在每条指令执行之前输出一行，依次为调用栈深度、函数名、指令位置、指令和栈顶的值，
+// 栈顶的值在最右边，如
+//
+//	2 add              0004  OpAdd                     [..., 2, 1, 2]
+type Tracer struct {
+	// StackValues 显示的栈顶值的个数，为 0 时不显示
+	StackValues int
+
+	w *bufio.Writer
+}
+
+// New 创建输出到 w 的 Tracer，运行结束后调用 Flush
+func New(w io.Writer) *Tracer {
+	return &Tracer{StackValues: DefaultStackValues, w: bufio.NewWriter(w)}
+}
+
+// Hooks 返回需要添加到虚拟机上的回调，写入出错时停止运行
+func (t *Tracer) Hooks() *vm.Hooks {
+	return &vm.Hooks{Instruction: t.instruction}
+}
+
+// Flush 把缓冲的输出写入 w
+func (t *Tracer) Flush() error {
+	return t.w.Flush()
+}
+
+func (t *Tracer) instruction(v *vm.VM) error {
+	frame := v.Frames()[0]
+
+	var instruction string
+	decoded, _, err := code.DecodeAt(frame.Instructions(), frame.IP())
+	if err != nil {
+		instruction = fmt.Sprintf("ERROR: %s", err)
+	} else {
+		instruction = decoded.String()
+	}
+
+	_, err = fmt.Fprintf(t.w, "%3d %-16s %04d  %-24s  %s\n",
+		v.Depth(), vm.FunctionName(frame.Function()), frame.IP(), instruction, t.stack(v.Stack()))
+	return err
+}
+
+// stack 返回栈顶 StackValues 个值的文本形式，前面还有其他值时以 ... 开头
+func (t *Tracer) stack(stack []object.Object) string {
+	if t.StackValues <= 0 {
+		return ""
+	}
+
+	from := len(stack) - t.StackValues
+	var values []string
+	if from > 0 {
+		values = append(values, "...")
+	} else {
+		from = 0
+	}
+
+	for _, obj := range stack[from:] {
+		values = append(values, inspect(obj))
+	}
+
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// inspect 返回值的单行文本形式，过长时截断
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "nil"
+	}
+
+	s := strings.Join(strings.Fields(obj.Inspect()), " ")
+	if utf8.RuneCountInString(s) <= maxValueLength {
+		return s
+	}
+
+	return string([]rune(s)[:maxValueLength-3]) + "..."
+}
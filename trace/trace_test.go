@@ -0,0 +1,144 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/object"
+	"github.com/Shea11012/interpreter_in_go/parser"
+	"github.com/Shea11012/interpreter_in_go/vm"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// run 运行 source，hooks 添加到虚拟机上
+func run(t *testing.T, source string, hooks ...*vm.Hooks) error {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	for _, h := range hooks {
+		machine.AddHooks(h)
+	}
+
+	return machine.Run()
+}
+
+func TestTracer(t *testing.T) {
+	var out bytes.Buffer
+	tracer := New(&out)
+	if err := run(t, `let add = fn(a, b) { a + b }; add(1, 2);`, tracer.Hooks()); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Flush error: %s", err)
+	}
+
+	// 闭包的地址每次运行都不同
+	got := regexp.MustCompile(`0x[0-9a-f]+`).ReplaceAllString(out.String(), "0x0")
+	expected := `  1 <main>           0000  OpClosure 0 0             []
+  1 <main>           0004  OpSetGlobal 0             [Closure[0x0]]
+  1 <main>           0007  OpGetGlobal 0             []
+  1 <main>           0010  OpConstant 1              [Closure[0x0]]
+  1 <main>           0013  OpConstant 2              [Closure[0x0], 1]
+  1 <main>           0016  OpCall 2                  [Closure[0x0], 1, 2]
+  2 add              0000  OpGetLocal 0              [Closure[0x0], 1, 2]
+  2 add              0002  OpGetLocal 1              [..., 1, 2, 1]
+  2 add              0004  OpAdd                     [..., 2, 1, 2]
+  2 add              0005  OpReturnValue             [..., 1, 2, 3]
+  1 <main>           0018  OpPop                     [3]
+`
+	if got != expected {
+		t.Errorf("wrong trace.\ngot:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		input    object.Object
+		expected string
+	}{
+		{nil, "nil"},
+		{&object.Integer{Value: 5}, "5"},
+		{&object.String{Value: "a\n  b"}, "a b"},
+		{&object.String{Value: "abcdefghijklmnopqrstuvwxyz0123456789"}, "abcdefghijklmnopqrstuvwxyz012..."},
+	}
+
+	for _, tt := range tests {
+		if got := inspect(tt.input); got != tt.expected {
+			t.Errorf("wrong inspect. got=%q, want=%q", got, tt.expected)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	events := NewEvents()
+	clock := time.Unix(0, 0)
+	events.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	source := `let g = fn(x) { if (x > 0) { x + "a" } };
+let f = fn(x) { g(x) };
+f(0);
+f(1);`
+	if err := run(t, source, events.Hooks()); err == nil {
+		t.Fatalf("expected a runtime error")
+	}
+	events.Stop()
+
+	expected := []struct {
+		name  string
+		phase string
+		ts    float64
+	}{
+		{"<main>", "B", 0},
+		{"f:2", "B", 1000},
+		{"g:1", "B", 2000},
+		{"g:1", "E", 3000},
+		{"f:2", "E", 4000},
+		{"f:2", "B", 5000},
+		{"g:1", "B", 6000},
+		{"g:1", "E", 7000},
+		{"f:2", "E", 7000},
+		{"<main>", "E", 7000},
+	}
+
+	got := events.Events()
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of events. got=%d, want=%d\n%+v", len(got), len(expected), got)
+	}
+	for i, e := range expected {
+		if got[i].Name != e.name || got[i].Phase != e.phase || got[i].Timestamp != e.ts {
+			t.Errorf("wrong event %d. got=%+v, want=%+v", i, got[i], e)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := events.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON error: %s", err)
+	}
+
+	var decoded struct {
+		TraceEvents []Event `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if len(decoded.TraceEvents) != len(expected) {
+		t.Errorf("wrong number of decoded events. got=%d, want=%d", len(decoded.TraceEvents), len(expected))
+	}
+}
@@ -18,3 +18,27 @@ func NewFrame(cl *object.Closure,basePointer int) *Frame {
 func (f *Frame) Instructions() code.Instructions {
 	return f.cl.Fn.Instructions
 }
+
+// Function 返回 frame 正在执行的函数
+func (f *Frame) Function() *object.CompiledFunction {
+	return f.cl.Fn
+}
+
+// Closure 返回 frame 正在执行的闭包，其中包含自由变量的值
+func (f *Frame) Closure() *object.Closure {
+	return f.cl
+}
+
+// IP 返回 frame 当前指令的位置，调用者的 frame 中为调用指令的操作数所在位置
+func (f *Frame) IP() int {
+	if f.ip < 0 {
+		return 0
+	}
+
+	return f.ip
+}
+
+// Line 返回 frame 当前指令对应的源码行，没有行号信息时为 0
+func (f *Frame) Line() int {
+	return f.cl.Fn.Lines.Line(f.IP())
+}
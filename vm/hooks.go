@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"github.com/Shea11012/interpreter_in_go/object"
+)
+
+// MainFunctionName 主程序在调用栈中显示的函数名
+const MainFunctionName = "<main>"
+
+// Hooks 虚拟机执行过程中的回调，用于调试器、性能分析、跟踪和覆盖率统计
+// 字段为 nil 时不调用，没有添加任何 Hooks 时执行速度不受影响
+type Hooks struct {
+	// Instruction 在执行每条指令之前调用，此时 Frames()[0].IP() 为这条指令的位置
+	// 返回错误时停止运行，Run 返回这个错误
+	Instruction func(v *VM) error
+	// Call 在进入函数之后、执行函数的第一条指令之前调用，不包括内置函数
+	Call func(v *VM, fn *object.CompiledFunction)
+	// Return 在函数返回、frame 出栈之后调用，运行出错时已经进入的函数不会再调用 Return
+	Return func(v *VM, fn *object.CompiledFunction)
+}
+
+// AddHooks 添加回调，多组回调按添加的顺序调用，在 Run 之前调用
+func (v *VM) AddHooks(hooks *Hooks) {
+	v.hooks = append(v.hooks, hooks)
+}
+
+func (v *VM) instructionHooks() error {
+	for _, h := range v.hooks {
+		if h.Instruction == nil {
+			continue
+		}
+		if err := h.Instruction(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *VM) callHooks(fn *object.CompiledFunction) {
+	for _, h := range v.hooks {
+		if h.Call != nil {
+			h.Call(v, fn)
+		}
+	}
+}
+
+func (v *VM) returnHooks(fn *object.CompiledFunction) {
+	for _, h := range v.hooks {
+		if h.Return != nil {
+			h.Return(v, fn)
+		}
+	}
+}
+
+// Frames 返回当前的调用栈，第一个元素为正在执行的函数，最后一个为主程序
+func (v *VM) Frames() []*Frame {
+	frames := make([]*Frame, 0, v.framesIndex)
+	for i := v.framesIndex - 1; i >= 0; i-- {
+		frames = append(frames, v.frames[i])
+	}
+
+	return frames
+}
+
+// CurrentFrame 返回正在执行的 frame，与 Frames()[0] 相同，但不需要复制调用栈
+func (v *VM) CurrentFrame() *Frame {
+	return v.currentFrame()
+}
+
+// Depth 返回调用栈的深度，只有主程序时为 1
+func (v *VM) Depth() int {
+	return v.framesIndex
+}
+
+// Locals 返回 frame 的局部变量，下标为局部变量的索引，主程序没有局部变量
+func (v *VM) Locals(frame *Frame) []object.Object {
+	if frame == v.frames[0] {
+		return nil
+	}
+
+	locals := make([]object.Object, frame.cl.Fn.NumLocals)
+	copy(locals, v.stack[frame.basePointer:])
+	return locals
+}
+
+// Globals 返回全局变量，下标为全局变量的索引，没有赋值的为 nil
+func (v *VM) Globals() []object.Object {
+	return v.globals
+}
+
+// Stack 返回栈中当前的值，最后一个元素为栈顶
+func (v *VM) Stack() []object.Object {
+	return v.stack[:v.sp]
+}
+
+// FunctionName 返回函数在调用栈中显示的名字，匿名函数为 <anonymous>
+func FunctionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+
+	return fn.Name
+}
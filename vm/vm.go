@@ -1,20 +1,22 @@
 package vm
 
 import (
+	"context"
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/builtin"
 	"github.com/Shea11012/interpreter_in_go/code"
 	"github.com/Shea11012/interpreter_in_go/compiler"
+	"github.com/Shea11012/interpreter_in_go/limit"
 	"github.com/Shea11012/interpreter_in_go/object"
 )
 
 const StackSize = 2048
-const GlobalsSize = 65536
+const GlobalsSize = compiler.GlobalsSize
 
 var (
-	True  = &object.Boolean{Value: true}
-	False = &object.Boolean{Value: false}
-	Null  = &object.Null{}
+	True  = object.TRUE
+	False = object.FALSE
+	Null  = object.NULL
 )
 
 type VM struct {
@@ -27,25 +29,52 @@ type VM struct {
 
 	frames      []*Frame
 	framesIndex int
+
+	builtins *builtin.Registry
+	resolved []*object.Builtin // 按常量池索引缓存已经解析的内置函数，只在第一次执行时按名字查找
+	runtime  *object.Runtime
+
+	limits    limit.Limits
+	meter     *limit.Meter
+	maxStack  int // 本次运行实际生效的栈大小上限
+	maxFrames int // 本次运行实际生效的 frame 数量上限，包含 main frame
+
+	wide bool  // 当前指令是否带有 OpWide 前缀
+	halt error // 内置函数回调函数时触发的资源限制错误，内置函数返回后中止运行
+	active int // 正在进行的 Run 和 Call 的层数，为 0 时 Call 使用新的 meter
+
+	hooks []*Hooks
 }
 
 const MaxFrames = 1024
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Name:         MainFunctionName,
+		Lines:        bytecode.Lines,
+	}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
 
-	return &VM{
+	vm := &VM{
 		constants:   bytecode.Constants,
 		stack:       make([]object.Object, StackSize),
 		sp:          0,
 		globals:     make([]object.Object, GlobalsSize),
 		frames:      frames,
 		framesIndex: 1,
+		builtins:    builtin.Default(),
+		runtime:     &object.Runtime{IO: object.StdIO()},
+		meter:       limit.NewMeter(context.Background(), limit.Limits{}),
+		maxStack:    StackSize,
+		maxFrames:   MaxFrames,
 	}
+	vm.runtime.Call = vm.Call
+
+	return vm
 }
 
 func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
@@ -63,32 +92,89 @@ func (v *VM) StackTop() object.Object {
 	return v.stack[v.sp-1]
 }
 
+// SetBuiltins 设置运行时使用的内置函数注册表，需要包含编译时使用的内置函数
+func (v *VM) SetBuiltins(registry *builtin.Registry) {
+	v.builtins = registry
+	v.resolved = nil
+}
+
+// SetIO 设置程序使用的标准输入输出，默认为进程的标准输入输出
+func (v *VM) SetIO(io *object.IO) {
+	v.runtime.IO = io
+}
+
+// SetLimits 设置运行时的资源限制，在 Run 之前调用
+func (v *VM) SetLimits(limits limit.Limits) {
+	v.limits = limits
+}
+
 // Run 运行指令
 func (v *VM) Run() error {
+	return v.RunContext(context.Background())
+}
+
+// RunContext 运行指令，ctx 被取消或触发资源限制时停止运行并返回 limit 包中对应的错误
+func (v *VM) RunContext(ctx context.Context) error {
+	v.start(ctx)
+	v.active++
+	defer func() { v.active-- }()
+
+	return v.run(0)
+}
+
+// start 为一次新的 Run 或 Call 重新开始计量资源限制
+func (v *VM) start(ctx context.Context) {
+	v.meter = limit.NewMeter(ctx, v.limits)
+	v.runtime.SizeLimit = v.meter.CheckSize
+	v.maxStack = v.meter.StackSizeLimit(StackSize)
+	// main frame 不计入调用深度
+	v.maxFrames = v.meter.CallDepthLimit(MaxFrames-1) + 1
+	v.halt = nil
+}
+
+// run 执行指令，直到当前 frame 的指令执行完或调用栈深度回到 base
+func (v *VM) run(base int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 	// 从0开始读取
-	for v.currentFrame().ip < len(v.currentFrame().Instructions())-1 {
+	for v.framesIndex > base && v.currentFrame().ip < len(v.currentFrame().Instructions())-1 {
+		if err := v.meter.Step(); err != nil {
+			return err
+		}
+
 		v.currentFrame().ip++
 
+		if len(v.hooks) > 0 {
+			if err := v.instructionHooks(); err != nil {
+				return err
+			}
+		}
+
 		ip = v.currentFrame().ip
 		ins = v.currentFrame().Instructions()
 		op = code.Opcode(ins[ip])
 
+		// OpWide 只改变紧跟着的指令的操作数宽度
+		v.wide = op == code.OpWide
+		if v.wide {
+			v.currentFrame().ip++
+			ip = v.currentFrame().ip
+			op = code.Opcode(ins[ip])
+		}
+
 		switch op {
 		case code.OpPop:
 			v.pop()
 
 		case code.OpConstant:
-			constIndex := code.ReadUint16(ins[ip+1:])
-			v.currentFrame().ip += 2 // 对应每个op可以操作的字节数
+			constIndex := v.readOperand(2) // 对应每个op可以操作的字节数
 			err := v.push(v.constants[constIndex])
 			if err != nil {
 				return err
 			}
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod:
 			err := v.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -121,11 +207,10 @@ func (v *VM) Run() error {
 				return err
 			}
 		case code.OpJump:
-			pos := int(code.ReadUint16(ins[ip+1:]))
+			pos := v.readOperand(2)
 			v.currentFrame().ip = pos - 1 // 跳过一个指令
 		case code.OpJumpNotTruthy:
-			pos := int(code.ReadUint16(ins[ip+1:]))
-			v.currentFrame().ip += 2 // 跳过两个或1个指令
+			pos := v.readOperand(2)
 			condition := v.pop()
 			if !isTruthy(condition) {
 				v.currentFrame().ip = pos - 1
@@ -138,38 +223,37 @@ func (v *VM) Run() error {
 			}
 
 		case code.OpGetGlobal:
-			globalIndex := code.ReadUint16(ins[ip+1:]) // 读取两个字节
-			v.currentFrame().ip += 2                   // 索引后移 2 位
+			globalIndex := v.readOperand(2)
 			err := v.push(v.globals[globalIndex])
 			if err != nil {
 				return err
 			}
 
 		case code.OpSetGlobal:
-			globalIndex := code.ReadUint16(ins[ip+1:]) // 读取两个字节
-			v.currentFrame().ip += 2                   // 索引后移 2 位
+			globalIndex := v.readOperand(2)
 			v.globals[globalIndex] = v.pop()
 
 		case code.OpSetLocal:
-			localIndex := code.ReadUint8(ins[ip+1:])
-			v.currentFrame().ip += 1
+			localIndex := v.readOperand(1)
 
 			frame := v.currentFrame()
-			v.stack[frame.basePointer+int(localIndex)] = v.pop()
+			v.stack[frame.basePointer+localIndex] = v.pop()
 
 		case code.OpGetLocal:
-			localIndex := code.ReadUint8(ins[ip+1:])
-			v.currentFrame().ip += 1
+			localIndex := v.readOperand(1)
 
 			frame := v.currentFrame()
-			err := v.push(v.stack[frame.basePointer+int(localIndex)])
+			err := v.push(v.stack[frame.basePointer+localIndex])
 			if err != nil {
 				return err
 			}
 
 		case code.OpArray:
-			numElements := int(code.ReadUint16(ins[ip+1:]))
-			v.currentFrame().ip += 2
+			numElements := v.readOperand(2)
+
+			if err := v.meter.CheckSize(numElements); err != nil {
+				return err
+			}
 
 			// 根据 OpArray 找出数组元素数量， 根据数量推算出栈中元素，数组的起始索引，构建数组
 			array := v.buildArray(v.sp-numElements, v.sp)
@@ -182,8 +266,11 @@ func (v *VM) Run() error {
 			}
 
 		case code.OpHash:
-			numElements := int(code.ReadUint16(ins[ip+1:]))
-			v.currentFrame().ip += 2
+			numElements := v.readOperand(2)
+
+			if err := v.meter.CheckSize(numElements / 2); err != nil {
+				return err
+			}
 
 			hash, err := v.buildHash(v.sp-numElements, v.sp)
 			if err != nil {
@@ -205,12 +292,26 @@ func (v *VM) Run() error {
 				return err
 			}
 
+		case code.OpSlice:
+			high := v.pop()
+			low := v.pop()
+			left := v.pop()
+
+			result, err := object.Slice(left, low, high)
+			if err != nil {
+				return err
+			}
+
+			err = v.push(result)
+			if err != nil {
+				return err
+			}
+
 		case code.OpClosure:
-			constIndex := code.ReadUint16(ins[ip+1:]) // 获取闭包函数索引
-			numFree := code.ReadUint8(ins[ip+3:])	// 获取闭包函数所需变量数量
-			v.currentFrame().ip += 3
+			constIndex := v.readOperand(2) // 获取闭包函数索引
+			numFree := v.readOperand(1)    // 获取闭包函数所需变量数量
 
-			err := v.pushClosure(int(constIndex),int(numFree))
+			err := v.pushClosure(constIndex, numFree)
 			if err != nil {
 				return err
 			}
@@ -223,18 +324,20 @@ func (v *VM) Run() error {
 			}
 
 		case code.OpGetBuiltin:
-			builtinIndex := code.ReadUint8(ins[ip+1:])
-			v.currentFrame().ip += 1
+			nameIndex := v.readOperand(2)
+
+			fn, err := v.resolveBuiltin(nameIndex)
+			if err != nil {
+				return err
+			}
 
-			definition := builtin.BuiltinFns[builtinIndex]
-			err := v.push(definition.Builtin)
+			err = v.push(fn)
 			if err != nil {
 				return err
 			}
 
 		case code.OpGetFree:
-			freeIndex := code.ReadUint8(ins[ip+1:])
-			v.currentFrame().ip += 1
+			freeIndex := v.readOperand(1)
 
 			currentClosure := v.currentFrame().cl
 			err := v.push(currentClosure.Free[freeIndex])
@@ -243,8 +346,7 @@ func (v *VM) Run() error {
 			}
 
 		case code.OpCall:
-			numArgs := int(code.ReadUint8(ins[ip+1:]))
-			v.currentFrame().ip += 1
+			numArgs := v.readOperand(1)
 
 			err := v.executeCall(numArgs)
 			if err != nil {
@@ -255,6 +357,9 @@ func (v *VM) Run() error {
 			returnValue := v.pop()
 			frame := v.popFrame()
 			v.sp = frame.basePointer - 1
+			if len(v.hooks) > 0 {
+				v.returnHooks(frame.cl.Fn)
+			}
 			err := v.push(returnValue)
 			if err != nil {
 				return err
@@ -263,6 +368,9 @@ func (v *VM) Run() error {
 		case code.OpReturn:
 			frame := v.popFrame()
 			v.sp = frame.basePointer - 1
+			if len(v.hooks) > 0 {
+				v.returnHooks(frame.cl.Fn)
+			}
 			err := v.push(Null)
 			if err != nil {
 				return err
@@ -284,6 +392,18 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// readOperand 读取当前指令的下一个操作数并移动 ip，指令带有 OpWide 前缀时操作数宽度加倍
+func (v *VM) readOperand(width int) int {
+	if v.wide {
+		width *= 2
+	}
+
+	frame := v.currentFrame()
+	operand := code.ReadOperand(frame.Instructions()[frame.ip+1:], width)
+	frame.ip += width
+	return operand
+}
+
 // LastPoppedStackElem 取栈顶值
 func (v *VM) LastPoppedStackElem() object.Object {
 	return v.stack[v.sp]
@@ -291,8 +411,8 @@ func (v *VM) LastPoppedStackElem() object.Object {
 
 // push 将值推入栈中，sp指向栈中下一个值
 func (v *VM) push(o object.Object) error {
-	if v.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if v.sp >= v.maxStack {
+		return &limit.StackSizeError{Limit: v.maxStack}
 	}
 
 	v.stack[v.sp] = o
@@ -322,40 +442,52 @@ func (v *VM) executeBinaryOperation(op code.Opcode) error {
 	}
 }
 
-func (v *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+// integerOperators 算术指令对应的运算符
+var integerOperators = map[code.Opcode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+	code.OpMod: "%",
+}
 
-	var result int64
-	switch op {
-	case code.OpAdd:
-		result = leftValue + rightValue
-	case code.OpSub:
-		result = leftValue - rightValue
-	case code.OpMul:
-		result = leftValue * rightValue
-	case code.OpDiv:
-		result = leftValue / rightValue
-	default:
+// executeBinaryIntegerOperation 整数运算，溢出时结果自动提升为 BigInt，除以 0 时返回错误
+func (v *VM) executeBinaryIntegerOperation(op code.Opcode, left object.Object, right object.Object) error {
+	operator, ok := integerOperators[op]
+	if !ok {
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
 
-	return v.push(&object.Integer{Value: result})
+	result, err := object.IntegerArithmetic(operator, left, right)
+	if err != nil {
+		return err
+	}
+
+	return v.push(result)
 }
 
 func (v *VM) executeComparison(op code.Opcode) error {
 	right := v.pop()
 	left := v.pop()
 
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+	// BigInt 走下面通用的比较
+	_, leftInteger := left.(*object.Integer)
+	_, rightInteger := right.(*object.Integer)
+	if leftInteger && rightInteger {
 		return v.executeIntegerComparison(op, left, right)
 	}
 
 	switch op {
 	case code.OpEqual:
-		return v.push(nativeBoolToBooleanObject(right == left))
+		return v.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return v.push(nativeBoolToBooleanObject(right != left))
+		return v.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
+	case code.OpGreaterThan:
+		result, ok := object.Compare(left, right)
+		if !ok {
+			return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+		}
+		return v.push(nativeBoolToBooleanObject(result > 0))
 	default:
 		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
 	}
@@ -394,12 +526,12 @@ func (v *VM) executeBangOperator() error {
 
 func (v *VM) executeMinusOperator() error {
 	operand := v.pop()
-	if operand.Type() != object.INTEGER_OBJ {
+	result, ok := object.NegateInteger(operand)
+	if !ok {
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
 
-	value := operand.(*object.Integer).Value
-	return v.push(&object.Integer{Value: -value})
+	return v.push(result)
 }
 
 func (v *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, right object.Object) error {
@@ -409,6 +541,10 @@ func (v *VM) executeBinaryStringOperation(op code.Opcode, left object.Object, ri
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
 
+	if err := v.meter.CheckSize(len(leftValue) + len(rightValue)); err != nil {
+		return err
+	}
+
 	return v.push(&object.String{Value: leftValue + rightValue})
 }
 
@@ -422,25 +558,26 @@ func (v *VM) buildArray(startIndex int, endIndex int) object.Object {
 }
 
 func (v *VM) buildHash(startIndex int, endIndex int) (object.Object, error) {
-	hashPairs := make(map[object.HashKey]object.HashPair, endIndex-startIndex)
+	hash := object.NewHash((endIndex - startIndex) / 2)
 	for i := startIndex; i < endIndex; i += 2 {
 		key := v.stack[i]
 		value := v.stack[i+1]
 		pair := object.HashPair{Key: key, Value: value}
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.HashKeyOf(key)
 		if !ok {
 			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
 		}
 
-		hashPairs[hashKey.HashKey()] = pair
+		hash.Set(hashKey, pair)
 	}
-	return &object.Hash{Pairs: hashPairs}, nil
+	return hash, nil
 }
 
 func (v *VM) executeIndexExpression(left object.Object, index object.Object) error {
 	switch {
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return v.executeArrayIndex(left, index)
+	case (left.Type() == object.ARRAY_OBJ || left.Type() == object.STRING_OBJ) && index.Type() == object.INTEGER_OBJ:
+		result, _ := object.ElementAt(left, index)
+		return v.push(result)
 	case left.Type() == object.HASH_OBJ:
 		return v.executeHashIndex(left, index)
 	default:
@@ -448,26 +585,14 @@ func (v *VM) executeIndexExpression(left object.Object, index object.Object) err
 	}
 }
 
-func (v *VM) executeArrayIndex(array object.Object, index object.Object) error {
-	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-
-	if i < 0 || i > max {
-		return v.push(Null)
-	}
-
-	return v.push(arrayObject.Elements[i])
-}
-
 func (v *VM) executeHashIndex(hash object.Object, index object.Object) error {
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return v.push(Null)
 	}
@@ -489,6 +614,71 @@ func (v *VM) popFrame() *Frame {
 	return v.frames[v.framesIndex]
 }
 
+// Call 调用函数 fn 并返回结果，用于在 Run 结束之后调用程序中定义的函数，以及内置函数通过 object.Runtime.Call 回调函数
+// 运行出错时调用栈恢复到调用之前的状态，调用者可以继续执行
+func (v *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return v.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext 与 Call 相同，ctx 被取消时停止运行。
+// 在 Run 或 Call 之外调用时，资源限制从零开始计量，ctx 只对这次调用生效；
+// 在运行中被内置函数回调时沿用当前运行的计量和 ctx，参数 ctx 被忽略
+func (v *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	if v.active == 0 {
+		v.start(ctx)
+	}
+	v.active++
+	defer func() { v.active-- }()
+
+	sp, framesIndex := v.sp, v.framesIndex
+	err := v.call(fn, args)
+	if err != nil {
+		for v.framesIndex > framesIndex {
+			frame := v.popFrame()
+			if len(v.hooks) > 0 {
+				v.returnHooks(frame.cl.Fn)
+			}
+		}
+		v.sp = sp
+		if isLimitError(err) {
+			v.halt = err
+		}
+		return nil, err
+	}
+
+	return v.pop(), nil
+}
+
+// isLimitError 判断 err 是否为 limit 包中的资源限制错误，这类错误不能被内置函数当作普通错误处理
+func isLimitError(err error) bool {
+	switch err.(type) {
+	case *limit.InstructionLimitError, *limit.CallDepthError, *limit.StackSizeError,
+		*limit.CollectionSizeError, *limit.CanceledError:
+		return true
+	}
+
+	return false
+}
+
+func (v *VM) call(fn object.Object, args []object.Object) error {
+	if err := v.push(fn); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := v.push(arg); err != nil {
+			return err
+		}
+	}
+
+	framesIndex := v.framesIndex
+	if err := v.executeCall(len(args)); err != nil {
+		return err
+	}
+
+	// 内置函数的结果已经在栈顶，Monkey 函数执行到返回为止
+	return v.run(framesIndex)
+}
+
 func (v *VM) executeCall(args int) error {
 	callee := v.stack[v.sp-1-args]
 
@@ -507,19 +697,41 @@ func (v *VM) callClosure(cl *object.Closure,numArgs int) error {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",cl.Fn.NumParameters,numArgs)
 	}
 
+	if v.framesIndex >= v.maxFrames {
+		return &limit.CallDepthError{Limit: v.maxFrames - 1}
+	}
+
+	// 局部变量直接占用栈空间，不经过 push 检查
+	if v.sp-numArgs+cl.Fn.NumLocals > v.maxStack {
+		return &limit.StackSizeError{Limit: v.maxStack}
+	}
+
 	frame := NewFrame(cl, v.sp-numArgs)
 	v.pushFrame(frame)
 	// 跳过函数地址和函数变量地址
 	v.sp = frame.basePointer + cl.Fn.NumLocals
 
+	if len(v.hooks) > 0 {
+		v.callHooks(cl.Fn)
+	}
+
 	return nil
 }
 
 func (v *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := v.stack[v.sp-numArgs:v.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Fn(v.runtime, args...)
 	v.sp = v.sp - numArgs - 1
 
+	// 内置函数可能把回调中的资源限制错误转换成了错误对象
+	if v.halt != nil {
+		return v.halt
+	}
+
+	if err := v.meter.CheckObject(result); err != nil {
+		return err
+	}
+
 	var err error
 	if result != nil {
 		err = v.push(result)
@@ -530,6 +742,25 @@ func (v *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return err
 }
 
+// resolveBuiltin 返回常量池中 nameIndex 处的名字对应的内置函数
+func (v *VM) resolveBuiltin(nameIndex int) (*object.Builtin, error) {
+	if v.resolved == nil {
+		v.resolved = make([]*object.Builtin, len(v.constants))
+	}
+	if fn := v.resolved[nameIndex]; fn != nil {
+		return fn, nil
+	}
+
+	name := v.constants[nameIndex].(*object.String).Value
+	fn, ok := v.builtins.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined builtin %s", name)
+	}
+	v.resolved[nameIndex] = fn
+
+	return fn, nil
+}
+
 func (v *VM) pushClosure(constIndex int,numFree int) error {
 	constant := v.constants[constIndex]	// 获取闭包函数
 	function,ok := constant.(*object.CompiledFunction)
@@ -1,13 +1,21 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Shea11012/interpreter_in_go/ast"
+	"github.com/Shea11012/interpreter_in_go/builtin"
+	"github.com/Shea11012/interpreter_in_go/code"
 	"github.com/Shea11012/interpreter_in_go/compiler"
 	"github.com/Shea11012/interpreter_in_go/lexer"
+	"github.com/Shea11012/interpreter_in_go/limit"
 	"github.com/Shea11012/interpreter_in_go/object"
 	"github.com/Shea11012/interpreter_in_go/parser"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -135,13 +143,13 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
-			t.Errorf("hash has wrong number of Pairs. want=%d,got=%d", len(expected), len(hash.Pairs))
+		if hash.Len() != len(expected) {
+			t.Errorf("hash has wrong number of Pairs. want=%d,got=%d", len(expected), hash.Len())
 			return
 		}
 
 		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+			pair, ok := hash.Get(expectedKey)
 			if !ok {
 				t.Errorf("no pair for given key in pairs")
 			}
@@ -315,7 +323,8 @@ func TestIndexExpressions(t *testing.T) {
 		{input: "[[1,1,1]][0][0]", expected: 1},
 		{input: "[][0]", expected: Null},
 		{input: "[1,2,3][99]", expected: Null},
-		{input: "[1][-1]", expected: Null},
+		{input: "[1][-1]", expected: 1},
+		{input: "[1][-2]", expected: Null},
 		{input: "{1:1,2:2}[1]", expected: 1},
 		{input: "{1:1,2:2}[2]", expected: 2},
 		{input: "{1:1}[0]", expected: Null},
@@ -581,10 +590,26 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []vmTestCase{
-		/*{
+		{
 			input:    `map([1,2,3],fn(a) { a * 2});`,
 			expected: []int{2, 4, 6},
-		},*/
+		},
+		{
+			input:    `map([1,2,3],len);`,
+			expected: &object.Error{Message: "argument to `len` not supported, got INTEGER"},
+		},
+		{
+			input:    `reduce([1,2,3],10,fn(acc, a) { acc + a });`,
+			expected: 16,
+		},
+		{
+			input:    `reduce([],10,fn(acc, a) { acc + a });`,
+			expected: 10,
+		},
+		{
+			input:    `map([1,2,3],1);`,
+			expected: &object.Error{Message: "second argument to `map` must be function, got INTEGER"},
+		},
 		{
 			input:    `len("")`,
 			expected: 0,
@@ -794,3 +819,710 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   limit.Limits
+		expected error
+	}{
+		{
+			input:    `let f = fn() { f() }; f();`,
+			limits:   limit.Limits{},
+			expected: &limit.CallDepthError{},
+		},
+		{
+			input:    `let f = fn(x) { f(x + 1) }; f(0);`,
+			limits:   limit.Limits{},
+			expected: &limit.StackSizeError{},
+		},
+		{
+			input:    `let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(20);`,
+			limits:   limit.Limits{MaxCallDepth: 10},
+			expected: &limit.CallDepthError{},
+		},
+		{
+			input:    `1 + 2 + 3 + 4 + 5`,
+			limits:   limit.Limits{MaxInstructions: 5},
+			expected: &limit.InstructionLimitError{},
+		},
+		{
+			input:    `[1, 2, 3, 4, 5]`,
+			limits:   limit.Limits{MaxStackSize: 3},
+			expected: &limit.StackSizeError{},
+		},
+		{
+			input:    `[1, 2, 3, 4, 5]`,
+			limits:   limit.Limits{MaxCollectionSize: 4},
+			expected: &limit.CollectionSizeError{},
+		},
+		{
+			input:    `{1: 1, 2: 2, 3: 3}`,
+			limits:   limit.Limits{MaxCollectionSize: 2},
+			expected: &limit.CollectionSizeError{},
+		},
+		{
+			input:    `"hello" + " world"`,
+			limits:   limit.Limits{MaxCollectionSize: 5},
+			expected: &limit.CollectionSizeError{},
+		},
+		{
+			input:    `push([1, 2], 3)`,
+			limits:   limit.Limits{MaxCollectionSize: 2},
+			expected: &limit.CollectionSizeError{},
+		},
+		{
+			input:    `map([1, 2, 3], fn(x) { let f = fn(y) { f(y) }; f(x) })`,
+			limits:   limit.Limits{MaxCallDepth: 50},
+			expected: &limit.CallDepthError{},
+		},
+		{
+			input:    `let f = fn(x) { if (x == 0) { 0 } else { f(x - 1) } }; f(20);`,
+			limits:   limit.Limits{MaxCallDepth: 21, MaxInstructions: 1000},
+			expected: nil,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			comp := compiler.New()
+			err := comp.Compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			vm := New(comp.Bytecode())
+			vm.SetLimits(tt.limits)
+			err = vm.Run()
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatalf("vm error: %s", err)
+				}
+				return
+			}
+
+			if reflect.TypeOf(err) != reflect.TypeOf(tt.expected) {
+				t.Fatalf("wrong error type. want=%T, got=%T (%v)", tt.expected, err, err)
+			}
+		})
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	input := `
+	let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } };
+	fib(30);
+`
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = New(comp.Bytecode()).RunContext(ctx)
+	var canceled *limit.CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("expected CanceledError, got=%T (%v)", err, err)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error to wrap context.Canceled, got=%v", err)
+	}
+}
+
+func TestBuiltinRegistry(t *testing.T) {
+	registry := builtin.NewPreset(builtin.PresetNoIO)
+	registry.Register(builtin.MustFromFunc("double", func(n int64) int64 { return n * 2 }))
+
+	comp := compiler.NewWithRegistry(registry)
+	err := comp.Compile(parse(`double(len([1, 2, 3]))`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// 编译后注册表中新增和移除其他函数，字节码仍然引用原来的函数
+	registry.Remove("first", "last")
+	registry.Register(builtin.MustFromFunc("triple", func(n int64) int64 { return n * 3 }))
+
+	vm := New(comp.Bytecode())
+	vm.SetBuiltins(registry)
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 6, vm.LastPoppedStackElem())
+
+	registry.Remove("double")
+	vm = New(comp.Bytecode())
+	vm.SetBuiltins(registry)
+	err = vm.Run()
+	if err == nil || err.Error() != "undefined builtin double" {
+		t.Fatalf("expected undefined builtin error, got=%v", err)
+	}
+}
+
+func TestBuiltinResolveCache(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse(`let f = fn(x) { len(x) }; f([1, 2]);`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 2, vm.LastPoppedStackElem())
+
+	// 替换注册表之后不再使用之前解析的内置函数
+	registry := builtin.Default()
+	registry.Register(builtin.MustFromFunc("len", func(a []object.Object) int64 { return int64(len(a)) * 10 }))
+	vm.SetBuiltins(registry)
+
+	result, err := vm.Call(vm.Globals()[0], &object.Array{Elements: []object.Object{Null}})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 10, result)
+}
+
+func TestProgramIO(t *testing.T) {
+	input := `
+	let name = read_line();
+	let second = read_line();
+	puts("hello " + name);
+	print("a", 1, true);
+	eprint("oops");
+	let rest = read_all();
+	let eof = read_line();
+	[second, rest, eof];
+`
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	vm := New(comp.Bytecode())
+	vm.SetIO(object.NewIO(strings.NewReader("monkey\r\nline2\nremaining\ninput"), &stdout, &stderr))
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if stdout.String() != "hello monkey\na1true" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+
+	if stderr.String() != "oops" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+
+	if result := vm.LastPoppedStackElem().Inspect(); result != "[line2, remaining\ninput, null]" {
+		t.Errorf("wrong result. got=%q", result)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("世界")`, "2"},
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`join(split("a b c", " "), "-")`, "a-b-c"},
+		{`upper(trim("  monkey "))`, "MONKEY"},
+		{`index_of("世界 hello", "hello")`, "3"},
+		{`substr("世界 hello", 1, 3)`, "界 h"},
+		{`chars("a世")`, "[a, 世]"},
+		{`format("%s=%d %v", "x", 1, [true])`, "x=1 [true]"},
+		{`format("%d", "x")`, "ERROR: format: %d expects INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRepeatCollectionLimit(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse(`repeat("ab", 100)`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetLimits(limit.Limits{MaxCollectionSize: 10})
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if got := vm.LastPoppedStackElem().Inspect(); got != "ERROR: repeat: collection size limit exceeded: size=200, limit=10" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({1: 2, 3: 4})`, "2"},
+		{`keys({"a": 1})`, "[a]"},
+		{`values({"a": 1})`, "[1]"},
+		{`entries({"a": 1})`, "[[a, 1]]"},
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`let h = {"a": 1, "b": 2}; let d = delete(h, "a"); [len(h), len(d), has_key(d, "a")]`, "[2, 1, false]"},
+		{`merge({"a": 1}, {"a": 2})["a"]`, "2"},
+		{`has_key({}, [fn(x) { x }])`, "ERROR: unusable as hash key: ARRAY"},
+		{`{"b": 1, "a": 2, 10: 3, 2: 4}`, "{b: 1, a: 2, 10: 3, 2: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`keys({"z": 1, "y": 2, "x": 3})`, "[z, y, x]"},
+		{`values(delete({"z": 1, "y": 2, "x": 3}, "y"))`, "[1, 3]"},
+		{`merge({"z": 1, "y": 2}, {"a": 3, "z": 4})`, "{z: 4, y: 2, a: 3}"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStructuralComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" == "a"`, "true"},
+		{`"a" != "a"`, "false"},
+		{`"a" == "b"`, "false"},
+		{`"a" < "b"`, "true"},
+		{`"abc" > "abd"`, "false"},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, "true"},
+		{`[1, 2] != [1, 3]`, "true"},
+		{`[1, 2] < [1, 3]`, "true"},
+		{`[1, 2, 0] > [1, 2]`, "true"},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 2}`, "false"},
+		{`1 == "1"`, "false"},
+		{`let f = fn() { 1 }; [f == f, fn() { 1 } == fn() { 1 }]`, "[true, false]"},
+		{`{[1, "a"]: "found"}[[1, "a"]]`, "found"},
+		{`{[1, "a"]: "found"}[[1, "b"]]`, "null"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	comp := compiler.New()
+	err := comp.Compile(parse(`[true] > [false]`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	err = New(comp.Bytecode()).Run()
+	expected := fmt.Sprintf("unknown operator: %d (ARRAY ARRAY)", code.OpGreaterThan)
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error for unordered arrays. got=%v", err)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3, 4][:2]`, "[1, 2]"},
+		{`[1, 2, 3, 4][2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][-2:]`, "[3, 4]"},
+		{`[1, 2, 3, 4][:-1]`, "[1, 2, 3]"},
+		{`[1, 2, 3, 4][-10:10]`, "[1, 2, 3, 4]"},
+		{`[1, 2, 3, 4][3:1]`, "[]"},
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][-4]`, "null"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, "null"},
+		{`"héllo wörld"[2:5]`, "llo"},
+		{`"héllo wörld"[6:]`, "wörld"},
+		{`"héllo"[4:2]`, ""},
+		{`let a = [1, 2, 3]; let b = a[:]; [a == b, len(a[1:])]`, "[true, 2]"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2]["a":]`, "slice index must be INTEGER, got STRING"},
+		{`{}[1:2]`, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range errors {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err = New(comp.Bytecode()).Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestWideOperands(t *testing.T) {
+	// 标识符只能包含字母，用 vaa、vab、... 生成变量名
+	names := make([]string, 300)
+	for i := range names {
+		names[i] = "v" + string(rune('a'+i/26)) + string(rune('a'+i%26))
+	}
+
+	var locals strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&locals, "let %s = %d; ", name, i)
+	}
+
+	args := make([]string, len(names))
+	for i := range args {
+		args[i] = strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{
+			"300 locals",
+			fmt.Sprintf("let f = fn() { %s %s + %s }; f()", locals.String(), names[0], names[299]),
+			299,
+		},
+		{
+			"300 arguments",
+			fmt.Sprintf("let f = fn(%s) { %s - %s }; f(%s)", strings.Join(names, ", "), names[299], names[1], strings.Join(args, ", ")),
+			298,
+		},
+		{
+			"70000 constants",
+			strings.Repeat("1; ", 69999) + "70000",
+			70000,
+		},
+		{
+			"code larger than 64KB",
+			fmt.Sprintf("let f = fn(x) { if (x) { %s 30000 } else { 0 - 1 } }; f(true) + f(false)", strings.Repeat("1; ", 25000)),
+			29999,
+		},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.name, err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("%s: vm error: %s", tt.name, err)
+		}
+
+		if err := testIntegerObject(tt.expected, vm.LastPoppedStackElem()); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`9223372036854775807 + 1`, "9223372036854775808"},
+		{`18446744073709551616`, "18446744073709551616"},
+		{`18446744073709551616 / 2 - 1`, "9223372036854775807"},
+		{`-9223372036854775808 == -9223372036854775807 - 1`, "true"},
+		{`-9223372036854775807 - 2`, "-9223372036854775809"},
+		{`-(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`let big = 9223372036854775807 * 4; big / 4`, "9223372036854775807"},
+		{`(9223372036854775807 + 1) - 1 == 9223372036854775807`, "true"},
+		{`(9223372036854775807 + 1) > 9223372036854775807`, "true"},
+		{`let f = fn(n) { if (n < 1) { 1 } else { n * f(n - 1) } }; f(25)`, "15511210043330985984000000"},
+		{`let k = 9223372036854775807 + 1; {k: "big"}[9223372036854775807 + 1]`, "big"},
+		{`{(9223372036854775807 + 1) * 2: "big"}[0 - 1300789964862373523]`, "null"},
+		{`has_key({(9223372036854775807 + 1) * 2: "big"}, 0 - 1300789964862373523)`, "false"},
+		{`[1, 2, 3][9223372036854775807 + 1]`, "null"},
+		{`7 % 3`, "1"},
+		{`-7 % 3`, "-1"},
+		{`(9223372036854775807 * 3) % 10`, "1"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("%s vm error: %s", tt.input, err)
+		}
+
+		if got := vm.LastPoppedStackElem().Inspect(); got != tt.expected {
+			t.Errorf("%s wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{`1 / 0`, `1 % 0`, `(9223372036854775807 + 1) / 0`} {
+		comp := compiler.New()
+		err := comp.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err = New(comp.Bytecode()).Run()
+		if err != object.ErrDivisionByZero {
+			t.Errorf("%s wrong error. want=%v, got=%v", input, object.ErrDivisionByZero, err)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	input := `let add = fn(a, b) {
+    a + b
+};
+let double = fn(x) { add(x, x) };
+double(3);`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var events []string
+	var instructions int
+	var locals string
+	machine := New(comp.Bytecode())
+	machine.AddHooks(&Hooks{
+		Instruction: func(v *VM) error {
+			instructions++
+			frame := v.Frames()[0]
+			if frame.Function().Name == "add" && locals == "" {
+				for _, local := range v.Locals(frame) {
+					locals += local.Inspect() + " "
+				}
+				events = append(events, fmt.Sprintf("line %d depth %d", frame.Line(), v.Depth()))
+			}
+			return nil
+		},
+		Call: func(v *VM, fn *object.CompiledFunction) {
+			events = append(events, "call "+FunctionName(fn))
+		},
+		Return: func(v *VM, fn *object.CompiledFunction) {
+			events = append(events, "return "+FunctionName(fn))
+		},
+	})
+
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	expected := []string{"call double", "call add", "line 2 depth 3", "return add", "return double"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("wrong events. got=%v, want=%v", events, expected)
+	}
+	if locals != "3 3 " {
+		t.Errorf("wrong locals. got=%q", locals)
+	}
+	if instructions != 17 {
+		t.Errorf("wrong number of instructions. got=%d", instructions)
+	}
+
+	// Instruction 返回错误时停止运行
+	stop := errors.New("stop")
+	machine = New(comp.Bytecode())
+	machine.AddHooks(&Hooks{Instruction: func(v *VM) error {
+		if v.Depth() == 3 {
+			return stop
+		}
+		return nil
+	}})
+	if err := machine.Run(); err != stop {
+		t.Errorf("wrong error. got=%v, want=%v", err, stop)
+	}
+}
+
+func TestCall(t *testing.T) {
+	// try 调用函数，出错时返回错误信息
+	registry := builtin.Default()
+	registry.Register(builtin.BuiltinFn{
+		Name: "try",
+		Builtin: &object.Builtin{Fn: func(rt *object.Runtime, args ...object.Object) object.Object {
+			result, err := rt.Call(args[0], args[1:]...)
+			if err != nil {
+				return &object.String{Value: "error: " + err.Error()}
+			}
+			return result
+		}},
+	})
+
+	input := `let inc = fn(x) { x + 1 };
+let bad = fn(x) { let y = x * 2; inc(y) + "a" };
+let results = [try(inc, 1), try(bad, 1), try(len, "abc"), try(inc, 1, 2), try(fn() { try(bad, 2) })];
+results`
+
+	comp := compiler.NewWithRegistry(registry)
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var depth []int
+	machine := New(comp.Bytecode())
+	machine.SetBuiltins(registry)
+	machine.AddHooks(&Hooks{Return: func(v *VM, fn *object.CompiledFunction) {
+		depth = append(depth, v.Depth())
+	}})
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	testExpectedObject(t, []interface{}{
+		2,
+		"error: unspported types for binary operation: INTEGER STRING",
+		3,
+		"error: wrong number of arguments: want=1, got=2",
+		"error: unspported types for binary operation: INTEGER STRING",
+	}, machine.LastPoppedStackElem())
+
+	// 出错时放弃的 frame 同样调用 Return，调用栈回到调用之前
+	expected := []int{1, 2, 1, 3, 2, 1}
+	if !reflect.DeepEqual(depth, expected) {
+		t.Errorf("wrong return depths. got=%v, want=%v", depth, expected)
+	}
+
+	// Run 结束之后调用程序中定义的函数
+	result, err := machine.Call(machine.Globals()[0], &object.Integer{Value: 41})
+	if err != nil {
+		t.Fatalf("Call error: %s", err)
+	}
+	testExpectedObject(t, 42, result)
+
+	if _, err := machine.Call(&object.Integer{Value: 1}); err == nil {
+		t.Errorf("expected an error calling an integer")
+	}
+	if len(machine.Stack()) != 0 {
+		t.Errorf("stack not restored. got=%v", machine.Stack())
+	}
+}
+
+func TestCallAfterRun(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let inc = fn(x) { x + 1 }; inc(1); inc(2);`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// Run 用掉了几乎全部指令数，Call 重新开始计量
+	machine := New(comp.Bytecode())
+	machine.SetLimits(limit.Limits{MaxInstructions: 20})
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		result, err := machine.Call(machine.Globals()[0], &object.Integer{Value: int64(i)})
+		if err != nil {
+			t.Fatalf("call %d: unexpected error: %s", i, err)
+		}
+		testExpectedObject(t, i+1, result)
+	}
+
+	// Run 结束后取消的 ctx 不影响之后的 Call
+	comp = compiler.New()
+	if err := comp.Compile(parse(`let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } };`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	machine = New(comp.Bytecode())
+	if err := machine.RunContext(ctx); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	cancel()
+
+	loop, n := machine.Globals()[0], &object.Integer{Value: 500}
+	if _, err := machine.CallContext(ctx, loop, n); err == nil {
+		t.Errorf("expected canceled error from CallContext")
+	}
+	result, err := machine.Call(loop, n)
+	if err != nil {
+		t.Fatalf("unexpected error after canceled run: %s", err)
+	}
+	testExpectedObject(t, 0, result)
+}